@@ -10,16 +10,28 @@ type Array struct {
 
 	//it's a template flag that never be saved
 	mType DType
+	//the types of the nested arrays when mType is ArrayType
+	subTypes []DType
 }
 
-func NewArray(aType DType) *Array {
+// NewArray returns an empty array of aType, an array of arrays
+// takes the types of the nested arrays as subTypes
+func NewArray(aType DType, subTypes ...DType) *Array {
 	return &Array{
-		mType: aType,
-		size:  ValidNewUInt32(0),
-		value: make([]DtRefer, 0),
+		mType:    aType,
+		subTypes: subTypes,
+		size:     ValidNewUInt32(0),
+		value:    make([]DtRefer, 0),
 	}
 }
 
+func (p *Array) newItem() DtRefer {
+	if p.mType == ArrayType && len(p.subTypes) > 0 {
+		return NewArray(p.subTypes[0], p.subTypes[1:]...)
+	}
+	return NewDtRefer(p.mType)
+}
+
 func (p *Array) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	sizeArr, _ := p.size.Encode()
@@ -38,12 +50,15 @@ func (p *Array) Decode(buf []byte, offset int) (int, error) {
 	index = index + sizeArr
 
 	length := int(p.size.GetValue().(uint32))
-	if length > 0 {
-		for i := 0; i < length; i++ {
-			dt := NewDtRefer(p.mType)
-			dtLen, _ := dt.Decode(buf, offset+index)
-			index = index + dtLen
+	p.value = make([]DtRefer, length)
+	for i := 0; i < length; i++ {
+		dt := p.newItem()
+		dtLen, err := dt.Decode(buf, offset+index)
+		if err != nil {
+			return 0, err
 		}
+		index = index + dtLen
+		p.value[i] = dt
 	}
 	return index, nil
 }
@@ -64,8 +79,10 @@ func (p *Array) GetLen() int {
 	}
 	return i
 }
+
+// Copy returns an array that shares the items with p
 func (p *Array) Copy() DtRefer {
-	ret := NewArray(p.mType)
+	ret := NewArray(p.mType, p.subTypes...)
 	ret.size.SetValue(p.size.GetValue())
 	length := int(p.size.GetValue().(uint32))
 	if length > 0 {
@@ -77,38 +94,98 @@ func (p *Array) Copy() DtRefer {
 	return ret
 }
 func (p *Array) DeepCopy() DtRefer {
-	ret := NewArray(p.mType)
+	ret := NewArray(p.mType, p.subTypes...)
 	ret.size.SetValue(p.size.GetValue())
 	length := int(p.size.GetValue().(uint32))
 	if length > 0 {
 		ret.value = make([]DtRefer, length)
 		for i := 0; i < length; i++ {
-			ret.value[i] = p.value[i].Copy()
+			ret.value[i] = p.value[i].DeepCopy()
 		}
 	}
 	return ret
 }
 
+// Compare orders the arrays item by item, a prefix is before the longer array
+func (p *Array) Compare(v Comparator) int {
+	o := v.(*Array)
+	for i := 0; i < len(p.value) && i < len(o.value); i++ {
+		if c := p.value[i].Compare(o.value[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(p.value) < len(o.value):
+		return -1
+	case len(p.value) > len(o.value):
+		return 1
+	}
+	return 0
+}
+
 func (p *Array) GetSize() int {
 	return int(p.size.GetValue().(uint32))
 }
 
-func (p *Array) Add(v DtRefer, canEquals bool) bool {
-	if canEquals {
-		p.value = append(p.value, v)
-		return true
-	} else {
-		insert := true
-		for _, item := range p.value {
-			if item.Compare(v) == 0 {
-				insert = false
-				break
-			}
+func (p *Array) GetType() DType {
+	return p.mType
+}
+
+// Get returns the item at i, it panics if i is out of range like a slice
+func (p *Array) Get(i int) DtRefer {
+	return p.value[i]
+}
+
+// Set replaces the item at i
+func (p *Array) Set(i int, v DtRefer) {
+	p.value[i] = v
+}
+
+// Remove deletes the item at i
+func (p *Array) Remove(i int) {
+	p.value = append(p.value[:i], p.value[i+1:]...)
+	p.size.SetValue(uint32(len(p.value)))
+}
+
+// IndexOf returns the index of the first item equals to v, or -1
+func (p *Array) IndexOf(v DtRefer) int {
+	for i, item := range p.value {
+		if item.Compare(v) == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *Array) Contains(v DtRefer) bool {
+	return p.IndexOf(v) >= 0
+}
+
+// ContainsAll reports whether every item of o is in p
+func (p *Array) ContainsAll(o *Array) bool {
+	for _, item := range o.value {
+		if !p.Contains(item) {
+			return false
 		}
-		if insert {
-			p.value = append(p.value, v)
+	}
+	return true
+}
+
+// Overlaps reports whether p and o have an item in common
+func (p *Array) Overlaps(o *Array) bool {
+	for _, item := range o.value {
+		if p.Contains(item) {
 			return true
 		}
+	}
+	return false
+}
+
+func (p *Array) Add(v DtRefer, canEquals bool) bool {
+	if !canEquals && p.Contains(v) {
 		return false
 	}
+	p.value = append(p.value, v)
+	p.size.SetValue(uint32(len(p.value)))
+	return true
 }
@@ -0,0 +1,97 @@
+package dt
+
+import (
+	"testing"
+)
+
+func newInt32Array(values ...int32) *Array {
+	arr := NewArray(Int32Type)
+	for _, v := range values {
+		arr.Add(ValidNewInt32(v), true)
+	}
+	return arr
+}
+
+func TestArray(t *testing.T) {
+	a1 := newInt32Array(3, -1, 7)
+	if a1.GetSize() != 3 {
+		t.Fatal("size should be 3,but ", a1.GetSize())
+	}
+	b1, _ := a1.Encode()
+
+	a2 := NewArray(Int32Type)
+	l, _ := a2.Decode(b1, 0)
+	if l != 4+3*4 || l != a2.GetLen() {
+		t.Fatal("len should be 16,but ", l)
+	}
+	if a2.GetSize() != 3 || a2.Get(1).GetValue().(int32) != -1 || a2.Compare(a1) != 0 {
+		t.Fatal("the items should be decoded")
+	}
+}
+
+func TestArray_Nested(t *testing.T) {
+	meta := NewCellMetaRaw(0, ArrayType, "matrix", "", nil).WithSubType(ArrayType, StringType)
+	m1 := meta.NewCell().(*Array)
+	for _, row := range [][]string{{"a", "b"}, {}, {"c"}} {
+		arr := NewArray(StringType)
+		for _, s := range row {
+			arr.Add(ValidNewString(s), true)
+		}
+		m1.Add(arr, true)
+	}
+	b1, _ := m1.Encode()
+
+	m2 := meta.NewCell().(*Array)
+	m2.Decode(b1, 0)
+	if m2.GetSize() != 3 || m2.Get(2).(*Array).Get(0).GetValue().(string) != "c" {
+		t.Fatal("the nested arrays should be decoded")
+	}
+
+	m3 := m2.DeepCopy().(*Array)
+	m3.Get(0).(*Array).Set(0, ValidNewString("z"))
+	if m2.Get(0).(*Array).Get(0).GetValue().(string) != "a" {
+		t.Fatal("DeepCopy should copy the nested arrays")
+	}
+	m4 := m2.Copy().(*Array)
+	m4.Get(0).(*Array).Set(0, ValidNewString("z"))
+	if m2.Get(0).(*Array).Get(0).GetValue().(string) != "z" {
+		t.Fatal("Copy should share the items")
+	}
+}
+
+func TestArray_Compare(t *testing.T) {
+	values := []*Array{newInt32Array(), newInt32Array(-1), newInt32Array(1), newInt32Array(1, 2), newInt32Array(2)}
+	for i := range values {
+		for j := range values {
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			if values[i].Compare(values[j]) != want {
+				t.Fatal(i, " compare ", j, " should be ", want)
+			}
+		}
+	}
+}
+
+func TestArray_Items(t *testing.T) {
+	arr := newInt32Array(1, 2, 3)
+	if arr.Add(ValidNewInt32(2), false) || arr.GetSize() != 3 {
+		t.Fatal("a duplicated item should not be added")
+	}
+	if arr.IndexOf(ValidNewInt32(3)) != 2 || arr.Contains(ValidNewInt32(9)) {
+		t.Fatal("IndexOf and Contains are wrong")
+	}
+	if !arr.ContainsAll(newInt32Array(3, 1)) || arr.ContainsAll(newInt32Array(1, 9)) {
+		t.Fatal("ContainsAll is wrong")
+	}
+	if !arr.Overlaps(newInt32Array(9, 3)) || arr.Overlaps(newInt32Array(9)) {
+		t.Fatal("Overlaps is wrong")
+	}
+	arr.Remove(0)
+	if arr.GetSize() != 2 || arr.Get(0).GetValue().(int32) != 2 {
+		t.Fatal("the first item should be removed")
+	}
+}
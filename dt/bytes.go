@@ -0,0 +1,195 @@
+package dt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+)
+
+const (
+	bytesInline byte = iota
+	bytesLob
+)
+
+var ErrBytesTooLong = errors.New("bytes too long to be saved inline")
+var ErrNoLobStore = errors.New("a large object needs a LobStore")
+
+// LobStore saves the large objects out of the rows, the rows only keep their ids
+type LobStore interface {
+	Create(r io.Reader) (id uint64, size int64, err error)
+	Open(id uint64) (io.ReadCloser, error)
+	Delete(id uint64) error
+}
+
+// LobRef is the value of a Bytes that is saved as a large object
+type LobRef struct {
+	ID   uint64
+	Size int64
+}
+
+// Bytes is raw binary data. A small value is saved inline in the row, a large
+// one is streamed into a LobStore and the row only saves its LobRef.
+type Bytes struct {
+	DtRefer
+	value []byte
+	lob   *LobRef
+}
+
+func NewBytes() *Bytes {
+	return ValidNewBytes(nil)
+}
+
+func ValidNewBytes(v []byte) *Bytes {
+	return &Bytes{value: v}
+}
+
+// IsLob reports whether the value is saved in a LobStore
+func (p *Bytes) IsLob() bool {
+	return nil != p.lob
+}
+
+// Size returns the length of the value, inline or not
+func (p *Bytes) Size() int64 {
+	if p.IsLob() {
+		return p.lob.Size
+	}
+	return int64(len(p.value))
+}
+
+// WriteFrom streams r into store and makes p refer to it, the old large
+// object of p is not deleted because other copies of the row may use it. A
+// tree deletes it when the row in the tree is replaced, see
+// yard.PageTree.AttachLobStore
+func (p *Bytes) WriteFrom(store LobStore, r io.Reader) error {
+	if nil == store {
+		return ErrNoLobStore
+	}
+	id, size, err := store.Create(r)
+	if err != nil {
+		return err
+	}
+	p.value = nil
+	p.lob = &LobRef{ID: id, Size: size}
+	return nil
+}
+
+// Reader returns the value as a stream, store is only used by a large object
+func (p *Bytes) Reader(store LobStore) (io.ReadCloser, error) {
+	if !p.IsLob() {
+		return ioutil.NopCloser(bytes.NewReader(p.value)), nil
+	}
+	if nil == store {
+		return nil, ErrNoLobStore
+	}
+	return store.Open(p.lob.ID)
+}
+
+// CopyTo copies the value to w without loading a large object into memory
+func (p *Bytes) CopyTo(store LobStore, w io.Writer) (int64, error) {
+	r, err := p.Reader(store)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	return io.Copy(w, r)
+}
+
+// Encode saves a flag, then the length and the bytes, or the LobRef
+func (p *Bytes) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if p.IsLob() {
+		buf.WriteByte(bytesLob)
+		binary.Write(buf, binary.BigEndian, p.lob.ID)
+		binary.Write(buf, binary.BigEndian, uint64(p.lob.Size))
+		return buf.Bytes(), nil
+	}
+	if len(p.value) > math.MaxUint32 {
+		return nil, ErrBytesTooLong
+	}
+	buf.WriteByte(bytesInline)
+	binary.Write(buf, binary.BigEndian, uint32(len(p.value)))
+	buf.Write(p.value)
+	return buf.Bytes(), nil
+}
+
+func (p *Bytes) Decode(buf []byte, offset int) (int, error) {
+	if buf[offset] == bytesLob {
+		p.value = nil
+		p.lob = &LobRef{
+			ID:   binary.BigEndian.Uint64(buf[offset+1 : offset+9]),
+			Size: int64(binary.BigEndian.Uint64(buf[offset+9 : offset+17])),
+		}
+		return 17, nil
+	}
+	size := int(binary.BigEndian.Uint32(buf[offset+1 : offset+5]))
+	p.lob = nil
+	p.value = append([]byte(nil), buf[offset+5:offset+5+size]...)
+	return 5 + size, nil
+}
+
+// SetValue takes a []byte, a string or a LobRef
+func (p *Bytes) SetValue(v ValueRefer) {
+	switch x := v.(type) {
+	case string:
+		p.value, p.lob = []byte(x), nil
+	case LobRef:
+		p.value, p.lob = nil, &x
+	default:
+		p.value, p.lob = v.([]byte), nil
+	}
+}
+
+// GetValue returns the []byte of an inline value or the LobRef of a large object
+func (p *Bytes) GetValue() ValueRefer {
+	if p.IsLob() {
+		return *p.lob
+	}
+	return p.value
+}
+
+func (p *Bytes) GetLen() int {
+	if p.IsLob() {
+		return 17
+	}
+	return 5 + len(p.value)
+}
+
+func (p *Bytes) Copy() DtRefer {
+	ret := NewBytes()
+	ret.value = p.value
+	ret.lob = p.lob
+	return ret
+}
+
+func (p *Bytes) DeepCopy() DtRefer {
+	ret := NewBytes()
+	ret.value = append([]byte(nil), p.value...)
+	if p.IsLob() {
+		lob := *p.lob
+		ret.lob = &lob
+	}
+	return ret
+}
+
+// Compare orders the inline values by bytes and puts the large objects
+// after them in the order of their ids, the content of a large object is
+// never read by Compare
+func (p *Bytes) Compare(v Comparator) int {
+	o := v.(*Bytes)
+	switch {
+	case !p.IsLob() && !o.IsLob():
+		return bytes.Compare(p.value, o.value)
+	case !p.IsLob():
+		return -1
+	case !o.IsLob():
+		return 1
+	case p.lob.ID < o.lob.ID:
+		return -1
+	case p.lob.ID > o.lob.ID:
+		return 1
+	}
+	return 0
+}
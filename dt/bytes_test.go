@@ -0,0 +1,76 @@
+package dt
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// memLobStore keeps the large objects in memory
+type memLobStore struct {
+	objects map[uint64][]byte
+}
+
+func (s *memLobStore) Create(r io.Reader) (uint64, int64, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	id := uint64(len(s.objects) + 1)
+	s.objects[id] = b
+	return id, int64(len(b)), nil
+}
+
+func (s *memLobStore) Open(id uint64) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(s.objects[id])), nil
+}
+
+func (s *memLobStore) Delete(id uint64) error {
+	delete(s.objects, id)
+	return nil
+}
+
+func TestBytes(t *testing.T) {
+	b1 := ValidNewBytes([]byte{0, 1, 2, 0xff})
+	buf, _ := b1.Encode()
+
+	b2 := NewBytes()
+	l, _ := b2.Decode(buf, 0)
+	if l != 5+4 || l != b2.GetLen() {
+		t.Fatal("len should be 9,but ", l)
+	}
+	if !bytes.Equal(b2.GetValue().([]byte), []byte{0, 1, 2, 0xff}) || b2.Compare(b1) != 0 {
+		t.Fatal("the bytes should be decoded")
+	}
+	if ValidNewBytes([]byte("a")).Compare(ValidNewBytes([]byte("ab"))) != -1 {
+		t.Fatal("a should be before ab")
+	}
+}
+
+func TestBytes_Lob(t *testing.T) {
+	store := &memLobStore{objects: make(map[uint64][]byte)}
+	data := bytes.Repeat([]byte("pitydb"), 1000)
+
+	b1 := NewBytes()
+	if err := b1.WriteFrom(nil, bytes.NewReader(data)); err != ErrNoLobStore {
+		t.Fatal("a large object should need a store")
+	}
+	if err := b1.WriteFrom(store, bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if !b1.IsLob() || b1.Size() != int64(len(data)) || b1.GetLen() != 17 {
+		t.Fatal("only the reference should be saved in the row")
+	}
+	buf, _ := b1.Encode()
+
+	b2 := NewBytes()
+	b2.Decode(buf, 0)
+	out := new(bytes.Buffer)
+	if n, err := b2.CopyTo(store, out); err != nil || n != int64(len(data)) || !bytes.Equal(out.Bytes(), data) {
+		t.Fatal("the large object should be read back, ", err)
+	}
+	if ValidNewBytes([]byte{0xff}).Compare(b2) != -1 || b2.Compare(b1) != 0 {
+		t.Fatal("the large objects should be after the inline values")
+	}
+}
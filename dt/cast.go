@@ -0,0 +1,464 @@
+package dt
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrCastType = errors.New("the value can not be cast to the type")
+var ErrCastOverflow = errors.New("the value is out of the range of the type")
+var ErrCastPrecision = errors.New("the value loses its fraction in the type")
+var ErrIncomparable = errors.New("the values can not be compared")
+
+// TypeOf returns the DType of d
+func TypeOf(d DtRefer) (DType, bool) {
+	switch d.(type) {
+	case *Byte:
+		return ByteType, true
+	case *Int32:
+		return Int32Type, true
+	case *UInt32:
+		return UInt32Type, true
+	case *Int64:
+		return Int64Type, true
+	case *UInt64:
+		return UInt64Type, true
+	case *Float32:
+		return Float32Type, true
+	case *Float64:
+		return Float64Type, true
+	case *Bool, *AtomicBool:
+		return BoolType, true
+	case *String:
+		return StringType, true
+	case *Decimal:
+		return DecimalType, true
+	case *Time:
+		return TimeType, true
+	case *Array:
+		return ArrayType, true
+	case *Json:
+		return JsonType, true
+	case *Bytes:
+		return BytesType, true
+	case *UUID:
+		return UUIDType, true
+	case *Enum:
+		return EnumType, true
+	case *Struct:
+		return StructType, true
+	case *Map:
+		return MapType, true
+	}
+	return 0, false
+}
+
+// Cast converts v to the go value that SetValue of typ takes. v is a go value
+// or a DtRefer. A number is cast to another number only if it fits the range
+// and keeps its fraction, a string is parsed and everything except an Array,
+// a Struct, a Map and a large object can be formatted as a string.
+func Cast(v ValueRefer, typ DType) (ValueRefer, error) {
+	if d, ok := v.(DtRefer); ok {
+		switch d.(type) {
+		case *Decimal, *Json, *Array, *Struct, *Map:
+		default:
+			v = d.GetValue()
+		}
+	}
+	switch typ {
+	case ByteType, Int32Type, UInt32Type, Int64Type, UInt64Type:
+		return castInteger(v, typ)
+	case Float32Type, Float64Type:
+		return castFloat(v, typ)
+	case BoolType:
+		return castBool(v)
+	case StringType:
+		return castString(v)
+	case DecimalType:
+		return castDecimal(v)
+	case TimeType:
+		return castTime(v)
+	case JsonType:
+		if j, ok := v.(*Json); ok {
+			return j, nil
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, ErrCastType
+		}
+		j, err := ValidNewJson(string(b))
+		if err != nil {
+			return nil, ErrCastType
+		}
+		return j, nil
+	case BytesType:
+		switch x := v.(type) {
+		case []byte:
+			return x, nil
+		case string:
+			return []byte(x), nil
+		case LobRef:
+			return x, nil
+		}
+	case UUIDType:
+		switch x := v.(type) {
+		case string:
+			u, err := ParseUUID(x)
+			if err != nil {
+				return nil, ErrCastType
+			}
+			return u, nil
+		case [16]byte:
+			return x, nil
+		case []byte:
+			if len(x) == 16 {
+				return x, nil
+			}
+		}
+	case EnumType:
+		//the labels are checked by the cell, see TrySetValue
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+		i, err := castInteger(v, Int32Type)
+		if err != nil {
+			return nil, err
+		}
+		return int(i.(int32)), nil
+	case StructType:
+		switch v.(type) {
+		case *Struct, map[string]ValueRefer:
+			return v, nil
+		}
+	case MapType:
+		if _, ok := v.(*Map); ok || reflect.ValueOf(v).Kind() == reflect.Map {
+			return v, nil
+		}
+	case ArrayType:
+		if a, ok := v.(*Array); ok {
+			return a.GetValue(), nil
+		}
+		if a, ok := v.([]DtRefer); ok {
+			return a, nil
+		}
+	}
+	return nil, ErrCastType
+}
+
+// TrySetValue casts v to the type of cell and sets it, the cell is not
+// changed if an error is returned
+func TrySetValue(cell DtRefer, v ValueRefer) error {
+	switch c := cell.(type) {
+	case *Decimal:
+		d, err := Cast(v, DecimalType)
+		if err != nil {
+			return err
+		}
+		return c.Set(d.(*Decimal))
+	case *Time:
+		if s, ok := v.(string); ok {
+			return c.SetString(s)
+		}
+	case *Enum:
+		x, err := Cast(v, EnumType)
+		if err != nil {
+			return err
+		}
+		if s, ok := x.(string); ok {
+			return c.SetLabel(s)
+		}
+		return c.SetIndex(x.(int))
+	case *Struct:
+		return c.set(v)
+	case *Map:
+		return c.set(v)
+	}
+	typ, ok := TypeOf(cell)
+	if !ok {
+		return ErrCastType
+	}
+	x, err := Cast(v, typ)
+	if err != nil {
+		return err
+	}
+	cell.SetValue(x)
+	return nil
+}
+
+// goNumber turns the go numbers, bool and the numeric strings into a big.Rat,
+// a float that is NaN or infinite is returned as the float
+func goNumber(v ValueRefer) (*big.Rat, float64, error) {
+	r := new(big.Rat)
+	switch x := v.(type) {
+	case int:
+		return r.SetInt64(int64(x)), 0, nil
+	case int8:
+		return r.SetInt64(int64(x)), 0, nil
+	case int16:
+		return r.SetInt64(int64(x)), 0, nil
+	case int32:
+		return r.SetInt64(int64(x)), 0, nil
+	case int64:
+		return r.SetInt64(x), 0, nil
+	case uint:
+		return r.SetUint64(uint64(x)), 0, nil
+	case byte:
+		return r.SetUint64(uint64(x)), 0, nil
+	case uint16:
+		return r.SetUint64(uint64(x)), 0, nil
+	case uint32:
+		return r.SetUint64(uint64(x)), 0, nil
+	case uint64:
+		return r.SetUint64(x), 0, nil
+	case float32:
+		return floatNumber(float64(x))
+	case float64:
+		return floatNumber(x)
+	case bool:
+		if x {
+			return r.SetInt64(1), 0, nil
+		}
+		return r, 0, nil
+	case *Decimal:
+		return r.SetFrac(x.unscaled, pow10(x.scale)), 0, nil
+	case string:
+		s := strings.TrimSpace(x)
+		if f, err := strconv.ParseFloat(s, 64); err == nil && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			return nil, f, nil
+		}
+		d, err := ValidNewDecimal(s)
+		if err != nil {
+			return nil, 0, ErrCastType
+		}
+		return r.SetFrac(d.unscaled, pow10(d.scale)), 0, nil
+	}
+	return nil, 0, ErrCastType
+}
+
+func floatNumber(f float64) (*big.Rat, float64, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, f, nil
+	}
+	return new(big.Rat).SetFloat64(f), 0, nil
+}
+
+var integerRanges = map[DType][2]*big.Int{
+	ByteType:   {big.NewInt(0), big.NewInt(math.MaxUint8)},
+	Int32Type:  {big.NewInt(math.MinInt32), big.NewInt(math.MaxInt32)},
+	UInt32Type: {big.NewInt(0), big.NewInt(math.MaxUint32)},
+	Int64Type:  {big.NewInt(math.MinInt64), big.NewInt(math.MaxInt64)},
+	UInt64Type: {big.NewInt(0), new(big.Int).SetUint64(math.MaxUint64)},
+}
+
+func castInteger(v ValueRefer, typ DType) (ValueRefer, error) {
+	r, f, err := goNumber(v)
+	if err != nil {
+		return nil, err
+	}
+	if nil == r {
+		if math.IsNaN(f) {
+			return nil, ErrCastType
+		}
+		return nil, ErrCastOverflow
+	}
+	if !r.IsInt() {
+		return nil, ErrCastPrecision
+	}
+	n := r.Num()
+	limits := integerRanges[typ]
+	if n.Cmp(limits[0]) < 0 || n.Cmp(limits[1]) > 0 {
+		return nil, ErrCastOverflow
+	}
+	switch typ {
+	case ByteType:
+		return byte(n.Uint64()), nil
+	case Int32Type:
+		return int32(n.Int64()), nil
+	case UInt32Type:
+		return uint32(n.Uint64()), nil
+	case Int64Type:
+		return n.Int64(), nil
+	}
+	return n.Uint64(), nil
+}
+
+func castFloat(v ValueRefer, typ DType) (ValueRefer, error) {
+	r, f, err := goNumber(v)
+	if err != nil {
+		return nil, err
+	}
+	if nil != r {
+		if typ == Float32Type {
+			f32, _ := r.Float32()
+			if math.IsInf(float64(f32), 0) {
+				return nil, ErrCastOverflow
+			}
+			return f32, nil
+		}
+		f, _ = r.Float64()
+		if math.IsInf(f, 0) {
+			return nil, ErrCastOverflow
+		}
+	}
+	if typ == Float32Type {
+		return float32(f), nil
+	}
+	return f, nil
+}
+
+func castBool(v ValueRefer) (ValueRefer, error) {
+	switch x := v.(type) {
+	case bool:
+		return x, nil
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(x))
+		if err != nil {
+			return nil, ErrCastType
+		}
+		return b, nil
+	}
+	r, _, err := goNumber(v)
+	if err != nil || nil == r {
+		return nil, ErrCastType
+	}
+	switch r.Sign() {
+	case 0:
+		return false, nil
+	case 1:
+		if r.IsInt() && r.Num().IsInt64() && r.Num().Int64() == 1 {
+			return true, nil
+		}
+	}
+	return nil, ErrCastType
+}
+
+func castString(v ValueRefer) (ValueRefer, error) {
+	switch x := v.(type) {
+	case string:
+		return x, nil
+	case []byte:
+		return string(x), nil
+	case bool:
+		return strconv.FormatBool(x), nil
+	case float32:
+		return strconv.FormatFloat(float64(x), 'g', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(x, 'g', -1, 64), nil
+	case time.Time:
+		return x.Format(time.RFC3339Nano), nil
+	case *Decimal:
+		return x.String(), nil
+	case *Json:
+		return x.String(), nil
+	}
+	if r, _, err := goNumber(v); err == nil && nil != r {
+		return r.Num().String(), nil
+	}
+	return nil, ErrCastType
+}
+
+func castDecimal(v ValueRefer) (ValueRefer, error) {
+	switch x := v.(type) {
+	case *Decimal:
+		return x, nil
+	case float32:
+		return castDecimal(strconv.FormatFloat(float64(x), 'g', -1, 32))
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return nil, ErrCastOverflow
+		}
+		return castDecimal(strconv.FormatFloat(x, 'g', -1, 64))
+	case string:
+		d, err := ValidNewDecimal(x)
+		if err != nil {
+			return nil, ErrCastType
+		}
+		return d, nil
+	}
+	r, _, err := goNumber(v)
+	if err != nil {
+		return nil, err
+	}
+	return &Decimal{unscaled: new(big.Int).Set(r.Num())}, nil
+}
+
+func castTime(v ValueRefer) (ValueRefer, error) {
+	switch x := v.(type) {
+	case time.Time:
+		return x, nil
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, DateLayout, TimeOfDayLayout} {
+			if t, err := time.Parse(layout, strings.TrimSpace(x)); err == nil {
+				return t, nil
+			}
+		}
+		return nil, ErrCastType
+	case int64:
+		return time.Unix(0, x).UTC(), nil
+	}
+	return nil, ErrCastType
+}
+
+func isNumeric(d Comparator) bool {
+	switch d.(type) {
+	case *Byte, *Int32, *UInt32, *Int64, *UInt64, *Float32, *Float64, *Decimal:
+		return true
+	}
+	return false
+}
+
+// compareNumeric compares two numbers of any numeric types exactly,
+// NaN is after all the numbers like in Float64.Compare
+func compareNumeric(a DtRefer, b DtRefer) int {
+	var ra, rb *big.Rat
+	var fa, fb float64
+	if d, ok := a.(*Decimal); ok {
+		ra, _, _ = goNumber(d)
+	} else {
+		ra, fa, _ = goNumber(a.GetValue())
+	}
+	if d, ok := b.(*Decimal); ok {
+		rb, _, _ = goNumber(d)
+	} else {
+		rb, fb, _ = goNumber(b.GetValue())
+	}
+	if nil == ra || nil == rb {
+		//one of them is NaN or infinite
+		nanA, nanB := nil == ra && math.IsNaN(fa), nil == rb && math.IsNaN(fb)
+		if nanA || nanB {
+			return compareNaN(nanA, nanB)
+		}
+		if nil != ra {
+			fa, _ = ra.Float64()
+		}
+		if nil != rb {
+			fb, _ = rb.Float64()
+		}
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	return ra.Cmp(rb)
+}
+
+// CompareValues compares a and b like a.Compare(b), but it returns
+// ErrIncomparable instead of panicking when the types do not match
+func CompareValues(a DtRefer, b DtRefer) (int, error) {
+	if isNumeric(a) && isNumeric(b) {
+		return compareNumeric(a, b), nil
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return 0, ErrIncomparable
+	}
+	return a.Compare(b), nil
+}
@@ -0,0 +1,135 @@
+package dt
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCast(t *testing.T) {
+	cases := []struct {
+		value ValueRefer
+		typ   DType
+		want  ValueRefer
+		err   error
+	}{
+		{int(7), Int32Type, int32(7), nil},
+		{int64(-1), UInt32Type, nil, ErrCastOverflow},
+		{uint64(math.MaxUint64), Int64Type, nil, ErrCastOverflow},
+		{float64(3), Int64Type, int64(3), nil},
+		{float64(3.5), Int64Type, nil, ErrCastPrecision},
+		{math.Inf(1), Int64Type, nil, ErrCastOverflow},
+		{" 255 ", ByteType, byte(255), nil},
+		{"256", ByteType, nil, ErrCastOverflow},
+		{"1e3", UInt64Type, uint64(1000), nil},
+		{"abc", Int32Type, nil, ErrCastType},
+		{true, Int32Type, int32(1), nil},
+		{int32(2), Float64Type, float64(2), nil},
+		{float64(1e300), Float32Type, nil, ErrCastOverflow},
+		{"NaN", Float64Type, math.NaN(), nil},
+		{int(1), BoolType, true, nil},
+		{int(2), BoolType, nil, ErrCastType},
+		{"false", BoolType, false, nil},
+		{int64(-12), StringType, "-12", nil},
+		{float64(0.1), StringType, "0.1", nil},
+		{ValidInt64(5), Float32Type, float32(5), nil},
+		{ValidNewString("12"), Int64Type, int64(12), nil},
+		{[]int{1}, Int64Type, nil, ErrCastType},
+		{NewArray(Int32Type), StringType, nil, ErrCastType},
+		{NewStruct(NewRowMeta()), StringType, nil, ErrCastType},
+		{NewMap(StringType, Int32Type), StringType, nil, ErrCastType},
+		{LobRef{ID: 1, Size: 10}, StringType, nil, ErrCastType},
+		{&Bytes{lob: &LobRef{ID: 1, Size: 10}}, StringType, nil, ErrCastType},
+	}
+	for _, c := range cases {
+		got, err := Cast(c.value, c.typ)
+		if err != c.err {
+			t.Fatal(c.value, " cast to ", c.typ, " should fail with ", c.err, ",but ", err)
+		}
+		if f, ok := c.want.(float64); ok && f != f {
+			if g, ok := got.(float64); !ok || g == g {
+				t.Fatal(c.value, " cast to ", c.typ, " should be NaN,but ", got)
+			}
+			continue
+		}
+		if err == nil && got != c.want {
+			t.Fatal(c.value, " cast to ", c.typ, " should be ", c.want, ",but ", got)
+		}
+	}
+
+	d, err := Cast(float64(0.1), DecimalType)
+	if err != nil || d.(*Decimal).String() != "0.1" {
+		t.Fatal("0.1 should be cast to the decimal 0.1,but ", d, err)
+	}
+	tm, err := Cast("2017-03-04", TimeType)
+	if err != nil || !tm.(time.Time).Equal(time.Date(2017, 3, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Fatal("2017-03-04 should be cast to a time,but ", tm, err)
+	}
+	j, err := Cast(map[string]int{"a": 1}, JsonType)
+	if err != nil || j.(*Json).String() != `{"a":1}` {
+		t.Fatal("a map should be cast to json,but ", j, err)
+	}
+}
+
+func TestTrySetValue(t *testing.T) {
+	i := NewInt32()
+	if err := TrySetValue(i, "12"); err != nil || i.GetValue().(int32) != 12 {
+		t.Fatal("12 should be set,but ", i.GetValue(), err)
+	}
+	if err := TrySetValue(i, 1.5); err != ErrCastPrecision || i.GetValue().(int32) != 12 {
+		t.Fatal("1.5 should not be set,but ", i.GetValue(), err)
+	}
+	d := NewDecimalWithPrecision(4, 2)
+	if err := TrySetValue(d, 12.345); err != nil || d.String() != "12.35" {
+		t.Fatal("12.345 should be set as 12.35,but ", d, err)
+	}
+	if err := TrySetValue(d, 100); err != ErrDecimalOverflow {
+		t.Fatal("100 should overflow decimal(4,2),but ", err)
+	}
+	tm := NewTimeWithKind(TimeOfDayKind)
+	if err := TrySetValue(tm, "10:11:12"); err != nil || tm.String() != "10:11:12" {
+		t.Fatal("10:11:12 should be set,but ", tm, err)
+	}
+}
+
+func TestCompareNumeric(t *testing.T) {
+	d, _ := ValidNewDecimal("2.5")
+	values := []DtRefer{ValidNewFloat64(math.Inf(-1)), ValidInt64(math.MinInt64), ValidNewInt32(-1),
+		ValidNewByte(0), ValidNewFloat32(0.5), ValidNewUInt32(2), d, ValidInt64(math.MaxInt64),
+		ValidNewUInt64(math.MaxInt64 + 1), ValidNewFloat64(math.Inf(1)), ValidNewFloat32(float32(math.NaN()))}
+	for i := range values {
+		for j := range values {
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			if values[i].Compare(values[j]) != want {
+				t.Fatal(i, " compare ", j, " should be ", want)
+			}
+			if c, err := CompareValues(values[i], values[j]); err != nil || c != want {
+				t.Fatal(i, " compare ", j, " should be ", want)
+			}
+		}
+	}
+	if ValidNewInt32(3).Compare(ValidNewFloat64(3)) != 0 {
+		t.Fatal("3 should equal 3.0")
+	}
+	if _, err := CompareValues(ValidNewInt32(1), ValidNewString("1")); err != ErrIncomparable {
+		t.Fatal("an int32 and a string should not be compared")
+	}
+	if _, err := CompareValues(ValidNewBool(true), ValidNewAtomicBool(true)); err != ErrIncomparable {
+		t.Fatal("a bool and an atomic bool should not be compared")
+	}
+	for _, v := range values {
+		func() {
+			defer func() {
+				if nil == recover() {
+					t.Fatal("a number compared with a string should panic")
+				}
+			}()
+			v.Compare(ValidNewString("1"))
+		}()
+	}
+}
@@ -0,0 +1,123 @@
+package dt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Collation decides the order of the String values
+type Collation byte
+
+const (
+	CollateNoCase  Collation = iota //the folded runes, "a" equals "A"
+	CollateBinary                   //the bytes of the utf-8 encoding
+	CollateUnicode                  //the letters, then the accents, then the case, "a" < "A" < "á" < "b"
+)
+
+// latinBases maps the accented latin letters to the letters they are built on
+var latinBases = map[rune]rune{}
+
+func init() {
+	for base, letters := range map[rune]string{
+		'a': "àáâãäåāăą", 'c': "çćĉċč", 'd': "ďđ", 'e': "èéêëēĕėęě",
+		'g': "ĝğġģ", 'h': "ĥħ", 'i': "ìíîïĩīĭįı", 'j': "ĵ", 'k': "ķ",
+		'l': "ĺļľŀł", 'n': "ñńņňŉ", 'o': "òóôõöøōŏő", 'r': "ŕŗř",
+		's': "śŝşš", 't': "ţťŧ", 'u': "ùúûüũūŭůűų", 'w': "ŵ", 'y': "ýÿŷ", 'z': "źżž",
+	} {
+		for _, r := range letters {
+			latinBases[r] = base
+		}
+	}
+}
+
+// foldRune returns the smallest rune that equals r by unicode case folding,
+// two strings are strings.EqualFold if and only if their folded runes are the same
+func foldRune(r rune) rune {
+	min := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < min {
+			min = f
+		}
+	}
+	return min
+}
+
+func compareNoCase(a string, b string) int {
+	for a != "" && b != "" {
+		ra, la := utf8.DecodeRuneInString(a)
+		rb, lb := utf8.DecodeRuneInString(b)
+		fa, fb := foldRune(ra), foldRune(rb)
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		a, b = a[la:], b[lb:]
+	}
+	switch {
+	case a == "" && b == "":
+		return 0
+	case a == "":
+		return -1
+	}
+	return 1
+}
+
+// collationKey returns the bytes that sort in the order of the collation,
+// so an index that only compares bytes honors the collation
+func collationKey(c Collation, s string) []byte {
+	switch c {
+	case CollateBinary:
+		return []byte(s)
+	case CollateUnicode:
+		return unicodeKey(s)
+	}
+	buf := new(bytes.Buffer)
+	for _, r := range s {
+		buf.WriteRune(foldRune(r))
+	}
+	return buf.Bytes()
+}
+
+// unicodeKey saves the letters, the accents and the case of every rune as
+// three levels, every level ends with a zero and the string itself breaks ties
+func unicodeKey(s string) []byte {
+	letters, accents, cases := new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer)
+	for _, r := range s {
+		lower := unicode.ToLower(r)
+		base, ok := latinBases[lower]
+		if !ok {
+			base = lower
+		}
+		binary.Write(letters, binary.BigEndian, uint32(base)+1)
+		binary.Write(accents, binary.BigEndian, uint32(lower)+1)
+		if lower == r {
+			cases.WriteByte(1)
+		} else {
+			cases.WriteByte(2)
+		}
+	}
+	key := new(bytes.Buffer)
+	key.Write(letters.Bytes())
+	key.Write([]byte{0, 0, 0, 0})
+	key.Write(accents.Bytes())
+	key.Write([]byte{0, 0, 0, 0})
+	key.Write(cases.Bytes())
+	key.WriteByte(0)
+	key.WriteString(s)
+	return key.Bytes()
+}
+
+func compareCollated(c Collation, a string, b string) int {
+	switch c {
+	case CollateBinary:
+		return strings.Compare(a, b)
+	case CollateUnicode:
+		return bytes.Compare(unicodeKey(a), unicodeKey(b))
+	}
+	return compareNoCase(a, b)
+}
@@ -0,0 +1,70 @@
+package dt
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+)
+
+func sortStrings(c Collation, values []string) []string {
+	cells := make([]*String, len(values))
+	for i, v := range values {
+		cells[i] = NewStringWithCollation(c)
+		cells[i].SetValue(v)
+	}
+	sort.SliceStable(cells, func(i, j int) bool {
+		return cells[i].Compare(cells[j]) < 0
+	})
+	ret := make([]string, len(cells))
+	for i, cell := range cells {
+		ret[i] = cell.value
+	}
+	return ret
+}
+
+func TestCollation(t *testing.T) {
+	values := []string{"b", "á", "A", "a", "B", "ab", ""}
+	cases := map[Collation][]string{
+		CollateBinary:  {"", "A", "B", "a", "ab", "b", "á"},
+		CollateNoCase:  {"", "A", "a", "ab", "b", "B", "á"},
+		CollateUnicode: {"", "a", "A", "á", "ab", "b", "B"},
+	}
+	for c, want := range cases {
+		got := sortStrings(c, values)
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatal("collation ", c, " should sort ", want, ",but ", got)
+			}
+		}
+	}
+	if ValidNewString("Straße").Compare(ValidNewString("STRASSE")) == 0 {
+		t.Fatal("the simple case folding does not expand ß")
+	}
+	if ValidNewString("Kelvin").Compare(ValidNewString("Kelvin")) != 0 {
+		t.Fatal("the kelvin sign should equal k")
+	}
+}
+
+func TestCollationKey(t *testing.T) {
+	values := []string{"", "a", "A", "á", "ab", "b", "B", "Ł", "z", "日本"}
+	for _, c := range []Collation{CollateBinary, CollateNoCase, CollateUnicode} {
+		for _, x := range values {
+			for _, y := range values {
+				a, b := NewStringWithCollation(c), NewStringWithCollation(c)
+				a.SetValue(x)
+				b.SetValue(y)
+				if a.Compare(b) != bytes.Compare(a.CollationKey(), b.CollationKey()) {
+					t.Fatal("the key of ", x, " and ", y, " should compare like the strings in collation ", c)
+				}
+			}
+		}
+	}
+}
+
+func TestCollationCellMeta(t *testing.T) {
+	meta := NewCellMetaRaw(0, StringType, "name", "", "x").WithCollation(CollateBinary)
+	cell := meta.NewCell().(*String)
+	if cell.GetCollation() != CollateBinary || cell.Copy().(*String).GetCollation() != CollateBinary {
+		t.Fatal("the cell should keep the collation of the meta")
+	}
+}
@@ -0,0 +1,144 @@
+package dt
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrConstraintExists = errors.New("constraint already exists")
+
+// CheckValidator parses the condition of a CHECK and checks it against the
+// cells of meta. It is set by the expr package, which dt can not import, and
+// nothing is checked if it is not imported.
+var CheckValidator func(meta *RowMeta, check string) error
+
+type ConstraintKind byte
+
+const (
+	NotNullConstraint ConstraintKind = iota
+	UniqueConstraint
+	CheckConstraint
+)
+
+var constraintNames = []string{"NOT NULL", "UNIQUE", "CHECK"}
+
+func (k ConstraintKind) String() string {
+	return constraintNames[k]
+}
+
+// Constraint is a rule that every row of a RowMeta keeps
+type Constraint struct {
+	Name    string
+	Kind    ConstraintKind
+	Columns []string //the cells of NOT NULL and UNIQUE
+	Check   string   //the condition of CHECK, a row is refused only if it is false, see the expr package
+}
+
+// WithNotNull refuses the rows without the cell, the constraint is named "<name>_not_null"
+func (s *CellMeta) WithNotNull() *CellMeta {
+	s.notNull = true
+	return s
+}
+
+func (s *CellMeta) IsNotNull() bool {
+	return s.notNull
+}
+
+// WithUnique refuses two rows of the same cell, the constraint is named "<name>_unique"
+func (s *CellMeta) WithUnique() *CellMeta {
+	s.unique = true
+	return s
+}
+
+func (s *CellMeta) IsUnique() bool {
+	return s.unique
+}
+
+// WithCheck refuses the rows that make check false, the constraint is named "<name>_check"
+func (s *CellMeta) WithCheck(check string) *CellMeta {
+	s.check = check
+	return s
+}
+
+func (s *CellMeta) GetCheck() string {
+	return s.check
+}
+
+// AddUnique refuses two rows of the same columns, the rows with a NULL column are never the same.
+// The index of the columns is kept in memory and built by a scan of the table after it is opened.
+func (meta *RowMeta) AddUnique(name string, columns ...string) error {
+	return meta.addConstraint(&Constraint{Name: name, Kind: UniqueConstraint, Columns: columns})
+}
+
+// AddCheck refuses the rows that make check false, a check that does not
+// parse or is not a bool of the cells is refused at once, see CheckValidator
+func (meta *RowMeta) AddCheck(name string, check string) error {
+	if nil != CheckValidator {
+		if err := CheckValidator(meta, check); err != nil {
+			return fmt.Errorf("constraint %q: %v", name, err)
+		}
+	}
+	return meta.addConstraint(&Constraint{Name: name, Kind: CheckConstraint, Check: check})
+}
+
+// ValidateChecks returns the error of the first CHECK that CheckValidator
+// refuses. The check of a cell is only validated here, it is declared before
+// the RowMeta has the other cells.
+func (meta *RowMeta) ValidateChecks() error {
+	if nil == CheckValidator {
+		return nil
+	}
+	for _, c := range meta.GetConstraints() {
+		if c.Kind != CheckConstraint {
+			continue
+		}
+		if err := CheckValidator(meta, c.Check); err != nil {
+			return fmt.Errorf("constraint %q: %v", c.Name, err)
+		}
+	}
+	return nil
+}
+
+func (meta *RowMeta) addConstraint(c *Constraint) error {
+	for _, o := range meta.GetConstraints() {
+		if o.Name == c.Name {
+			return ErrConstraintExists
+		}
+	}
+	meta.constraints = append(meta.constraints, c)
+	return nil
+}
+
+// DropConstraint removes a constraint added to the RowMeta, the constraints
+// of the cells are removed from the CellMeta
+func (meta *RowMeta) DropConstraint(name string) bool {
+	for i, c := range meta.constraints {
+		if c.Name == name {
+			meta.constraints = append(meta.constraints[:i], meta.constraints[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// GetConstraints returns the constraints of the cells in the order of the
+// cells, then the constraints added to the RowMeta
+func (meta *RowMeta) GetConstraints() []*Constraint {
+	var ret []*Constraint
+	for _, item := range meta.items {
+		if nil == item {
+			continue
+		}
+		name := item.GetName()
+		if item.notNull {
+			ret = append(ret, &Constraint{Name: name + "_not_null", Kind: NotNullConstraint, Columns: []string{name}})
+		}
+		if item.unique {
+			ret = append(ret, &Constraint{Name: name + "_unique", Kind: UniqueConstraint, Columns: []string{name}})
+		}
+		if item.check != "" {
+			ret = append(ret, &Constraint{Name: name + "_check", Kind: CheckConstraint, Check: item.check})
+		}
+	}
+	return append(ret, meta.constraints...)
+}
@@ -0,0 +1,46 @@
+package dt
+
+import "testing"
+
+func TestRowMeta_GetConstraints(t *testing.T) {
+	meta := NewRowMeta()
+	meta.AddCellMeta(NewCellMetaRaw(0, Int32Type, "id", "", nil).WithNotNull().WithUnique())
+	meta.AddCellMeta(NewCellMetaRaw(1, Int32Type, "qty", "", nil).WithCheck("qty >= 0"))
+	meta.AddCellMeta(NewCellMetaRaw(2, StringType, "sku", "", nil))
+
+	if err := meta.AddUnique("id_sku", "id", "sku"); err != nil {
+		t.Fatal(err)
+	}
+	if err := meta.AddCheck("qty_unique", "qty < 10"); err != nil {
+		t.Fatal(err)
+	}
+	if err := meta.AddCheck("id_unique", "id > 0"); err != ErrConstraintExists {
+		t.Fatal("the name is used by the cell")
+	}
+
+	var names []string
+	for _, c := range meta.GetConstraints() {
+		names = append(names, c.Kind.String()+" "+c.Name)
+	}
+	want := "[NOT NULL id_not_null UNIQUE id_unique CHECK qty_check UNIQUE id_sku CHECK qty_unique]"
+	if s := sprint(names); s != want {
+		t.Fatal("should be ", want, ",but ", s)
+	}
+	if !meta.DropConstraint("id_sku") || meta.DropConstraint("id_unique") {
+		t.Fatal("only the constraints of the RowMeta are dropped")
+	}
+	if meta.GetCellMeta("sku").GetPos() != 2 || nil != meta.GetCellMeta("x") {
+		t.Fatal("the cell should be found by name")
+	}
+}
+
+func sprint(strs []string) string {
+	s := "["
+	for i, str := range strs {
+		if i > 0 {
+			s += " "
+		}
+		s += str
+	}
+	return s + "]"
+}
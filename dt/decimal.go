@@ -0,0 +1,353 @@
+package dt
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// RoundingMode decides how the dropped digits of a Decimal are rounded
+type RoundingMode int
+
+const (
+	RoundHalfUp   RoundingMode = iota //0.5 -> 1, -0.5 -> -1
+	RoundHalfEven                     //0.5 -> 0, 1.5 -> 2, the banker's rounding
+	RoundDown                         //toward zero
+	RoundUp                           //away from zero
+	RoundFloor                        //toward negative infinity
+	RoundCeiling                      //toward positive infinity
+)
+
+// MaxDecimalScale is the largest scale that can be encoded
+const MaxDecimalScale = 127
+
+// maxDecimalDigits is the largest power of ten that fits in the 127 bytes
+// Encode allows
+const maxDecimalDigits = 305
+
+var ErrDecimalOverflow = errors.New("decimal overflows its precision")
+var ErrDecimalSyntax = errors.New("invalid decimal")
+var ErrDivisionByZero = errors.New("division by zero")
+
+var bigTen = big.NewInt(10)
+
+// Decimal is an exact fixed-point number, the value is unscaled * 10^-scale.
+// A Decimal made by a CellMeta keeps the declared precision and scale,
+// the values set to it are rounded to the scale by RoundHalfUp.
+type Decimal struct {
+	DtRefer
+	unscaled  *big.Int
+	scale     int32
+	precision int32 //the max number of digits, 0 means no limit
+}
+
+func NewDecimal() *Decimal {
+	return NewDecimalWithPrecision(0, 0)
+}
+
+// NewDecimalWithPrecision returns a zero Decimal(precision, scale)
+func NewDecimalWithPrecision(precision int, scale int) *Decimal {
+	return &Decimal{
+		unscaled:  new(big.Int),
+		scale:     int32(scale),
+		precision: int32(precision),
+	}
+}
+
+// ValidNewDecimal parses s, for example "-12.345" or "1.5e3"
+func ValidNewDecimal(s string) (*Decimal, error) {
+	unscaled, scale, err := parseDecimal(s)
+	if err != nil {
+		return nil, err
+	}
+	return &Decimal{unscaled: unscaled, scale: scale}, nil
+}
+
+// DecimalFromInt64 returns unscaled * 10^-scale
+func DecimalFromInt64(unscaled int64, scale int) *Decimal {
+	return &Decimal{unscaled: big.NewInt(unscaled), scale: int32(scale)}
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+func parseDecimal(s string) (*big.Int, int32, error) {
+	s = strings.TrimSpace(s)
+	exp := int64(0)
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, ok := new(big.Int).SetString(s[i+1:], 10)
+		if !ok {
+			return nil, 0, ErrDecimalSyntax
+		}
+		//the exponents out of int32 are refused before scale is computed
+		if !e.IsInt64() || e.Int64() > math.MaxInt32 {
+			return nil, 0, ErrDecimalOverflow
+		}
+		if e.Int64() < math.MinInt32 {
+			return nil, 0, ErrDecimalSyntax
+		}
+		exp = e.Int64()
+		s = s[:i]
+	}
+	sign := ""
+	if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
+		sign, s = s[:1], s[1:]
+	}
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+	digits := intPart + fracPart
+	if digits == "" || strings.IndexFunc(digits, func(r rune) bool { return r < '0' || r > '9' }) >= 0 {
+		return nil, 0, ErrDecimalSyntax
+	}
+	unscaled, _ := new(big.Int).SetString(sign+digits, 10)
+	scale := int64(len(fracPart)) - exp
+	if scale < 0 {
+		if -scale > maxDecimalDigits {
+			return nil, 0, ErrDecimalOverflow
+		}
+		unscaled.Mul(unscaled, pow10(int32(-scale)))
+		scale = 0
+	}
+	if scale > MaxDecimalScale {
+		return nil, 0, ErrDecimalSyntax
+	}
+	return unscaled, int32(scale), nil
+}
+
+// divRound returns num / den rounded by mode
+func divRound(num *big.Int, den *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+	sign := int64(num.Sign() * den.Sign())
+	half := new(big.Int).Abs(r)
+	half.Lsh(half, 1)
+	cmp := half.Cmp(new(big.Int).Abs(den))
+
+	away := false
+	switch mode {
+	case RoundDown:
+	case RoundUp:
+		away = true
+	case RoundFloor:
+		away = sign < 0
+	case RoundCeiling:
+		away = sign > 0
+	case RoundHalfUp:
+		away = cmp >= 0
+	case RoundHalfEven:
+		away = cmp > 0 || (cmp == 0 && q.Bit(0) == 1)
+	}
+	if away {
+		q.Add(q, big.NewInt(sign))
+	}
+	return q
+}
+
+// rescaled returns the unscaled value at a scale not smaller than the scale of p
+func (p *Decimal) rescaled(scale int32) *big.Int {
+	return new(big.Int).Mul(p.unscaled, pow10(scale-p.scale))
+}
+
+// Round returns the value with scale digits after the point
+func (p *Decimal) Round(scale int, mode RoundingMode) *Decimal {
+	s := int32(scale)
+	if s >= p.scale {
+		return &Decimal{unscaled: p.rescaled(s), scale: s}
+	}
+	return &Decimal{unscaled: divRound(p.unscaled, pow10(p.scale-s), mode), scale: s}
+}
+
+func (p *Decimal) Add(o *Decimal) *Decimal {
+	scale := maxInt32(p.scale, o.scale)
+	return &Decimal{unscaled: new(big.Int).Add(p.rescaled(scale), o.rescaled(scale)), scale: scale}
+}
+
+func (p *Decimal) Sub(o *Decimal) *Decimal {
+	scale := maxInt32(p.scale, o.scale)
+	return &Decimal{unscaled: new(big.Int).Sub(p.rescaled(scale), o.rescaled(scale)), scale: scale}
+}
+
+func (p *Decimal) Mul(o *Decimal) *Decimal {
+	return &Decimal{unscaled: new(big.Int).Mul(p.unscaled, o.unscaled), scale: p.scale + o.scale}
+}
+
+// Div returns p / o with scale digits after the point rounded by mode
+func (p *Decimal) Div(o *Decimal, scale int, mode RoundingMode) (*Decimal, error) {
+	if o.unscaled.Sign() == 0 {
+		return nil, ErrDivisionByZero
+	}
+	//p.unscaled * 10^shift / o.unscaled has the wanted scale
+	shift := int32(scale) + o.scale - p.scale
+	num, den := new(big.Int).Set(p.unscaled), new(big.Int).Set(o.unscaled)
+	if shift >= 0 {
+		num.Mul(num, pow10(shift))
+	} else {
+		den.Mul(den, pow10(-shift))
+	}
+	return &Decimal{unscaled: divRound(num, den, mode), scale: int32(scale)}, nil
+}
+
+// Mod returns the remainder of p / o, it has the sign of p
+func (p *Decimal) Mod(o *Decimal) (*Decimal, error) {
+	if o.unscaled.Sign() == 0 {
+		return nil, ErrDivisionByZero
+	}
+	scale := maxInt32(p.scale, o.scale)
+	return &Decimal{unscaled: new(big.Int).Rem(p.rescaled(scale), o.rescaled(scale)), scale: scale}, nil
+}
+
+func (p *Decimal) Neg() *Decimal {
+	return &Decimal{unscaled: new(big.Int).Neg(p.unscaled), scale: p.scale}
+}
+
+func (p *Decimal) Sign() int {
+	return p.unscaled.Sign()
+}
+
+func (p *Decimal) Scale() int {
+	return int(p.scale)
+}
+
+func (p *Decimal) Precision() int {
+	return int(p.precision)
+}
+
+func (p *Decimal) Float64() float64 {
+	f, _ := new(big.Rat).SetFrac(p.unscaled, pow10(p.scale)).Float64()
+	return f
+}
+
+func (p *Decimal) String() string {
+	digits := new(big.Int).Abs(p.unscaled).String()
+	sign := ""
+	if p.unscaled.Sign() < 0 {
+		sign = "-"
+	}
+	if p.scale == 0 {
+		return sign + digits
+	}
+	if len(digits) <= int(p.scale) {
+		digits = strings.Repeat("0", int(p.scale)-len(digits)+1) + digits
+	}
+	point := len(digits) - int(p.scale)
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// Set stores the value of o rounded to the scale of p
+func (p *Decimal) Set(o *Decimal) error {
+	r := o.Round(int(p.scale), RoundHalfUp)
+	if p.precision > 0 && len(new(big.Int).Abs(r.unscaled).String()) > int(p.precision) {
+		return ErrDecimalOverflow
+	}
+	if p.precision == 0 && p.scale == 0 {
+		//a Decimal without a declared type keeps the scale of the value
+		r = o
+	}
+	p.unscaled = new(big.Int).Set(r.unscaled)
+	p.scale = r.scale
+	return nil
+}
+
+func (p *Decimal) SetString(s string) error {
+	o, err := ValidNewDecimal(s)
+	if err != nil {
+		return err
+	}
+	return p.Set(o)
+}
+
+// Encode saves the scale, the signed length of the magnitude and the magnitude
+func (p *Decimal) Encode() ([]byte, error) {
+	mag := new(big.Int).Abs(p.unscaled).Bytes()
+	if len(mag) > 127 || p.scale > MaxDecimalScale || p.scale < 0 {
+		return nil, ErrDecimalOverflow
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteByte(byte(p.scale))
+	size := int8(len(mag))
+	if p.unscaled.Sign() < 0 {
+		size = -size
+	}
+	buf.WriteByte(byte(size))
+	buf.Write(mag)
+	return buf.Bytes(), nil
+}
+
+func (p *Decimal) Decode(buf []byte, offset int) (int, error) {
+	p.scale = int32(buf[offset])
+	size := int8(buf[offset+1])
+	n := int(size)
+	if n < 0 {
+		n = -n
+	}
+	p.unscaled = new(big.Int).SetBytes(buf[offset+2 : offset+2+n])
+	if size < 0 {
+		p.unscaled.Neg(p.unscaled)
+	}
+	return 2 + n, nil
+}
+
+// SetValue takes a string, *Decimal, int, int32 or int64, it panics
+// if the value can not be held by the declared precision
+func (p *Decimal) SetValue(v ValueRefer) {
+	var err error
+	switch x := v.(type) {
+	case string:
+		err = p.SetString(x)
+	case *Decimal:
+		err = p.Set(x)
+	case int:
+		err = p.Set(DecimalFromInt64(int64(x), 0))
+	case int32:
+		err = p.Set(DecimalFromInt64(int64(x), 0))
+	default:
+		err = p.Set(DecimalFromInt64(v.(int64), 0))
+	}
+	if err != nil {
+		panic(err)
+	}
+}
+
+// GetValue returns the value as a string, it keeps every digit
+func (p *Decimal) GetValue() ValueRefer {
+	return p.String()
+}
+
+func (p *Decimal) GetLen() int {
+	return 2 + len(new(big.Int).Abs(p.unscaled).Bytes())
+}
+
+func (p *Decimal) Copy() DtRefer {
+	return &Decimal{
+		unscaled:  new(big.Int).Set(p.unscaled),
+		scale:     p.scale,
+		precision: p.precision,
+	}
+}
+func (p *Decimal) DeepCopy() DtRefer {
+	return p.Copy()
+}
+
+// Compare orders the values by number, 1.50 equals 1.5
+func (p *Decimal) Compare(v Comparator) int {
+	if _, ok := v.(*Decimal); !ok && isNumeric(v) {
+		return compareNumeric(p, v.(DtRefer))
+	}
+	o := v.(*Decimal)
+	scale := maxInt32(p.scale, o.scale)
+	return p.rescaled(scale).Cmp(o.rescaled(scale))
+}
+
+func maxInt32(a int32, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
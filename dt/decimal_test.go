@@ -0,0 +1,150 @@
+package dt
+
+import (
+	"testing"
+)
+
+func TestDecimal(t *testing.T) {
+	for _, s := range []string{"0", "-12.345", "99999999999999999999999999.99", "0.001", "-0.5"} {
+		d1, err := ValidNewDecimal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b1, _ := d1.Encode()
+
+		d2 := NewDecimal()
+		l, _ := d2.Decode(b1, 0)
+		if l != len(b1) || l != d2.GetLen() {
+			t.Fatal("len should be ", len(b1), ",but ", l)
+		}
+		if d2.GetValue().(string) != s {
+			t.Fatal("value should be ", s, ",but ", d2.GetValue())
+		}
+	}
+}
+
+func TestDecimalExponent(t *testing.T) {
+	if d, err := ValidNewDecimal("1.5e3"); err != nil || d.GetValue().(string) != "1500" {
+		t.Fatal("1.5e3 should be 1500")
+	}
+	if d, err := ValidNewDecimal("1e305"); err != nil {
+		t.Fatal(err)
+	} else if _, err := d.Encode(); err != nil {
+		t.Fatal("1e305 should be encoded ", err)
+	}
+	cases := []struct {
+		in  string
+		err error
+	}{
+		{"1e3000000000", ErrDecimalOverflow},
+		{"1e99999999", ErrDecimalOverflow},
+		{"1e306", ErrDecimalOverflow},
+		{"1e-3000000000", ErrDecimalSyntax},
+		{"1e99999999999999999999", ErrDecimalOverflow},
+	}
+	for _, c := range cases {
+		if _, err := ValidNewDecimal(c.in); err != c.err {
+			t.Fatal(c.in, " should fail with ", c.err, ",but ", err)
+		}
+	}
+	if v, err := Cast(ValidNewString("1e3000000000"), Int64Type); err == nil {
+		t.Fatal("1e3000000000 should not be cast to ", v)
+	}
+}
+
+func TestDecimalDeclared(t *testing.T) {
+	meta := NewCellMetaRaw(0, DecimalType, "price", "", "1.005").WithDecimal(5, 2)
+	d := meta.NewCell().(*Decimal)
+	if d.GetValue().(string) != "1.01" {
+		t.Fatal("value should be 1.01,but ", d.GetValue())
+	}
+	if err := d.SetString("999.994"); err != nil || d.String() != "999.99" {
+		t.Fatal("value should be 999.99,but ", d.String(), err)
+	}
+	if err := d.SetString("999.995"); err != ErrDecimalOverflow {
+		t.Fatal("999.995 should overflow decimal(5,2)")
+	}
+	if err := d.SetString("1.2.3"); err != ErrDecimalSyntax {
+		t.Fatal("1.2.3 should be invalid")
+	}
+}
+
+func TestDecimalCompare(t *testing.T) {
+	values := []string{"-10", "-1.5", "-0.01", "0", "0.001", "1.5", "1.50001", "10"}
+	for i := range values {
+		for j := range values {
+			a, _ := ValidNewDecimal(values[i])
+			b, _ := ValidNewDecimal(values[j])
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			if a.Compare(b) != want {
+				t.Fatal(values[i], " compare ", values[j], " should be ", want)
+			}
+		}
+	}
+	a, _ := ValidNewDecimal("1.50")
+	b, _ := ValidNewDecimal("1.5")
+	if a.Compare(b) != 0 {
+		t.Fatal("1.50 should equal 1.5")
+	}
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	a, _ := ValidNewDecimal("0.1")
+	b, _ := ValidNewDecimal("0.2")
+	if s := a.Add(b).String(); s != "0.3" {
+		t.Fatal("0.1+0.2 should be 0.3,but ", s)
+	}
+	if s := a.Sub(b).String(); s != "-0.1" {
+		t.Fatal("0.1-0.2 should be -0.1,but ", s)
+	}
+	if s := a.Mul(b).String(); s != "0.02" {
+		t.Fatal("0.1*0.2 should be 0.02,but ", s)
+	}
+	one, _ := ValidNewDecimal("1")
+	three, _ := ValidNewDecimal("3")
+	q, _ := one.Div(three, 4, RoundHalfUp)
+	if q.String() != "0.3333" {
+		t.Fatal("1/3 should be 0.3333,but ", q)
+	}
+	if _, err := one.Div(NewDecimal(), 2, RoundHalfUp); err != ErrDivisionByZero {
+		t.Fatal("1/0 should fail")
+	}
+	m, _ := ValidNewDecimal("-7.5")
+	r, _ := m.Mod(three)
+	if r.String() != "-1.5" {
+		t.Fatal("-7.5%3 should be -1.5,but ", r)
+	}
+}
+
+func TestDecimalRounding(t *testing.T) {
+	cases := []struct {
+		value string
+		mode  RoundingMode
+		want  string
+	}{
+		{"2.5", RoundHalfUp, "3"},
+		{"-2.5", RoundHalfUp, "-3"},
+		{"2.5", RoundHalfEven, "2"},
+		{"3.5", RoundHalfEven, "4"},
+		{"2.51", RoundHalfEven, "3"},
+		{"2.9", RoundDown, "2"},
+		{"-2.9", RoundDown, "-2"},
+		{"2.1", RoundUp, "3"},
+		{"-2.1", RoundUp, "-3"},
+		{"-2.1", RoundFloor, "-3"},
+		{"2.9", RoundFloor, "2"},
+		{"-2.9", RoundCeiling, "-2"},
+		{"2.1", RoundCeiling, "3"},
+	}
+	for _, c := range cases {
+		d, _ := ValidNewDecimal(c.value)
+		if s := d.Round(0, c.mode).String(); s != c.want {
+			t.Fatal(c.value, " rounded by ", c.mode, " should be ", c.want, ",but ", s)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package dt
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+var ErrEnumLabel = errors.New("the label is not allowed by the enum")
+
+// Enum is one of the labels declared by the CellMeta, it is saved as the
+// index of the label in 2 bytes and ordered like the labels are declared
+type Enum struct {
+	DtRefer
+	value  uint16
+	labels []string //never saved, they come from the CellMeta
+}
+
+func NewEnum(labels []string) *Enum {
+	return &Enum{labels: labels}
+}
+
+func (p *Enum) GetLabels() []string {
+	return p.labels
+}
+
+func (p *Enum) Index() int {
+	return int(p.value)
+}
+
+// Label returns "" if the labels are not known
+func (p *Enum) Label() string {
+	if int(p.value) < len(p.labels) {
+		return p.labels[p.value]
+	}
+	return ""
+}
+
+func (p *Enum) SetLabel(label string) error {
+	for i, l := range p.labels {
+		if l == label {
+			p.value = uint16(i)
+			return nil
+		}
+	}
+	return ErrEnumLabel
+}
+
+// SetIndex sets the label by its index, any index is taken if the labels are not known
+func (p *Enum) SetIndex(i int) error {
+	if i < 0 || i > math.MaxUint16 || (nil != p.labels && i >= len(p.labels)) {
+		return ErrEnumLabel
+	}
+	p.value = uint16(i)
+	return nil
+}
+
+func (p *Enum) Encode() ([]byte, error) {
+	buf := make([]byte, 2)
+	binary.BigEndian.PutUint16(buf, p.value)
+	return buf, nil
+}
+
+func (p *Enum) Decode(buf []byte, offset int) (int, error) {
+	p.value = binary.BigEndian.Uint16(buf[offset : offset+2])
+	return 2, nil
+}
+
+// SetValue takes a label or the index of a label as an int, it panics
+// if the enum does not allow it
+func (p *Enum) SetValue(v ValueRefer) {
+	var err error
+	switch x := v.(type) {
+	case string:
+		err = p.SetLabel(x)
+	case *Enum:
+		err = p.SetLabel(x.Label())
+	default:
+		err = p.SetIndex(v.(int))
+	}
+	if err != nil {
+		panic(err)
+	}
+}
+
+// GetValue returns the label, or the index as an int if the labels are not known
+func (p *Enum) GetValue() ValueRefer {
+	if nil == p.labels {
+		return int(p.value)
+	}
+	return p.Label()
+}
+
+func (p *Enum) GetLen() int {
+	return 2
+}
+
+func (p *Enum) Copy() DtRefer {
+	return &Enum{value: p.value, labels: p.labels}
+}
+
+func (p *Enum) DeepCopy() DtRefer {
+	return p.Copy()
+}
+
+// Compare orders the labels like they are declared
+func (p *Enum) Compare(v Comparator) int {
+	ov := v.(*Enum).value
+	switch {
+	case p.value == ov:
+		return 0
+	case p.value < ov:
+		return -1
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,57 @@
+package dt
+
+import "testing"
+
+func TestEnum(t *testing.T) {
+	meta := NewCellMetaRaw(0, EnumType, "status", "", nil).WithEnumLabels("new", "paid", "shipped")
+
+	e1 := meta.NewCell().(*Enum)
+	if err := e1.SetLabel("shipped"); err != nil {
+		t.Fatal(err)
+	}
+	if e1.Index() != 2 || e1.GetValue() != "shipped" {
+		t.Fatal("shipped should be 2")
+	}
+	if err := e1.SetLabel("lost"); err != ErrEnumLabel || e1.Label() != "shipped" {
+		t.Fatal("lost should not be allowed")
+	}
+	if err := e1.SetIndex(3); err != ErrEnumLabel {
+		t.Fatal("3 should not be allowed")
+	}
+
+	buf, _ := e1.Encode()
+	e2 := meta.NewCell().(*Enum)
+	l, _ := e2.Decode(buf, 0)
+	if l != 2 || l != e2.GetLen() || e2.Label() != "shipped" || e2.Compare(e1) != 0 {
+		t.Fatal("the enum should be decoded")
+	}
+
+	//the order of the declaration, not of the labels
+	e2.SetValue("new")
+	if e2.Compare(e1) != -1 || e1.Compare(e2) != 1 {
+		t.Fatal("new should be before shipped")
+	}
+
+	//without the labels only the index is known
+	e3 := NewDtRefer(EnumType)
+	e3.Decode(buf, 0)
+	if e3.GetValue() != 2 || e3.Compare(e1) != 0 {
+		t.Fatal("the index should be decoded")
+	}
+}
+
+func TestEnum_TrySetValue(t *testing.T) {
+	e := NewEnum([]string{"a", "b"})
+	if err := TrySetValue(e, "b"); err != nil || e.Index() != 1 {
+		t.Fatal("b should be set")
+	}
+	if err := TrySetValue(e, int64(0)); err != nil || e.Label() != "a" {
+		t.Fatal("0 should be set")
+	}
+	if err := TrySetValue(e, "c"); err != ErrEnumLabel || e.Label() != "a" {
+		t.Fatal("c should not be set")
+	}
+	if err := TrySetValue(e, 1.5); err == nil {
+		t.Fatal("1.5 should not be set")
+	}
+}
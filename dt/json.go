@@ -1,4 +1,542 @@
 package dt
 
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// the tags of the binary json values, the order of the tags is the order of Compare
+const (
+	jsonNull byte = iota
+	jsonFalse
+	jsonTrue
+	jsonInt
+	jsonFloat
+	jsonString
+	jsonArray
+	jsonObject
+)
+
+var ErrJsonSyntax = errors.New("invalid json")
+var ErrJsonPath = errors.New("invalid json path")
+
+// Json is a json document saved in a binary format. A scalar is a tag and
+// its payload, a string is prefixed by its length. An array or an object
+// saves its body length, the count and a table of offsets to the items,
+// the keys of an object are sorted, so a path is found by skipping and
+// binary searching without decoding the whole document.
 type Json struct {
+	DtRefer
+	doc []byte
+}
+
+func NewJson() *Json {
+	return &Json{doc: []byte{jsonNull}}
+}
+
+// ValidNewJson parses the json text s
+func ValidNewJson(s string) (*Json, error) {
+	p := NewJson()
+	if err := p.SetJson(s); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// SetJson replaces the document by the json text s
+func (p *Json) SetJson(s string) error {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return ErrJsonSyntax
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return ErrJsonSyntax
+	}
+	buf := new(bytes.Buffer)
+	if err := encodeJsonValue(buf, v); err != nil {
+		return err
+	}
+	p.doc = buf.Bytes()
+	return nil
+}
+
+// SetValue takes json text as a string or []byte, a *Json, or a go value
+// that encoding/json can marshal, it panics if the value is not valid json
+func (p *Json) SetValue(v ValueRefer) {
+	var err error
+	switch x := v.(type) {
+	case string:
+		err = p.SetJson(x)
+	case []byte:
+		err = p.SetJson(string(x))
+	case *Json:
+		p.doc = append([]byte(nil), x.doc...)
+	default:
+		var b []byte
+		if b, err = json.Marshal(v); err == nil {
+			err = p.SetJson(string(b))
+		}
+	}
+	if err != nil {
+		panic(err)
+	}
+}
+
+// GetValue returns the json text
+func (p *Json) GetValue() ValueRefer {
+	return p.String()
+}
+
+// Interface decodes the document to nil, bool, int64, float64, string,
+// []interface{} and map[string]interface{}
+func (p *Json) Interface() interface{} {
+	v, _ := decodeJsonValue(p.doc, 0)
+	return v
+}
+
+func (p *Json) String() string {
+	b, _ := json.Marshal(p.Interface())
+	return string(b)
+}
+
+func (p *Json) Encode() ([]byte, error) {
+	return p.doc, nil
+}
+
+func (p *Json) Decode(buf []byte, offset int) (int, error) {
+	l := jsonValueLen(buf, offset)
+	p.doc = append([]byte(nil), buf[offset:offset+l]...)
+	return l, nil
+}
+
+func (p *Json) GetLen() int {
+	return len(p.doc)
+}
+
+func (p *Json) Copy() DtRefer {
+	return &Json{doc: append([]byte(nil), p.doc...)}
+}
+func (p *Json) DeepCopy() DtRefer {
+	return p.Copy()
+}
+
+// Compare orders null < false < true < numbers < strings < arrays < objects,
+// arrays and objects are compared item by item, an object item is its key and value
+func (p *Json) Compare(v Comparator) int {
+	return compareJson(p.doc, 0, v.(*Json).doc, 0)
+}
+
+// GetPath returns the value at path, like "a.b[2]" or "[0].name",
+// the empty path is the whole document
+func (p *Json) GetPath(path string) (*Json, bool) {
+	segs, err := parseJsonPath(path)
+	if err != nil {
+		return nil, false
+	}
+	off := 0
+	for _, seg := range segs {
+		var ok bool
+		if seg.isIndex {
+			off, ok = jsonArrayItem(p.doc, off, seg.index)
+		} else {
+			off, ok = jsonObjectField(p.doc, off, seg.key)
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+	l := jsonValueLen(p.doc, off)
+	return &Json{doc: append([]byte(nil), p.doc[off:off+l]...)}, true
+}
+
+// SetPath replaces the value at path by v, only the containers on the path
+// are rebuilt. A missing object key is added and the index equal to the
+// length of an array appends to it.
+func (p *Json) SetPath(path string, v *Json) error {
+	segs, err := parseJsonPath(path)
+	if err != nil {
+		return err
+	}
+	doc, err := setJsonPath(p.doc, 0, segs, v.doc)
+	if err != nil {
+		return err
+	}
+	p.doc = doc
+	return nil
+}
+
+// DeletePath removes the value at path from its parent
+func (p *Json) DeletePath(path string) error {
+	segs, err := parseJsonPath(path)
+	if err != nil {
+		return err
+	}
+	if len(segs) == 0 {
+		return ErrJsonPath
+	}
+	doc, err := setJsonPath(p.doc, 0, segs, nil)
+	if err != nil {
+		return err
+	}
+	p.doc = doc
+	return nil
+}
+
+type jsonPathSeg struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+func parseJsonPath(path string) ([]jsonPathSeg, error) {
+	segs := make([]jsonPathSeg, 0)
+	for i := 0; i < len(path); {
+		switch path[i] {
+		case '.':
+			if i == 0 || i == len(path)-1 || path[i+1] == '.' || path[i+1] == '[' {
+				return nil, ErrJsonPath
+			}
+			i++
+		case '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, ErrJsonPath
+			}
+			idx, err := strconv.Atoi(path[i+1 : i+end])
+			if err != nil || idx < 0 {
+				return nil, ErrJsonPath
+			}
+			segs = append(segs, jsonPathSeg{index: idx, isIndex: true})
+			i += end + 1
+		default:
+			end := strings.IndexAny(path[i:], ".[")
+			if end < 0 {
+				end = len(path) - i
+			}
+			segs = append(segs, jsonPathSeg{key: path[i : i+end]})
+			i += end
+		}
+	}
+	return segs, nil
+}
+
+func encodeJsonValue(buf *bytes.Buffer, v interface{}) error {
+	switch x := v.(type) {
+	case nil:
+		buf.WriteByte(jsonNull)
+	case bool:
+		if x {
+			buf.WriteByte(jsonTrue)
+		} else {
+			buf.WriteByte(jsonFalse)
+		}
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			buf.WriteByte(jsonInt)
+			binary.Write(buf, binary.BigEndian, i)
+			return nil
+		}
+		f, err := x.Float64()
+		if err != nil || math.IsInf(f, 0) {
+			return ErrJsonSyntax
+		}
+		buf.WriteByte(jsonFloat)
+		binary.Write(buf, binary.BigEndian, f)
+	case string:
+		buf.WriteByte(jsonString)
+		binary.Write(buf, binary.BigEndian, uint32(len(x)))
+		buf.WriteString(x)
+	case []interface{}:
+		items := make([][]byte, len(x))
+		for i, item := range x {
+			b := new(bytes.Buffer)
+			if err := encodeJsonValue(b, item); err != nil {
+				return err
+			}
+			items[i] = b.Bytes()
+		}
+		writeJsonContainer(buf, jsonArray, items)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		items := make([][]byte, len(keys))
+		for i, k := range keys {
+			b := new(bytes.Buffer)
+			if err := encodeJsonValue(b, x[k]); err != nil {
+				return err
+			}
+			items[i] = jsonObjectItem(k, b.Bytes())
+		}
+		writeJsonContainer(buf, jsonObject, items)
+	default:
+		return ErrJsonSyntax
+	}
+	return nil
+}
+
+// jsonObjectItem is the length of the key, the key and the value
+func jsonObjectItem(key string, value []byte) []byte {
+	b := new(bytes.Buffer)
+	binary.Write(b, binary.BigEndian, uint32(len(key)))
+	b.WriteString(key)
+	b.Write(value)
+	return b.Bytes()
+}
+
+// writeJsonContainer writes the tag, the body length, the count, the offsets and the items
+func writeJsonContainer(buf *bytes.Buffer, tag byte, items [][]byte) {
+	bodyLen := 4 + 4*len(items)
+	for _, item := range items {
+		bodyLen += len(item)
+	}
+	buf.WriteByte(tag)
+	binary.Write(buf, binary.BigEndian, uint32(bodyLen))
+	binary.Write(buf, binary.BigEndian, uint32(len(items)))
+	off := 0
+	for _, item := range items {
+		binary.Write(buf, binary.BigEndian, uint32(off))
+		off += len(item)
+	}
+	for _, item := range items {
+		buf.Write(item)
+	}
+}
+
+func jsonUint32(doc []byte, off int) int {
+	return int(binary.BigEndian.Uint32(doc[off : off+4]))
+}
+
+func jsonValueLen(doc []byte, off int) int {
+	switch doc[off] {
+	case jsonInt, jsonFloat:
+		return 9
+	case jsonString, jsonArray, jsonObject:
+		return 5 + jsonUint32(doc, off+1)
+	}
+	return 1
+}
+
+func jsonCount(doc []byte, off int) int {
+	return jsonUint32(doc, off+5)
+}
+
+// jsonItem returns the offset of the i-th item of an array or an object
+func jsonItem(doc []byte, off int, i int) int {
+	count := jsonCount(doc, off)
+	return off + 9 + 4*count + jsonUint32(doc, off+9+4*i)
+}
+
+// jsonKey returns the key of the object item at off and the offset of its value
+func jsonKey(doc []byte, off int) (string, int) {
+	l := jsonUint32(doc, off)
+	return string(doc[off+4 : off+4+l]), off + 4 + l
+}
+
+func jsonArrayItem(doc []byte, off int, i int) (int, bool) {
+	if doc[off] != jsonArray || i >= jsonCount(doc, off) {
+		return 0, false
+	}
+	return jsonItem(doc, off, i), true
+}
+
+func jsonObjectField(doc []byte, off int, key string) (int, bool) {
+	if doc[off] != jsonObject {
+		return 0, false
+	}
+	count := jsonCount(doc, off)
+	i := sort.Search(count, func(i int) bool {
+		k, _ := jsonKey(doc, jsonItem(doc, off, i))
+		return k >= key
+	})
+	if i == count {
+		return 0, false
+	}
+	k, valueOff := jsonKey(doc, jsonItem(doc, off, i))
+	return valueOff, k == key
+}
+
+func decodeJsonValue(doc []byte, off int) (interface{}, int) {
+	l := jsonValueLen(doc, off)
+	switch doc[off] {
+	case jsonFalse:
+		return false, l
+	case jsonTrue:
+		return true, l
+	case jsonInt:
+		return int64(binary.BigEndian.Uint64(doc[off+1 : off+9])), l
+	case jsonFloat:
+		return math.Float64frombits(binary.BigEndian.Uint64(doc[off+1 : off+9])), l
+	case jsonString:
+		return string(doc[off+5 : off+l]), l
+	case jsonArray:
+		count := jsonCount(doc, off)
+		ret := make([]interface{}, count)
+		for i := 0; i < count; i++ {
+			ret[i], _ = decodeJsonValue(doc, jsonItem(doc, off, i))
+		}
+		return ret, l
+	case jsonObject:
+		count := jsonCount(doc, off)
+		ret := make(map[string]interface{}, count)
+		for i := 0; i < count; i++ {
+			k, valueOff := jsonKey(doc, jsonItem(doc, off, i))
+			ret[k], _ = decodeJsonValue(doc, valueOff)
+		}
+		return ret, l
+	}
+	return nil, l
+}
+
+// setJsonPath returns the value at off with the value at segs replaced by value,
+// a nil value deletes it
+func setJsonPath(doc []byte, off int, segs []jsonPathSeg, value []byte) ([]byte, error) {
+	if len(segs) == 0 {
+		return value, nil
+	}
+	seg := segs[0]
+	tag := doc[off]
+	if (seg.isIndex && tag != jsonArray) || (!seg.isIndex && tag != jsonObject) {
+		return nil, ErrJsonPath
+	}
+	count := jsonCount(doc, off)
+	items := make([][]byte, 0, count+1)
+	keys := make([]string, 0, count+1)
+	for i := 0; i < count; i++ {
+		itemOff := jsonItem(doc, off, i)
+		if tag == jsonObject {
+			k, valueOff := jsonKey(doc, itemOff)
+			keys = append(keys, k)
+			itemOff = valueOff
+		}
+		items = append(items, doc[itemOff:itemOff+jsonValueLen(doc, itemOff)])
+	}
+
+	pos := seg.index
+	if !seg.isIndex {
+		pos = sort.SearchStrings(keys, seg.key)
+	}
+	exists := pos < count && (seg.isIndex || keys[pos] == seg.key)
+	switch {
+	case exists:
+		child, err := setJsonPath(doc, jsonItemValue(doc, off, pos), segs[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		if nil == child {
+			items = append(items[:pos], items[pos+1:]...)
+			if !seg.isIndex {
+				keys = append(keys[:pos], keys[pos+1:]...)
+			}
+		} else {
+			items[pos] = child
+		}
+	case len(segs) == 1 && nil != value && (!seg.isIndex || pos == count):
+		items = append(items[:pos], append([][]byte{value}, items[pos:]...)...)
+		if !seg.isIndex {
+			keys = append(keys[:pos], append([]string{seg.key}, keys[pos:]...)...)
+		}
+	default:
+		return nil, ErrJsonPath
+	}
+
+	if tag == jsonObject {
+		for i := range items {
+			items[i] = jsonObjectItem(keys[i], items[i])
+		}
+	}
+	buf := new(bytes.Buffer)
+	writeJsonContainer(buf, tag, items)
+	return buf.Bytes(), nil
+}
+
+// jsonItemValue returns the offset of the value of the i-th item
+func jsonItemValue(doc []byte, off int, i int) int {
+	itemOff := jsonItem(doc, off, i)
+	if doc[off] == jsonObject {
+		_, itemOff = jsonKey(doc, itemOff)
+	}
+	return itemOff
+}
+
+func jsonRank(tag byte) byte {
+	if tag == jsonFloat {
+		return jsonInt
+	}
+	return tag
+}
+
+func jsonNumber(doc []byte, off int) float64 {
+	v, _ := decodeJsonValue(doc, off)
+	if i, ok := v.(int64); ok {
+		return float64(i)
+	}
+	return v.(float64)
+}
+
+func compareJson(a []byte, ao int, b []byte, bo int) int {
+	ra, rb := jsonRank(a[ao]), jsonRank(b[bo])
+	if ra != rb {
+		if ra < rb {
+			return -1
+		}
+		return 1
+	}
+	switch ra {
+	case jsonInt:
+		if a[ao] == jsonInt && b[bo] == jsonInt {
+			x, _ := decodeJsonValue(a, ao)
+			y, _ := decodeJsonValue(b, bo)
+			return compareInt64(x.(int64), y.(int64))
+		}
+		x, y := jsonNumber(a, ao), jsonNumber(b, bo)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case jsonString:
+		return bytes.Compare(a[ao+5:ao+jsonValueLen(a, ao)], b[bo+5:bo+jsonValueLen(b, bo)])
+	case jsonArray, jsonObject:
+		ca, cb := jsonCount(a, ao), jsonCount(b, bo)
+		for i := 0; i < ca && i < cb; i++ {
+			ia, ib := jsonItem(a, ao, i), jsonItem(b, bo, i)
+			if ra == jsonObject {
+				var ka, kb string
+				ka, ia = jsonKey(a, ia)
+				kb, ib = jsonKey(b, ib)
+				if c := strings.Compare(ka, kb); c != 0 {
+					return c
+				}
+			}
+			if c := compareJson(a, ia, b, ib); c != 0 {
+				return c
+			}
+		}
+		return compareInt64(int64(ca), int64(cb))
+	}
+	return 0
+}
+
+func compareInt64(a int64, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
 }
@@ -0,0 +1,114 @@
+package dt
+
+import (
+	"testing"
+)
+
+func TestJson(t *testing.T) {
+	j1, err := ValidNewJson(`{"b":[1,2.5,"x",null,true],"a":{"c":false},"d":-3}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b1, _ := j1.Encode()
+
+	j2 := NewJson()
+	l, _ := j2.Decode(append([]byte{0xff}, b1...), 1)
+	if l != len(b1) || l != j2.GetLen() {
+		t.Fatal("len should be ", len(b1), ",but ", l)
+	}
+	want := `{"a":{"c":false},"b":[1,2.5,"x",null,true],"d":-3}`
+	if j2.GetValue().(string) != want {
+		t.Fatal("value should be ", want, ",but ", j2.GetValue())
+	}
+
+	for _, s := range []string{`{"a":}`, `[1,2] 3`, ``, `{"a":1e999}`} {
+		if _, err := ValidNewJson(s); err != ErrJsonSyntax {
+			t.Fatal(s, " should be invalid")
+		}
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("SetValue should panic for invalid json")
+			}
+		}()
+		NewJson().SetValue("{")
+	}()
+}
+
+func TestJson_GetPath(t *testing.T) {
+	j, _ := ValidNewJson(`{"a":{"b":[10,{"c":"x"},30]},"k":1}`)
+	cases := map[string]string{
+		"":         `{"a":{"b":[10,{"c":"x"},30]},"k":1}`,
+		"a.b[2]":   `30`,
+		"a.b[1].c": `"x"`,
+		"a.b":      `[10,{"c":"x"},30]`,
+		"k":        `1`,
+	}
+	for path, want := range cases {
+		v, ok := j.GetPath(path)
+		if !ok || v.String() != want {
+			t.Fatal(path, " should be ", want, ",but ", v, ok)
+		}
+	}
+	for _, path := range []string{"a.b[3]", "a.x", "k[0]", "a.b.c", "a..b", "a.b[x]"} {
+		if _, ok := j.GetPath(path); ok {
+			t.Fatal(path, " should not be found")
+		}
+	}
+}
+
+func TestJson_SetPath(t *testing.T) {
+	j, _ := ValidNewJson(`{"a":{"b":[10,20]},"k":1}`)
+	v, _ := ValidNewJson(`{"z":true}`)
+	if err := j.SetPath("a.b[1]", v); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.SetPath("a.b[2]", v); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.SetPath("a.new", v); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.DeletePath("k"); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"a":{"b":[10,{"z":true},{"z":true}],"new":{"z":true}}}`
+	if j.String() != want {
+		t.Fatal("value should be ", want, ",but ", j)
+	}
+	if err := j.SetPath("a.b[9]", v); err != ErrJsonPath {
+		t.Fatal("a.b[9] should be out of range")
+	}
+	if err := j.SetPath("x.y", v); err != ErrJsonPath {
+		t.Fatal("x.y should not be created")
+	}
+	if err := j.DeletePath("a.missing"); err != ErrJsonPath {
+		t.Fatal("a.missing should not be deleted")
+	}
+}
+
+func TestJson_Compare(t *testing.T) {
+	values := []string{`null`, `false`, `true`, `-1`, `1`, `1.5`, `2`, `""`, `"a"`, `"b"`,
+		`[]`, `[1]`, `[1,2]`, `[2]`, `{}`, `{"a":1}`, `{"a":2}`, `{"b":0}`}
+	for i := range values {
+		for j := range values {
+			a, _ := ValidNewJson(values[i])
+			b, _ := ValidNewJson(values[j])
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			if a.Compare(b) != want {
+				t.Fatal(values[i], " compare ", values[j], " should be ", want)
+			}
+		}
+	}
+	a, _ := ValidNewJson(`{"x":1,"y":2}`)
+	b, _ := ValidNewJson(`{"y":2,"x":1.0}`)
+	if a.Compare(b) != 0 {
+		t.Fatal("the order of the keys should not matter")
+	}
+}
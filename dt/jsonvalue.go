@@ -0,0 +1,413 @@
+package dt
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+var ErrJsonLob = errors.New("a large object is not written to json")
+
+var typeNames = map[DType]string{
+	ByteType: "byte", Int32Type: "int32", UInt32Type: "uint32", Int64Type: "int64", UInt64Type: "uint64",
+	Float32Type: "float32", Float64Type: "float64", BoolType: "bool", StringType: "string",
+	DecimalType: "decimal", TimeType: "time", ArrayType: "array", JsonType: "json", BytesType: "bytes",
+	UUIDType: "uuid", EnumType: "enum", StructType: "struct", MapType: "map",
+}
+
+// JsonValueError is returned when a json value does not fit its cell
+type JsonValueError struct {
+	Path  string      //the cell, then the fields and the indexes like ship_to.city or tags[2]
+	Type  DType       //the type of the cell
+	Value interface{} //the json value
+	Err   error       //why the value can not be cast, nil if it is of a wrong json type
+}
+
+func (e *JsonValueError) Error() string {
+	msg := fmt.Sprintf("%s: json %s can not be %s", e.Path, jsonTypeOf(e.Value), typeNames[e.Type])
+	if nil != e.Err {
+		msg += ", " + e.Err.Error()
+	}
+	return msg
+}
+
+func jsonTypeOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number, float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// ToJsonValue returns the value of cell for encoding/json, NULL is nil. The
+// integers are exact numbers, a decimal is a string to keep its digits, a time
+// is a string like Time.String, bytes are base64 and a map is an object of the
+// keys as text.
+func ToJsonValue(cell DtRefer) (interface{}, error) {
+	switch c := cell.(type) {
+	case nil:
+		return nil, nil
+	case *Byte, *Int32, *UInt32, *Int64, *UInt64:
+		return json.Number(fmt.Sprint(c.GetValue())), nil
+	case *Float32:
+		f := float64(c.GetValue().(float32))
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("%v is not a json number", f)
+		}
+		return json.Number(strconv.FormatFloat(f, 'g', -1, 32)), nil
+	case *Float64:
+		f := c.GetValue().(float64)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("%v is not a json number", f)
+		}
+		return f, nil
+	case *Decimal:
+		return c.String(), nil
+	case *Time:
+		return c.String(), nil
+	case *UUID:
+		return c.String(), nil
+	case *Json:
+		return json.RawMessage(c.String()), nil
+	case *Bytes:
+		if c.IsLob() {
+			return nil, ErrJsonLob
+		}
+		return base64.StdEncoding.EncodeToString(c.GetValue().([]byte)), nil
+	case *Array:
+		items := c.GetValue().([]DtRefer)
+		ret := make([]interface{}, len(items))
+		for i, item := range items {
+			v, err := ToJsonValue(item)
+			if err != nil {
+				return nil, err
+			}
+			ret[i] = v
+		}
+		return ret, nil
+	case *Struct:
+		if nil == c.meta {
+			return nil, ErrNoStructMeta
+		}
+		ret := make(map[string]interface{}, len(c.value))
+		for i, item := range c.meta.GetItems() {
+			if nil == item {
+				continue
+			}
+			v, err := ToJsonValue(c.value[i])
+			if err != nil {
+				return nil, err
+			}
+			ret[item.GetName()] = v
+		}
+		return ret, nil
+	case *Map:
+		ret := make(map[string]interface{}, c.Len())
+		for i, k := range c.keys {
+			key, err := Cast(k, StringType)
+			if err != nil {
+				return nil, err
+			}
+			v, err := ToJsonValue(c.values[i])
+			if err != nil {
+				return nil, err
+			}
+			ret[key.(string)] = v
+		}
+		return ret, nil
+	}
+	return cell.GetValue(), nil
+}
+
+// FromJsonValue makes a cell of s from a value decoded by encoding/json, nil
+// for null. The numbers should be decoded as json.Number to keep them exact.
+// A *JsonValueError is returned if the value does not fit the cell.
+func (s *CellMeta) FromJsonValue(v interface{}) (DtRefer, error) {
+	return s.fromJson(s.GetName(), v)
+}
+
+func (s *CellMeta) fromJson(path string, v interface{}) (DtRefer, error) {
+	if nil == v {
+		return nil, nil
+	}
+	typ := s.GetMType()
+	fail := func(err error) (DtRefer, error) {
+		return nil, &JsonValueError{Path: path, Type: typ, Value: v, Err: err}
+	}
+	cell := s.newDtRefer()
+	switch typ {
+	case ByteType, Int32Type, UInt32Type, Int64Type, UInt64Type, Float32Type, Float64Type:
+		n, ok := numberText(v)
+		if !ok {
+			return fail(nil)
+		}
+		if err := TrySetValue(cell, n); err != nil {
+			return fail(err)
+		}
+		return cell, nil
+	case DecimalType:
+		n, ok := numberText(v)
+		if s, isString := v.(string); isString {
+			n, ok = s, true
+		}
+		if !ok {
+			return fail(nil)
+		}
+		if err := TrySetValue(cell, n); err != nil {
+			return fail(err)
+		}
+		return cell, nil
+	case BoolType, StringType, TimeType, UUIDType:
+		kind := jsonTypeOf(v)
+		if (typ == BoolType) != (kind == "boolean") || (typ != BoolType && kind != "string") {
+			return fail(nil)
+		}
+		if err := TrySetValue(cell, v); err != nil {
+			return fail(err)
+		}
+		return cell, nil
+	case EnumType:
+		if n, ok := numberText(v); ok {
+			v = n
+		} else if _, ok := v.(string); !ok {
+			return fail(nil)
+		}
+		if err := TrySetValue(cell, v); err != nil {
+			return fail(err)
+		}
+		return cell, nil
+	case BytesType:
+		text, ok := v.(string)
+		if !ok {
+			return fail(nil)
+		}
+		b, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return fail(err)
+		}
+		cell.SetValue(b)
+		return cell, nil
+	case JsonType:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fail(err)
+		}
+		j, err := ValidNewJson(string(b))
+		if err != nil {
+			return fail(err)
+		}
+		return j, nil
+	case ArrayType:
+		list, ok := v.([]interface{})
+		if !ok || len(s.subTypes) == 0 {
+			return fail(nil)
+		}
+		item := NewCellMetaRaw(0, s.subTypes[0], "", "", nil).WithSubType(s.subTypes[1:]...)
+		items := make([]DtRefer, len(list))
+		for i, x := range list {
+			c, err := item.fromJson(fmt.Sprintf("%s[%d]", path, i), x)
+			if err != nil {
+				return nil, err
+			}
+			if nil == c {
+				return nil, &JsonValueError{Path: fmt.Sprintf("%s[%d]", path, i), Type: s.subTypes[0]}
+			}
+			items[i] = c
+		}
+		cell.SetValue(items)
+		return cell, nil
+	case StructType:
+		obj, ok := v.(map[string]interface{})
+		if !ok || nil == s.structMeta {
+			return fail(nil)
+		}
+		st := cell.(*Struct)
+		for _, name := range sortedKeys(obj) {
+			field := s.structMeta.GetCellMeta(name)
+			if nil == field {
+				return nil, &JsonValueError{Path: path + "." + name, Type: typ, Value: obj[name], Err: ErrStructField}
+			}
+			c, err := field.fromJson(path+"."+name, obj[name])
+			if err != nil {
+				return nil, err
+			}
+			st.value[field.GetPos()] = c
+		}
+		return st, nil
+	case MapType:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fail(nil)
+		}
+		m := cell.(*Map)
+		value := *s
+		value.mType = ValidNewInt32(int32(s.mapValue))
+		for _, k := range sortedKeys(obj) {
+			c, err := value.fromJson(path+"."+k, obj[k])
+			if err != nil {
+				return nil, err
+			}
+			if nil == c {
+				return nil, &JsonValueError{Path: path + "." + k, Type: s.mapValue}
+			}
+			if err := m.Put(k, c); err != nil {
+				return nil, &JsonValueError{Path: path + "." + k, Type: s.mapKey, Value: k, Err: err}
+			}
+		}
+		return m, nil
+	}
+	return fail(ErrCastType)
+}
+
+// numberText returns the text of a json number
+func numberText(v interface{}) (string, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		return n.String(), true
+	case float64:
+		return strconv.FormatFloat(n, 'g', -1, 64), true
+	}
+	return "", false
+}
+
+// sortedKeys makes the errors of an object the same every time
+func sortedKeys(obj map[string]interface{}) []string {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// JsonSchema returns the JSON Schema of the objects of the rows of meta, see
+// ToJsonValue. A cell that is not NOT NULL may be null.
+func (meta *RowMeta) JsonSchema() map[string]interface{} {
+	schema := meta.objectSchema()
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	return schema
+}
+
+func (meta *RowMeta) objectSchema() map[string]interface{} {
+	props := make(map[string]interface{})
+	required := make([]string, 0)
+	for _, item := range meta.GetItems() {
+		if nil == item {
+			continue
+		}
+		props[item.GetName()] = item.jsonSchema()
+		if item.IsNotNull() {
+			required = append(required, item.GetName())
+		}
+	}
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	if meta.comment.value != "" {
+		schema["description"] = meta.comment.value
+	}
+	return schema
+}
+
+func integerSchema(min interface{}, max interface{}) map[string]interface{} {
+	return map[string]interface{}{"type": "integer", "minimum": min, "maximum": max}
+}
+
+func (s *CellMeta) jsonSchema() map[string]interface{} {
+	var schema map[string]interface{}
+	switch s.GetMType() {
+	case ByteType:
+		schema = integerSchema(0, math.MaxUint8)
+	case Int32Type:
+		schema = integerSchema(math.MinInt32, math.MaxInt32)
+	case UInt32Type:
+		schema = integerSchema(0, uint32(math.MaxUint32))
+	case Int64Type:
+		schema = integerSchema(int64(math.MinInt64), int64(math.MaxInt64))
+	case UInt64Type:
+		schema = integerSchema(0, uint64(math.MaxUint64))
+	case Float32Type, Float64Type:
+		schema = map[string]interface{}{"type": "number"}
+	case BoolType:
+		schema = map[string]interface{}{"type": "boolean"}
+	case StringType:
+		schema = map[string]interface{}{"type": "string"}
+	case DecimalType:
+		schema = map[string]interface{}{"type": "string", "pattern": `^[+-]?([0-9]+\.?[0-9]*|\.[0-9]+)$`}
+	case TimeType:
+		format := "date-time"
+		switch s.timeKind {
+		case DateKind:
+			format = "date"
+		case TimeOfDayKind:
+			format = "time"
+		}
+		schema = map[string]interface{}{"type": "string", "format": format}
+	case UUIDType:
+		schema = map[string]interface{}{"type": "string", "format": "uuid"}
+	case BytesType:
+		schema = map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+	case EnumType:
+		labels := make([]interface{}, len(s.enumLabels))
+		for i, label := range s.enumLabels {
+			labels[i] = label
+		}
+		schema = map[string]interface{}{"type": "string", "enum": labels}
+	case ArrayType:
+		schema = map[string]interface{}{"type": "array"}
+		if len(s.subTypes) > 0 {
+			item := NewCellMetaRaw(0, s.subTypes[0], "", "", nil).WithSubType(s.subTypes[1:]...).WithNotNull()
+			schema["items"] = item.jsonSchema()
+		}
+	case StructType:
+		schema = map[string]interface{}{"type": "object"}
+		if nil != s.structMeta {
+			schema = s.structMeta.objectSchema()
+		}
+	case MapType:
+		value := *s
+		value.mType = ValidNewInt32(int32(s.mapValue))
+		value.notNull, value.comment, value.defaultValue = true, NewString(), nil
+		schema = map[string]interface{}{"type": "object", "additionalProperties": value.jsonSchema()}
+	default:
+		//any json
+		schema = map[string]interface{}{}
+	}
+
+	if !s.notNull {
+		if typ, ok := schema["type"].(string); ok {
+			schema["type"] = []string{typ, "null"}
+		}
+		if labels, ok := schema["enum"].([]interface{}); ok {
+			schema["enum"] = append(labels, nil)
+		}
+	}
+	if s.GetComment() != "" {
+		schema["description"] = s.GetComment()
+	}
+	if nil != s.defaultValue {
+		if v, err := ToJsonValue(s.NewCell()); err == nil {
+			schema["default"] = v
+		}
+	}
+	return schema
+}
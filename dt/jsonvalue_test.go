@@ -0,0 +1,125 @@
+package dt
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func newJsonMeta() *RowMeta {
+	address := NewRowMeta()
+	address.AddCellMeta(NewCellMetaRaw(0, StringType, "city", "", nil).WithNotNull())
+	address.AddCellMeta(NewCellMetaRaw(1, Int32Type, "zip", "", nil))
+
+	meta := NewRowMeta()
+	meta.SetComment("orders")
+	meta.AddCellMeta(NewCellMetaRaw(0, Int64Type, "id", "", nil).WithNotNull())
+	meta.AddCellMeta(NewCellMetaRaw(1, DecimalType, "price", "", "9.90").WithDecimal(10, 2))
+	meta.AddCellMeta(NewCellMetaRaw(2, EnumType, "status", "", nil).WithEnumLabels("new", "paid"))
+	meta.AddCellMeta(NewCellMetaRaw(3, StructType, "ship_to", "", nil).WithStructMeta(address))
+	meta.AddCellMeta(NewCellMetaRaw(4, MapType, "tags", "", nil).WithMapTypes(StringType, ArrayType).WithSubType(Int32Type))
+	meta.AddCellMeta(NewCellMetaRaw(5, TimeType, "day", "", nil).WithTimeKind(DateKind))
+	meta.AddCellMeta(NewCellMetaRaw(6, BytesType, "sig", "", nil))
+	meta.AddCellMeta(NewCellMetaRaw(7, UInt64Type, "big", "", nil))
+	meta.AddCellMeta(NewCellMetaRaw(8, JsonType, "extra", "", nil))
+	return meta
+}
+
+func decodeJson(t *testing.T, text string) interface{} {
+	d := json.NewDecoder(strings.NewReader(text))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestCellMeta_FromJsonValue(t *testing.T) {
+	meta := newJsonMeta()
+	text := `{"big":18446744073709551615,"day":"2024-02-29","extra":{"a":[1,2]},"id":7,"price":"12.50",` +
+		`"ship_to":{"city":"Oslo","zip":null},"sig":"AQID","status":"paid","tags":{"odd":[1,3]}}`
+	obj := decodeJson(t, text).(map[string]interface{})
+	out := make(map[string]interface{})
+	for name, v := range obj {
+		cell, err := meta.GetCellMeta(name).FromJsonValue(v)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if out[name], err = ToJsonValue(cell); err != nil {
+			t.Fatal(name, err)
+		}
+	}
+	b, err := json.Marshal(out)
+	if err != nil || string(b) != text {
+		t.Fatal("the json should be the same", string(b), err)
+	}
+
+	price, _ := meta.GetCellMeta("price").FromJsonValue(json.Number("3.456"))
+	if price.(*Decimal).String() != "3.46" {
+		t.Fatal("a number should be a decimal of the scale", price)
+	}
+	sig, _ := meta.GetCellMeta("sig").FromJsonValue("AQID")
+	if !bytes.Equal(sig.GetValue().([]byte), []byte{1, 2, 3}) {
+		t.Fatal("bytes should be base64")
+	}
+	if cell, err := meta.GetCellMeta("id").FromJsonValue(nil); nil != cell || err != nil {
+		t.Fatal("null should be a NULL cell")
+	}
+
+	errs := []struct {
+		name string
+		v    string
+		msg  string
+	}{
+		{"id", `"7"`, "id: json string can not be int64"},
+		{"id", `7.5`, "id: json number can not be int64, "},
+		{"big", `-1`, "big: json number can not be uint64, "},
+		{"status", `"lost"`, "status: json string can not be enum, "},
+		{"ship_to", `{"city":1}`, "ship_to.city: json number can not be string"},
+		{"ship_to", `{"town":"Oslo"}`, "ship_to.town: json string can not be struct, " + ErrStructField.Error()},
+		{"tags", `{"odd":[1,"x"]}`, "tags.odd[1]: json string can not be int32"},
+		{"tags", `{"odd":[null]}`, "tags.odd[0]: json null can not be int32"},
+		{"day", `"yesterday"`, "day: json string can not be time, "},
+		{"sig", `"***"`, "sig: json string can not be bytes, "},
+	}
+	for _, c := range errs {
+		_, err := meta.GetCellMeta(c.name).FromJsonValue(decodeJson(t, c.v))
+		if _, ok := err.(*JsonValueError); !ok || !strings.HasPrefix(err.Error(), c.msg) {
+			t.Fatal(c.name, c.v, "should fail", err)
+		}
+	}
+}
+
+func TestRowMeta_JsonSchema(t *testing.T) {
+	b, err := json.Marshal(newJsonMeta().JsonSchema())
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := decodeJson(t, string(b)).(map[string]interface{})
+	props := schema["properties"].(map[string]interface{})
+	if schema["description"] != "orders" || len(props) != 9 || schema["additionalProperties"] != false {
+		t.Fatal("the schema should have every cell", string(b))
+	}
+	if required := schema["required"].([]interface{}); len(required) != 1 || required[0] != "id" {
+		t.Fatal("a NOT NULL cell should be required", required)
+	}
+
+	expects := map[string]string{
+		"id":      `{"maximum":9223372036854775807,"minimum":-9223372036854775808,"type":"integer"}`,
+		"price":   `{"default":"9.90","pattern":"^[+-]?([0-9]+\\.?[0-9]*|\\.[0-9]+)$","type":["string","null"]}`,
+		"status":  `{"enum":["new","paid",null],"type":["string","null"]}`,
+		"ship_to": `{"additionalProperties":false,"properties":{"city":{"type":"string"},"zip":{"maximum":2147483647,"minimum":-2147483648,"type":["integer","null"]}},"required":["city"],"type":["object","null"]}`,
+		"tags":    `{"additionalProperties":{"items":{"maximum":2147483647,"minimum":-2147483648,"type":"integer"},"type":"array"},"type":["object","null"]}`,
+		"day":     `{"format":"date","type":["string","null"]}`,
+		"sig":     `{"contentEncoding":"base64","type":["string","null"]}`,
+		"extra":   `{}`,
+	}
+	for name, expect := range expects {
+		b, _ := json.Marshal(props[name])
+		if string(b) != expect {
+			t.Fatal(name, "should be", expect, "not", string(b))
+		}
+	}
+}
@@ -16,6 +16,11 @@ const (
 	TimeType
 	ArrayType
 	JsonType
+	BytesType
+	UUIDType
+	EnumType   //the labels are declared by the CellMeta, see WithEnumLabels
+	StructType //the fields are declared by the CellMeta, see WithStructMeta
+	MapType    //the key and value types are declared by the CellMeta, see WithMapTypes
 )
 
 // Encoder is the interface representing objects that can encode themselves.
@@ -45,6 +50,8 @@ type DtRefer interface {
 	GetValue() ValueRefer
 	GetLen() int
 	Copy() DtRefer
+	//DeepCopy copies the values held by a container too, it is Copy for the other types
+	DeepCopy() DtRefer
 }
 
 func NewDtRefer(dType DType) DtRefer {
@@ -74,11 +81,21 @@ func NewDtReferWithSub(dType DType, subType DType) DtRefer {
 	case ArrayType:
 		r = NewArray(subType)
 	case DecimalType:
-	//TODO
+		r = NewDecimal()
 	case TimeType:
-	//TODO
+		r = NewTime()
 	case JsonType:
-		//TODO
+		r = NewJson()
+	case BytesType:
+		r = NewBytes()
+	case UUIDType:
+		r = NewUUID()
+	case EnumType:
+		r = NewEnum(nil)
+	case StructType:
+		r = NewStruct(nil)
+	case MapType:
+		r = NewMap(-1, subType)
 	}
 	return r
 }
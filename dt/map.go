@@ -0,0 +1,289 @@
+package dt
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"sort"
+)
+
+var ErrMapType = errors.New("the type is not allowed by the map")
+
+// Map maps keys of one type to values of one type, the entries are kept in
+// the order of the keys so a map is saved and compared the same way whatever
+// the order it is filled in
+type Map struct {
+	DtRefer
+	keys   []DtRefer
+	values []DtRefer
+	size   *UInt32
+
+	//it's a template that never be saved
+	keyType   DType
+	valueType DType
+	//makes a value, it takes the meta of a nested value, see CellMeta.WithMapTypes
+	newValue func() DtRefer
+}
+
+func NewMap(keyType DType, valueType DType) *Map {
+	return &Map{
+		keyType:   keyType,
+		valueType: valueType,
+		size:      ValidNewUInt32(0),
+		newValue:  func() DtRefer { return NewDtRefer(valueType) },
+	}
+}
+
+// mapKeyType reports the types that can be keys, their go values are comparable
+func mapKeyType(typ DType) bool {
+	switch typ {
+	case ByteType, Int32Type, UInt32Type, Int64Type, UInt64Type, Float32Type, Float64Type,
+		BoolType, StringType, DecimalType, TimeType, UUIDType:
+		return true
+	}
+	return false
+}
+
+func (p *Map) GetTypes() (DType, DType) {
+	return p.keyType, p.valueType
+}
+
+func (p *Map) Len() int {
+	return len(p.keys)
+}
+
+// Keys returns the keys in order, the value of Keys()[i] is Values()[i]
+func (p *Map) Keys() []DtRefer {
+	return p.keys
+}
+
+func (p *Map) Values() []DtRefer {
+	return p.values
+}
+
+func (p *Map) newKey(k ValueRefer) (DtRefer, error) {
+	if !mapKeyType(p.keyType) {
+		return nil, ErrMapType
+	}
+	if d, ok := k.(DtRefer); ok {
+		if typ, _ := TypeOf(d); typ == p.keyType {
+			return d, nil
+		}
+	}
+	key := NewDtRefer(p.keyType)
+	if err := TrySetValue(key, k); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// search returns the index of key and whether it is there
+func (p *Map) search(key DtRefer) (int, bool) {
+	i := sort.Search(len(p.keys), func(i int) bool {
+		return p.keys[i].Compare(key) >= 0
+	})
+	return i, i < len(p.keys) && p.keys[i].Compare(key) == 0
+}
+
+// Get returns the value of k, a k that can not be a key is never found
+func (p *Map) Get(k ValueRefer) (DtRefer, bool) {
+	key, err := p.newKey(k)
+	if err != nil {
+		return nil, false
+	}
+	i, ok := p.search(key)
+	if !ok {
+		return nil, false
+	}
+	return p.values[i], true
+}
+
+// Put casts k and v to the types of the map and sets the value of k
+func (p *Map) Put(k ValueRefer, v ValueRefer) error {
+	key, err := p.newKey(k)
+	if err != nil {
+		return err
+	}
+	value, ok := v.(DtRefer)
+	if typ, _ := TypeOf(value); !ok || typ != p.valueType {
+		value = p.newValue()
+		if nil == value {
+			return ErrMapType
+		}
+		if err := TrySetValue(value, v); err != nil {
+			return err
+		}
+	}
+	i, found := p.search(key)
+	if found {
+		p.values[i] = value
+		return nil
+	}
+	p.keys = append(p.keys, nil)
+	p.values = append(p.values, nil)
+	copy(p.keys[i+1:], p.keys[i:])
+	copy(p.values[i+1:], p.values[i:])
+	p.keys[i], p.values[i] = key, value
+	p.size.SetValue(uint32(len(p.keys)))
+	return nil
+}
+
+// PutAll puts every entry of a go map or a *Map, p is not changed if one of them fails
+func (p *Map) PutAll(v ValueRefer) error {
+	ret := p.Copy().(*Map)
+	if o, ok := v.(*Map); ok {
+		for i := range o.keys {
+			if err := ret.Put(o.keys[i], o.values[i]); err != nil {
+				return err
+			}
+		}
+	} else {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Map {
+			return ErrCastType
+		}
+		for _, k := range rv.MapKeys() {
+			if err := ret.Put(k.Interface(), rv.MapIndex(k).Interface()); err != nil {
+				return err
+			}
+		}
+	}
+	p.keys, p.values = ret.keys, ret.values
+	p.size.SetValue(uint32(len(p.keys)))
+	return nil
+}
+
+// Delete reports whether k was in the map
+func (p *Map) Delete(k ValueRefer) bool {
+	key, err := p.newKey(k)
+	if err != nil {
+		return false
+	}
+	i, ok := p.search(key)
+	if !ok {
+		return false
+	}
+	p.keys = append(p.keys[:i], p.keys[i+1:]...)
+	p.values = append(p.values[:i], p.values[i+1:]...)
+	p.size.SetValue(uint32(len(p.keys)))
+	return true
+}
+
+// Encode saves the size, then every key followed by its value in the order of the keys
+func (p *Map) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	sizeArr, _ := p.size.Encode()
+	buf.Write(sizeArr)
+	for i := range p.keys {
+		b, err := p.keys[i].Encode()
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+		if b, err = p.values[i].Encode(); err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+	}
+	return buf.Bytes(), nil
+}
+
+func (p *Map) Decode(buf []byte, offset int) (int, error) {
+	if !mapKeyType(p.keyType) {
+		return 0, ErrMapType
+	}
+	index, _ := p.size.Decode(buf, offset)
+	length := int(p.size.GetValue().(uint32))
+	p.keys = make([]DtRefer, length)
+	p.values = make([]DtRefer, length)
+	for i := 0; i < length; i++ {
+		key, value := NewDtRefer(p.keyType), p.newValue()
+		if nil == value {
+			return 0, ErrMapType
+		}
+		l, err := key.Decode(buf, offset+index)
+		if err != nil {
+			return 0, err
+		}
+		index += l
+		if l, err = value.Decode(buf, offset+index); err != nil {
+			return 0, err
+		}
+		index += l
+		p.keys[i], p.values[i] = key, value
+	}
+	return index, nil
+}
+
+// set replaces the entries by the entries of a go map or a *Map
+func (p *Map) set(v ValueRefer) error {
+	ret := NewMap(p.keyType, p.valueType)
+	ret.newValue = p.newValue
+	if err := ret.PutAll(v); err != nil {
+		return err
+	}
+	p.keys, p.values = ret.keys, ret.values
+	p.size.SetValue(uint32(len(p.keys)))
+	return nil
+}
+
+// SetValue takes a go map or a *Map, it panics if an entry can not be put
+func (p *Map) SetValue(v ValueRefer) {
+	if err := p.set(v); err != nil {
+		panic(err)
+	}
+}
+
+// GetValue returns a go map of the values of the keys and the entries
+func (p *Map) GetValue() ValueRefer {
+	m := make(map[ValueRefer]ValueRefer, len(p.keys))
+	for i := range p.keys {
+		m[p.keys[i].GetValue()] = p.values[i].GetValue()
+	}
+	return m
+}
+
+func (p *Map) GetLen() int {
+	i := p.size.GetLen()
+	for j := range p.keys {
+		i += p.keys[j].GetLen() + p.values[j].GetLen()
+	}
+	return i
+}
+
+// Copy returns a map that shares the keys and the values with p
+func (p *Map) Copy() DtRefer {
+	ret := &Map{
+		keyType:   p.keyType,
+		valueType: p.valueType,
+		newValue:  p.newValue,
+		size:      ValidNewUInt32(uint32(len(p.keys))),
+		keys:      append([]DtRefer(nil), p.keys...),
+		values:    append([]DtRefer(nil), p.values...),
+	}
+	return ret
+}
+
+func (p *Map) DeepCopy() DtRefer {
+	ret := p.Copy().(*Map)
+	for i := range ret.keys {
+		ret.keys[i] = ret.keys[i].DeepCopy()
+		ret.values[i] = ret.values[i].DeepCopy()
+	}
+	return ret
+}
+
+// Compare orders the maps entry by entry, the key first and then the value,
+// a map is before a longer map that starts with the same entries
+func (p *Map) Compare(v Comparator) int {
+	o := v.(*Map)
+	for i := 0; i < len(p.keys) && i < len(o.keys); i++ {
+		if c := p.keys[i].Compare(o.keys[i]); c != 0 {
+			return c
+		}
+		if c := p.values[i].Compare(o.values[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInt64(int64(len(p.keys)), int64(len(o.keys)))
+}
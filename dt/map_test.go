@@ -0,0 +1,74 @@
+package dt
+
+import "testing"
+
+func TestMap(t *testing.T) {
+	m1 := NewMap(StringType, Int64Type)
+	if err := m1.PutAll(map[string]int{"b": 2, "a": 1, "c": 3}); err != nil {
+		t.Fatal(err)
+	}
+	if m1.Len() != 3 || m1.Keys()[0].GetValue() != "a" || m1.Keys()[2].GetValue() != "c" {
+		t.Fatal("the keys should be in order")
+	}
+	if v, ok := m1.Get("b"); !ok || v.GetValue() != int64(2) {
+		t.Fatal("b should be 2")
+	}
+	if err := m1.Put("d", "x"); err == nil {
+		t.Fatal("x is not an int64")
+	}
+	if err := m1.PutAll(map[string]ValueRefer{"e": 5, "f": "x"}); err == nil || m1.Len() != 3 {
+		t.Fatal("the map should not be changed by a failed PutAll")
+	}
+	m1.Put("b", 20)
+	if v, _ := m1.Get("b"); v.GetValue() != int64(20) || m1.Len() != 3 {
+		t.Fatal("b should be replaced")
+	}
+
+	buf, _ := m1.Encode()
+	if len(buf) != m1.GetLen() {
+		t.Fatal("the len should be the encoded len")
+	}
+	m2 := NewMap(StringType, Int64Type)
+	l, err := m2.Decode(buf, 0)
+	if err != nil || l != len(buf) || m2.Compare(m1) != 0 {
+		t.Fatal("the map should be decoded", err)
+	}
+	if v := m2.GetValue().(map[ValueRefer]ValueRefer); len(v) != 3 || v["b"] != int64(20) {
+		t.Fatal("the values should be decoded,but ", v)
+	}
+
+	if !m2.Delete("a") || m2.Delete("a") || m2.Len() != 2 {
+		t.Fatal("a should be deleted once")
+	}
+	//{b,c} is after {a,b,c} because b is after a
+	if m2.Compare(m1) != 1 || m1.Compare(m2) != -1 {
+		t.Fatal("the maps should be compared by the entries")
+	}
+
+	m3 := NewMap(BytesType, Int64Type)
+	if err := m3.Put([]byte("a"), 1); err != ErrMapType {
+		t.Fatal("bytes can not be a key")
+	}
+}
+
+func TestMap_StructValue(t *testing.T) {
+	cell := NewCellMetaRaw(0, MapType, "addresses", "", nil).
+		WithMapTypes(StringType, StructType).WithStructMeta(newAddressMeta())
+	m1 := cell.NewCell().(*Map)
+	err := m1.Put("home", map[string]ValueRefer{"city": "Paris", "zip": 75001})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, _ := m1.Encode()
+	m2 := cell.NewCell().(*Map)
+	if _, err := m2.Decode(buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	home, _ := m2.Get("home")
+	if zip, _ := home.(*Struct).Field("zip"); zip.GetValue() != int32(75001) {
+		t.Fatal("the struct value should be decoded")
+	}
+	if err := TrySetValue(m2, map[string]ValueRefer{"work": "x"}); err == nil || m2.Len() != 1 {
+		t.Fatal("x is not a struct")
+	}
+}
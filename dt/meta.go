@@ -1,11 +1,54 @@
 package dt
 
+import "time"
+
 type CellMeta struct {
 	pos          *Int32
 	name         *String
 	comment      *String
 	mType        *Int32
 	defaultValue interface{}
+	defaultExpr  string //evaluated by the yard.Row, see WithDefaultExpr
+
+	//the declared type of DecimalType, see WithDecimal
+	precision int
+	scale     int
+
+	//the declared type of TimeType, see WithTimeKind
+	timeKind TimeKind
+
+	//the item types of ArrayType, see WithSubType
+	subTypes []DType
+
+	//the order of StringType, see WithCollation
+	collation Collation
+
+	//the encoding of the integer types, see WithEncoding
+	encoding IntEncoding
+
+	//the allowed labels of EnumType, see WithEnumLabels
+	enumLabels []string
+
+	//the fields of StructType, see WithStructMeta
+	structMeta *RowMeta
+
+	//the key and value types of MapType, see WithMapTypes
+	mapKey   DType
+	mapValue DType
+
+	//fills the cell on insert, see WithAutoIncrement
+	autoIncrement Sequence
+	sequenceName  string
+
+	//the constraints of the cell, see WithNotNull, WithUnique and WithCheck
+	notNull bool
+	unique  bool
+	check   string
+}
+
+// Sequence hands out the values of an auto-increment cell
+type Sequence interface {
+	NextValue() (int64, error)
 }
 
 func NewCellMetaRaw(pos int, typ DType, name string, comment string, defaultValue interface{}) *CellMeta {
@@ -48,8 +91,159 @@ func (s *CellMeta) GetDefaultValue() interface{} {
 	return s.defaultValue
 }
 
+// WithDefaultExpr makes the default value of the cell an expression of the
+// expr package, like "now()", "uuid()", "nextval('ids')" or "price * qty".
+// It is evaluated when a row is inserted with the cell NULL, instead of the
+// default value.
+func (s *CellMeta) WithDefaultExpr(e string) *CellMeta {
+	s.defaultExpr = e
+	return s
+}
+
+func (s *CellMeta) GetDefaultExpr() string {
+	return s.defaultExpr
+}
+
+// WithDecimal declares the precision and the scale of a DecimalType cell
+func (s *CellMeta) WithDecimal(precision int, scale int) *CellMeta {
+	s.precision = precision
+	s.scale = scale
+	return s
+}
+
+// GetDecimal returns the declared precision and scale, 0 means no limit
+func (s *CellMeta) GetDecimal() (int, int) {
+	return s.precision, s.scale
+}
+
+// WithTimeKind declares the kind of a TimeType cell, TimestampKind by default
+func (s *CellMeta) WithTimeKind(kind TimeKind) *CellMeta {
+	s.timeKind = kind
+	return s
+}
+
+func (s *CellMeta) GetTimeKind() TimeKind {
+	return s.timeKind
+}
+
+// WithSubType declares the item type of an ArrayType cell, an array of
+// arrays is declared by the item types of every level
+func (s *CellMeta) WithSubType(types ...DType) *CellMeta {
+	s.subTypes = types
+	return s
+}
+
+func (s *CellMeta) GetSubType() []DType {
+	return s.subTypes
+}
+
+// WithCollation declares the order of a StringType cell, CollateNoCase by default
+func (s *CellMeta) WithCollation(c Collation) *CellMeta {
+	s.collation = c
+	return s
+}
+
+func (s *CellMeta) GetCollation() Collation {
+	return s.collation
+}
+
+// WithEncoding declares how an Int32Type, UInt32Type, Int64Type or UInt64Type
+// cell is saved, FixedEncoding by default
+func (s *CellMeta) WithEncoding(enc IntEncoding) *CellMeta {
+	s.encoding = enc
+	return s
+}
+
+func (s *CellMeta) GetEncoding() IntEncoding {
+	return s.encoding
+}
+
+// WithEnumLabels declares the labels allowed by an EnumType cell in their order
+func (s *CellMeta) WithEnumLabels(labels ...string) *CellMeta {
+	s.enumLabels = labels
+	return s
+}
+
+func (s *CellMeta) GetEnumLabels() []string {
+	return s.enumLabels
+}
+
+// WithStructMeta declares the fields of a StructType cell
+func (s *CellMeta) WithStructMeta(meta *RowMeta) *CellMeta {
+	s.structMeta = meta
+	return s
+}
+
+func (s *CellMeta) GetStructMeta() *RowMeta {
+	return s.structMeta
+}
+
+// WithMapTypes declares the key and the value types of a MapType cell. A value
+// of a type that is declared by the CellMeta, like a struct or an enum, takes
+// the other declarations of the cell.
+func (s *CellMeta) WithMapTypes(keyType DType, valueType DType) *CellMeta {
+	s.mapKey = keyType
+	s.mapValue = valueType
+	return s
+}
+
+func (s *CellMeta) GetMapTypes() (DType, DType) {
+	return s.mapKey, s.mapValue
+}
+
+// WithAutoIncrement makes the cell take the next value of seq when a row
+// is inserted without it
+func (s *CellMeta) WithAutoIncrement(seq Sequence) *CellMeta {
+	s.autoIncrement = seq
+	if named, ok := seq.(interface {
+		GetName() string
+	}); ok {
+		s.sequenceName = named.GetName()
+	}
+	return s
+}
+
+// GetAutoIncrement returns nil if the cell is not auto-increment
+func (s *CellMeta) GetAutoIncrement() Sequence {
+	return s.autoIncrement
+}
+
+// GetAutoIncrementName returns the name of the sequence, a decoded RowMeta
+// only knows the name until WithAutoIncrement is called again
+func (s *CellMeta) GetAutoIncrementName() string {
+	return s.sequenceName
+}
+
+func (s *CellMeta) newDtRefer() DtRefer {
+	switch DType(s.mType.value) {
+	case Int32Type, UInt32Type, Int64Type, UInt64Type:
+		return NewIntWithEncoding(DType(s.mType.value), s.encoding)
+	case DecimalType:
+		return NewDecimalWithPrecision(s.precision, s.scale)
+	case StringType:
+		return NewStringWithCollation(s.collation)
+	case TimeType:
+		return NewTimeWithKind(s.timeKind)
+	case EnumType:
+		return NewEnum(s.enumLabels)
+	case StructType:
+		return NewStruct(s.structMeta)
+	case MapType:
+		m := NewMap(s.mapKey, s.mapValue)
+		value := *s
+		value.mType = ValidNewInt32(int32(s.mapValue))
+		m.newValue = value.newDtRefer
+		return m
+	case ArrayType:
+		if len(s.subTypes) > 0 {
+			return NewArray(s.subTypes[0], s.subTypes[1:]...)
+		}
+	}
+	return NewDtRefer(DType(s.mType.value))
+}
+
 func (s *CellMeta) NewCell() DtRefer {
-	r := NewDtRefer(DType(s.mType.value))
+	r := s.newDtRefer()
 	if s.defaultValue != nil {
 		r.SetValue(s.defaultValue)
 	}
@@ -59,6 +253,32 @@ func (s *CellMeta) NewCell() DtRefer {
 type RowMeta struct {
 	items   []*CellMeta
 	comment *String
+
+	//the rows expire ttl after the value of expire, see SetTTL
+	expire *CellMeta
+	ttl    time.Duration
+
+	//the constraints of more than one cell, see AddUnique and AddCheck
+	constraints []*Constraint
+
+	//finds the sequences of the default expressions, see WithSequences
+	sequences SequenceSource
+}
+
+// SequenceSource finds a sequence by name, it returns nil if there is no such sequence
+type SequenceSource interface {
+	LookupSequence(name string) Sequence
+}
+
+// WithSequences makes nextval of the default expressions use the sequences of src
+func (meta *RowMeta) WithSequences(src SequenceSource) *RowMeta {
+	meta.sequences = src
+	return meta
+}
+
+// GetSequences returns nil if the RowMeta has no SequenceSource
+func (meta *RowMeta) GetSequences() SequenceSource {
+	return meta.sequences
 }
 
 func NewRowMeta() *RowMeta {
@@ -89,6 +309,29 @@ func (meta *RowMeta) GetItems() []*CellMeta {
 	return meta.items
 }
 
+// GetCellMeta returns nil if there is no cell of the name
+func (meta *RowMeta) GetCellMeta(name string) *CellMeta {
+	for _, item := range meta.items {
+		if nil != item && item.GetName() == name {
+			return item
+		}
+	}
+	return nil
+}
+
 func (meta *RowMeta) GetCellSize() int {
 	return len(meta.items)
 }
+
+// SetTTL makes the rows expire. The cell holds a time, as TimeType or as
+// time.Now().UnixNano() in Int64Type or UInt64Type, and a row expires ttl after it. A zero ttl
+// means the cell holds the expire time of each row.
+func (meta *RowMeta) SetTTL(cell *CellMeta, ttl time.Duration) {
+	meta.expire = cell
+	meta.ttl = ttl
+}
+
+// GetTTL returns nil if the rows never expire
+func (meta *RowMeta) GetTTL() (*CellMeta, time.Duration) {
+	return meta.expire, meta.ttl
+}
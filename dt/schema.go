@@ -0,0 +1,258 @@
+package dt
+
+import (
+	"bytes"
+	"errors"
+	"time"
+)
+
+// the version of the encoding of a RowMeta, it is increased when a field is added
+const schemaVersion byte = 2
+
+var ErrSchemaVersion = errors.New("the schema is written by a newer version")
+
+func encodeTo(buf *bytes.Buffer, items ...DtRefer) error {
+	for _, item := range items {
+		b, err := item.Encode()
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+	}
+	return nil
+}
+
+func decodeFrom(buf []byte, idx int, items ...DtRefer) (int, error) {
+	for _, item := range items {
+		l, err := item.Decode(buf, idx)
+		if err != nil {
+			return idx, err
+		}
+		idx += l
+	}
+	return idx, nil
+}
+
+func encodeStrings(buf *bytes.Buffer, strs []string) {
+	encodeTo(buf, ValidNewUInt32(uint32(len(strs))))
+	for _, s := range strs {
+		encodeTo(buf, ValidNewString(s))
+	}
+}
+
+func decodeStrings(buf []byte, idx int) ([]string, int, error) {
+	count := NewUInt32()
+	idx, err := decodeFrom(buf, idx, count)
+	if err != nil {
+		return nil, idx, err
+	}
+	var strs []string
+	for i := uint32(0); i < count.value; i++ {
+		s := NewString()
+		if idx, err = decodeFrom(buf, idx, s); err != nil {
+			return nil, idx, err
+		}
+		strs = append(strs, s.value)
+	}
+	return strs, idx, nil
+}
+
+// Encode saves the cells with their declarations and constraints, the TTL and
+// the constraints of the RowMeta. An auto-increment cell only saves the name of
+// its sequence, see GetAutoIncrementName.
+func (meta *RowMeta) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.WriteByte(schemaVersion)
+	encodeTo(buf, meta.comment, ValidNewUInt32(uint32(len(meta.items))))
+	for _, item := range meta.items {
+		encodeTo(buf, ValidNewBool(nil != item))
+		if nil == item {
+			continue
+		}
+		if err := item.encode(buf); err != nil {
+			return nil, err
+		}
+	}
+
+	expire := int32(-1)
+	if nil != meta.expire {
+		expire = int32(meta.expire.GetPos())
+	}
+	encodeTo(buf, ValidNewInt32(expire), ValidInt64(int64(meta.ttl)))
+
+	encodeTo(buf, ValidNewUInt32(uint32(len(meta.constraints))))
+	for _, c := range meta.constraints {
+		encodeTo(buf, ValidNewString(c.Name), ValidNewByte(byte(c.Kind)))
+		encodeStrings(buf, c.Columns)
+		encodeTo(buf, ValidNewString(c.Check))
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *CellMeta) encode(buf *bytes.Buffer) error {
+	encodeTo(buf, s.pos, s.name, s.comment, s.mType,
+		ValidNewInt32(int32(s.precision)), ValidNewInt32(int32(s.scale)),
+		ValidNewByte(byte(s.timeKind)), ValidNewByte(byte(s.collation)), ValidNewByte(byte(s.encoding)))
+
+	encodeTo(buf, ValidNewUInt32(uint32(len(s.subTypes))))
+	for _, typ := range s.subTypes {
+		encodeTo(buf, ValidNewInt32(int32(typ)))
+	}
+	encodeStrings(buf, s.enumLabels)
+
+	encodeTo(buf, ValidNewBool(nil != s.structMeta))
+	if nil != s.structMeta {
+		b, err := s.structMeta.Encode()
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+	}
+	encodeTo(buf, ValidNewInt32(int32(s.mapKey)), ValidNewInt32(int32(s.mapValue)))
+
+	encodeTo(buf, ValidNewBool(s.notNull), ValidNewBool(s.unique), ValidNewString(s.check),
+		ValidNewString(s.GetAutoIncrementName()))
+
+	encodeTo(buf, ValidNewBool(nil != s.defaultValue))
+	if nil != s.defaultValue {
+		cell := s.newDtRefer()
+		if err := TrySetValue(cell, s.defaultValue); err != nil {
+			return err
+		}
+		if err := encodeTo(buf, cell); err != nil {
+			return err
+		}
+	}
+	//since version 2
+	return encodeTo(buf, ValidNewString(s.defaultExpr))
+}
+
+func (meta *RowMeta) Decode(buf []byte, offset int) (int, error) {
+	if buf[offset] > schemaVersion {
+		return 0, ErrSchemaVersion
+	}
+	count := NewUInt32()
+	idx, err := decodeFrom(buf, offset+1, meta.comment, count)
+	if err != nil {
+		return 0, err
+	}
+	meta.items = make([]*CellMeta, count.value)
+	for i := range meta.items {
+		present := NewBool()
+		if idx, err = decodeFrom(buf, idx, present); err != nil {
+			return 0, err
+		}
+		if !present.value {
+			continue
+		}
+		item := &CellMeta{}
+		if idx, err = item.decode(buf, idx, buf[offset]); err != nil {
+			return 0, err
+		}
+		meta.items[i] = item
+	}
+
+	expire, ttl := NewInt32(), NewInt64()
+	if idx, err = decodeFrom(buf, idx, expire, ttl); err != nil {
+		return 0, err
+	}
+	meta.expire, meta.ttl = nil, time.Duration(ttl.value)
+	if expire.value >= 0 && int(expire.value) < len(meta.items) {
+		meta.expire = meta.items[expire.value]
+	}
+
+	if idx, err = decodeFrom(buf, idx, count); err != nil {
+		return 0, err
+	}
+	meta.constraints = nil
+	for i := uint32(0); i < count.value; i++ {
+		name, kind, check := NewString(), NewByte(), NewString()
+		if idx, err = decodeFrom(buf, idx, name, kind); err != nil {
+			return 0, err
+		}
+		c := &Constraint{Name: name.value, Kind: ConstraintKind(kind.value)}
+		if c.Columns, idx, err = decodeStrings(buf, idx); err != nil {
+			return 0, err
+		}
+		if idx, err = decodeFrom(buf, idx, check); err != nil {
+			return 0, err
+		}
+		c.Check = check.value
+		meta.constraints = append(meta.constraints, c)
+	}
+	return idx - offset, nil
+}
+
+func (s *CellMeta) decode(buf []byte, idx int, version byte) (int, error) {
+	s.pos, s.name, s.comment, s.mType = NewInt32(), NewString(), NewString(), NewInt32()
+	precision, scale := NewInt32(), NewInt32()
+	timeKind, collation, encoding := NewByte(), NewByte(), NewByte()
+	idx, err := decodeFrom(buf, idx, s.pos, s.name, s.comment, s.mType, precision, scale, timeKind, collation, encoding)
+	if err != nil {
+		return idx, err
+	}
+	s.precision, s.scale = int(precision.value), int(scale.value)
+	s.timeKind, s.collation, s.encoding = TimeKind(timeKind.value), Collation(collation.value), IntEncoding(encoding.value)
+
+	count := NewUInt32()
+	if idx, err = decodeFrom(buf, idx, count); err != nil {
+		return idx, err
+	}
+	s.subTypes = nil
+	for i := uint32(0); i < count.value; i++ {
+		typ := NewInt32()
+		if idx, err = decodeFrom(buf, idx, typ); err != nil {
+			return idx, err
+		}
+		s.subTypes = append(s.subTypes, DType(typ.value))
+	}
+	if s.enumLabels, idx, err = decodeStrings(buf, idx); err != nil {
+		return idx, err
+	}
+
+	hasStruct := NewBool()
+	if idx, err = decodeFrom(buf, idx, hasStruct); err != nil {
+		return idx, err
+	}
+	if hasStruct.value {
+		s.structMeta = NewRowMeta()
+		l, err := s.structMeta.Decode(buf, idx)
+		if err != nil {
+			return idx, err
+		}
+		idx += l
+	}
+	mapKey, mapValue := NewInt32(), NewInt32()
+	if idx, err = decodeFrom(buf, idx, mapKey, mapValue); err != nil {
+		return idx, err
+	}
+	s.mapKey, s.mapValue = DType(mapKey.value), DType(mapValue.value)
+
+	notNull, unique, check, sequence := NewBool(), NewBool(), NewString(), NewString()
+	if idx, err = decodeFrom(buf, idx, notNull, unique, check, sequence); err != nil {
+		return idx, err
+	}
+	s.notNull, s.unique, s.check, s.sequenceName = notNull.value, unique.value, check.value, sequence.value
+
+	hasDefault := NewBool()
+	if idx, err = decodeFrom(buf, idx, hasDefault); err != nil {
+		return idx, err
+	}
+	s.defaultValue = nil
+	if hasDefault.value {
+		cell := s.newDtRefer()
+		if idx, err = decodeFrom(buf, idx, cell); err != nil {
+			return idx, err
+		}
+		s.defaultValue = cell.GetValue()
+	}
+	s.defaultExpr = ""
+	if version >= 2 {
+		e := NewString()
+		if idx, err = decodeFrom(buf, idx, e); err != nil {
+			return idx, err
+		}
+		s.defaultExpr = e.value
+	}
+	return idx, nil
+}
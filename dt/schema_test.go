@@ -0,0 +1,77 @@
+package dt
+
+import (
+	"testing"
+	"time"
+)
+
+type namedSequence struct{}
+
+func (namedSequence) NextValue() (int64, error) { return 1, nil }
+func (namedSequence) GetName() string           { return "ids" }
+
+func TestRowMeta_Encode(t *testing.T) {
+	address := NewRowMeta()
+	address.AddCellMeta(NewCellMetaRaw(0, StringType, "city", "", nil).WithNotNull())
+
+	meta := NewRowMeta()
+	meta.SetComment("orders")
+	meta.AddCellMeta(NewCellMetaRaw(0, Int64Type, "id", "the key", nil).
+		WithEncoding(OrderedVarintEncoding).WithAutoIncrement(namedSequence{}).WithUnique())
+	meta.AddCellMeta(NewCellMetaRaw(1, DecimalType, "price", "", "9.90").WithDecimal(10, 2).WithCheck("price > 0"))
+	meta.AddCellMeta(NewCellMetaRaw(3, EnumType, "status", "", "new").WithEnumLabels("new", "paid"))
+	meta.AddCellMeta(NewCellMetaRaw(4, StructType, "ship_to", "", nil).WithStructMeta(address))
+	meta.AddCellMeta(NewCellMetaRaw(5, MapType, "tags", "", nil).WithMapTypes(StringType, ArrayType).WithSubType(Int32Type))
+	at := NewCellMetaRaw(6, TimeType, "at", "", nil).WithTimeKind(TimestampTZKind).WithDefaultExpr("now()")
+	meta.AddCellMeta(at)
+	meta.AddCellMeta(NewCellMetaRaw(7, StringType, "name", "", nil).WithCollation(CollateUnicode))
+	meta.SetTTL(at, time.Hour)
+	meta.AddUnique("name_at", "name", "at")
+
+	buf, err := meta.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m2 := NewRowMeta()
+	l, err := m2.Decode(buf, 0)
+	if err != nil || l != len(buf) {
+		t.Fatal("the meta should be decoded", err)
+	}
+	if b, _ := m2.Encode(); string(b) != string(buf) {
+		t.Fatal("the decoded meta should be encoded the same")
+	}
+
+	if m2.comment.value != "orders" || m2.GetCellSize() != 8 || nil != m2.GetItems()[2] {
+		t.Fatal("the cells should be decoded")
+	}
+	id := m2.GetCellMeta("id")
+	if id.GetEncoding() != OrderedVarintEncoding || id.GetAutoIncrementName() != "ids" || nil != id.GetAutoIncrement() ||
+		!id.IsUnique() || id.GetComment() != "the key" {
+		t.Fatal("id should be decoded")
+	}
+	price := m2.GetCellMeta("price")
+	if p, s := price.GetDecimal(); p != 10 || s != 2 || price.GetDefaultValue() != "9.90" || price.GetCheck() != "price > 0" {
+		t.Fatal("price should be decoded")
+	}
+	if m2.GetCellMeta("status").NewCell().GetValue() != "new" {
+		t.Fatal("status should be decoded")
+	}
+	if m2.GetCellMeta("ship_to").GetStructMeta().GetCellMeta("city").IsNotNull() != true {
+		t.Fatal("the struct meta should be decoded")
+	}
+	if k, v := m2.GetCellMeta("tags").GetMapTypes(); k != StringType || v != ArrayType {
+		t.Fatal("the map types should be decoded")
+	}
+	if cell, ttl := m2.GetTTL(); cell != m2.GetCellMeta("at") || ttl != time.Hour ||
+		cell.GetTimeKind() != TimestampTZKind || cell.GetDefaultExpr() != "now()" {
+		t.Fatal("the ttl should be decoded")
+	}
+	if m2.GetCellMeta("name").GetCollation() != CollateUnicode || len(m2.GetConstraints()) != 3 {
+		t.Fatal("the constraints should be decoded")
+	}
+
+	buf[0] = schemaVersion + 1
+	if _, err := NewRowMeta().Decode(buf, 0); err != ErrSchemaVersion {
+		t.Fatal("a newer schema should not be decoded")
+	}
+}
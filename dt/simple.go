@@ -5,7 +5,6 @@ import (
 	"encoding/binary"
 	"errors"
 	"math"
-	"strings"
 )
 
 type Byte struct {
@@ -22,12 +21,14 @@ type AtomicBool struct {
 }
 type Int32 struct {
 	DtRefer
-	value int32
+	value    int32
+	encoding IntEncoding //never saved, it comes from the CellMeta
 }
 
 type Int64 struct {
 	DtRefer
-	value int64
+	value    int64
+	encoding IntEncoding //never saved, it comes from the CellMeta
 }
 
 type Float32 struct {
@@ -42,17 +43,20 @@ type Float64 struct {
 
 type UInt32 struct {
 	DtRefer
-	value uint32
+	value    uint32
+	encoding IntEncoding //never saved, it comes from the CellMeta
 }
 
 type UInt64 struct {
 	DtRefer
-	value uint64
+	value    uint64
+	encoding IntEncoding //never saved, it comes from the CellMeta
 }
 
 type String struct {
 	DtRefer
-	value string
+	value     string
+	collation Collation //never saved, it comes from the CellMeta
 }
 
 func NewInt32() *Int32 {
@@ -82,6 +86,22 @@ func NewAtomicBool() *AtomicBool {
 func NewByte() *Byte {
 	return ValidNewByte(0x0)
 }
+
+// NewIntWithEncoding returns the zero Int32, UInt32, Int64 or UInt64 saved by enc
+func NewIntWithEncoding(typ DType, enc IntEncoding) DtRefer {
+	switch typ {
+	case Int32Type:
+		return &Int32{encoding: enc}
+	case UInt32Type:
+		return &UInt32{encoding: enc}
+	case Int64Type:
+		return &Int64{encoding: enc}
+	case UInt64Type:
+		return &UInt64{encoding: enc}
+	}
+	return NewDtRefer(typ)
+}
+
 func NewString() *String {
 	return ValidNewString("")
 }
@@ -119,6 +139,10 @@ func ValidNewString(v string) *String {
 	return &String{value: v}
 }
 
+func NewStringWithCollation(c Collation) *String {
+	return &String{collation: c}
+}
+
 func (p *Bool) Encode() ([]byte, error) {
 	var b byte = 0x0
 	if p.value {
@@ -149,11 +173,21 @@ func (p *Bool) Copy() DtRefer {
 	ret.value = p.value
 	return ret
 }
+func (p *Bool) DeepCopy() DtRefer {
+	return p.Copy()
+}
+
+// Compare orders false before true
 func (p *Bool) Compare(v Comparator) int {
-	if p.value == v.(*Bool).value {
+	ov := v.(*Bool).value
+	switch {
+	case p.value == ov:
 		return 0
+	case ov:
+		return -1
+	default:
+		return 1
 	}
-	return 1
 }
 
 func (p *Byte) Encode() ([]byte, error) {
@@ -183,8 +217,14 @@ func (p *Byte) Copy() DtRefer {
 	ret.value = p.value
 	return ret
 }
+func (p *Byte) DeepCopy() DtRefer {
+	return p.Copy()
+}
 
 func (p *Byte) Compare(v Comparator) int {
+	if _, ok := v.(*Byte); !ok && isNumeric(v) {
+		return compareNumeric(p, v.(DtRefer))
+	}
 	ov := v.(*Byte).value
 	switch {
 	case p.value == ov:
@@ -239,22 +279,41 @@ func (p *AtomicBool) Copy() DtRefer {
 	ret.value = p.value
 	return ret
 }
+func (p *AtomicBool) DeepCopy() DtRefer {
+	return p.Copy()
+}
+
+// Compare orders false before true
 func (p *AtomicBool) Compare(v Comparator) int {
 	ov := v.(*AtomicBool).value
-
-	if ov == p.value {
+	switch {
+	case p.value == ov:
 		return 0
+	case p.value < ov:
+		return -1
+	default:
+		return 1
 	}
-	return 1
 }
 
 func (p *Int32) Encode() ([]byte, error) {
+	if p.encoding != FixedEncoding {
+		return encodeVarint(p.encoding, int64(p.value)), nil
+	}
 	buf := make([]byte, 4)
 	binary.BigEndian.PutUint32(buf, uint32(p.value))
 	return buf, nil
 }
 
 func (p *Int32) Decode(buf []byte, offset int) (int, error) {
+	if p.encoding != FixedEncoding {
+		v, l, err := decodeVarint(p.encoding, buf, offset)
+		if err == nil && (v < math.MinInt32 || v > math.MaxInt32) {
+			err = ErrVarintOverflow
+		}
+		p.value = int32(v)
+		return l, err
+	}
 	p.value = int32(binary.BigEndian.Uint32(buf[offset : offset+4]))
 	return 4, nil
 }
@@ -268,14 +327,24 @@ func (p *Int32) GetValue() ValueRefer {
 }
 
 func (p *Int32) GetLen() int {
+	if p.encoding != FixedEncoding {
+		return varintLen(p.encoding, int64(p.value))
+	}
 	return 4
 }
 func (p *Int32) Copy() DtRefer {
 	ret := NewInt32()
 	ret.value = p.value
+	ret.encoding = p.encoding
 	return ret
 }
+func (p *Int32) DeepCopy() DtRefer {
+	return p.Copy()
+}
 func (p *Int32) Compare(v Comparator) int {
+	if _, ok := v.(*Int32); !ok && isNumeric(v) {
+		return compareNumeric(p, v.(DtRefer))
+	}
 	ov := v.(*Int32).value
 	switch {
 	case p.value == ov:
@@ -289,12 +358,23 @@ func (p *Int32) Compare(v Comparator) int {
 }
 
 func (p *UInt32) Encode() ([]byte, error) {
+	if p.encoding != FixedEncoding {
+		return encodeUvarint(p.encoding, uint64(p.value)), nil
+	}
 	buf := make([]byte, 4)
 	binary.BigEndian.PutUint32(buf, p.value)
 	return buf, nil
 }
 
 func (p *UInt32) Decode(buf []byte, offset int) (int, error) {
+	if p.encoding != FixedEncoding {
+		v, l, err := decodeUvarint(p.encoding, buf, offset)
+		if err == nil && v > math.MaxUint32 {
+			err = ErrVarintOverflow
+		}
+		p.value = uint32(v)
+		return l, err
+	}
 	p.value = binary.BigEndian.Uint32(buf[offset : offset+4])
 	return 4, nil
 }
@@ -308,15 +388,25 @@ func (p *UInt32) GetValue() ValueRefer {
 }
 
 func (p *UInt32) GetLen() int {
+	if p.encoding != FixedEncoding {
+		return uvarintLen(p.encoding, uint64(p.value))
+	}
 	return 4
 }
 
 func (p *UInt32) Copy() DtRefer {
 	ret := NewUInt32()
 	ret.value = p.value
+	ret.encoding = p.encoding
 	return ret
 }
+func (p *UInt32) DeepCopy() DtRefer {
+	return p.Copy()
+}
 func (p *UInt32) Compare(v Comparator) int {
+	if _, ok := v.(*UInt32); !ok && isNumeric(v) {
+		return compareNumeric(p, v.(DtRefer))
+	}
 	ov := v.(*UInt32).value
 	switch {
 	case p.value == ov:
@@ -329,12 +419,20 @@ func (p *UInt32) Compare(v Comparator) int {
 }
 
 func (p *Int64) Encode() ([]byte, error) {
+	if p.encoding != FixedEncoding {
+		return encodeVarint(p.encoding, p.value), nil
+	}
 	buf := make([]byte, 8)
 	binary.BigEndian.PutUint64(buf, uint64(p.value))
 	return buf, nil
 }
 
 func (p *Int64) Decode(buf []byte, offset int) (int, error) {
+	if p.encoding != FixedEncoding {
+		v, l, err := decodeVarint(p.encoding, buf, offset)
+		p.value = v
+		return l, err
+	}
 	p.value = int64(binary.BigEndian.Uint64(buf[offset : offset+8]))
 	return 8, nil
 }
@@ -348,15 +446,25 @@ func (p *Int64) GetValue() ValueRefer {
 }
 
 func (p *Int64) GetLen() int {
+	if p.encoding != FixedEncoding {
+		return varintLen(p.encoding, p.value)
+	}
 	return 8
 }
 func (p *Int64) Copy() DtRefer {
 	ret := NewInt64()
 	ret.value = p.value
+	ret.encoding = p.encoding
 	return ret
 }
+func (p *Int64) DeepCopy() DtRefer {
+	return p.Copy()
+}
 
 func (p *Int64) Compare(v Comparator) int {
+	if _, ok := v.(*Int64); !ok && isNumeric(v) {
+		return compareNumeric(p, v.(DtRefer))
+	}
 	ov := v.(*Int64).value
 	switch {
 	case p.value == ov:
@@ -369,12 +477,20 @@ func (p *Int64) Compare(v Comparator) int {
 }
 
 func (p *UInt64) Encode() ([]byte, error) {
+	if p.encoding != FixedEncoding {
+		return encodeUvarint(p.encoding, p.value), nil
+	}
 	buf := make([]byte, 8)
 	binary.BigEndian.PutUint64(buf, p.value)
 	return buf, nil
 }
 
 func (p *UInt64) Decode(buf []byte, offset int) (int, error) {
+	if p.encoding != FixedEncoding {
+		v, l, err := decodeUvarint(p.encoding, buf, offset)
+		p.value = v
+		return l, err
+	}
 	p.value = binary.BigEndian.Uint64(buf[offset : offset+8])
 	return 8, nil
 }
@@ -388,15 +504,25 @@ func (p *UInt64) GetValue() ValueRefer {
 }
 
 func (p *UInt64) GetLen() int {
+	if p.encoding != FixedEncoding {
+		return uvarintLen(p.encoding, p.value)
+	}
 	return 8
 }
 func (p *UInt64) Copy() DtRefer {
 	ret := NewUInt64()
 	ret.value = p.value
+	ret.encoding = p.encoding
 	return ret
 }
+func (p *UInt64) DeepCopy() DtRefer {
+	return p.Copy()
+}
 
 func (p *UInt64) Compare(v Comparator) int {
+	if _, ok := v.(*UInt64); !ok && isNumeric(v) {
+		return compareNumeric(p, v.(DtRefer))
+	}
 	ov := v.(*UInt64).value
 	switch {
 	case p.value == ov:
@@ -438,10 +564,20 @@ func (p *Float32) Copy() DtRefer {
 	ret.value = p.value
 	return ret
 }
+func (p *Float32) DeepCopy() DtRefer {
+	return p.Copy()
+}
 
+// Compare orders NaN after all the numbers and equal to itself
 func (p *Float32) Compare(v Comparator) int {
+	if _, ok := v.(*Float32); !ok && isNumeric(v) {
+		return compareNumeric(p, v.(DtRefer))
+	}
 	ov := v.(*Float32).value
+	pNaN, oNaN := p.value != p.value, ov != ov
 	switch {
+	case pNaN || oNaN:
+		return compareNaN(pNaN, oNaN)
 	case p.value == ov:
 		return 0
 	case p.value < ov:
@@ -481,10 +617,20 @@ func (p *Float64) Copy() DtRefer {
 	ret.value = p.value
 	return ret
 }
+func (p *Float64) DeepCopy() DtRefer {
+	return p.Copy()
+}
 
+// Compare orders NaN after all the numbers and equal to itself
 func (p *Float64) Compare(v Comparator) int {
+	if _, ok := v.(*Float64); !ok && isNumeric(v) {
+		return compareNumeric(p, v.(DtRefer))
+	}
 	ov := v.(*Float64).value
+	pNaN, oNaN := p.value != p.value, ov != ov
 	switch {
+	case pNaN || oNaN:
+		return compareNaN(pNaN, oNaN)
 	case p.value == ov:
 		return 0
 	case p.value < ov:
@@ -579,14 +725,35 @@ func (p *String) GetValue() ValueRefer {
 	return p.value
 }
 func (p *String) Copy() DtRefer {
-	ret := NewString()
+	ret := NewStringWithCollation(p.collation)
 	ret.value = p.value
 	return ret
 }
+func (p *String) DeepCopy() DtRefer {
+	return p.Copy()
+}
 
+func (p *String) GetCollation() Collation {
+	return p.collation
+}
+
+// Compare orders the strings by the collation of p
 func (p *String) Compare(v Comparator) int {
-	if strings.EqualFold(p.value, v.(*String).value) {
+	return compareCollated(p.collation, p.value, v.(*String).value)
+}
+
+// CollationKey returns bytes that compare in the same order as Compare
+func (p *String) CollationKey() []byte {
+	return collationKey(p.collation, p.value)
+}
+
+func compareNaN(pNaN bool, oNaN bool) int {
+	switch {
+	case pNaN && oNaN:
 		return 0
+	case pNaN:
+		return 1
+	default:
+		return -1
 	}
-	return 1
 }
@@ -2,6 +2,7 @@ package dt
 
 import (
 	"bytes"
+	"math"
 	"testing"
 )
 
@@ -197,3 +198,30 @@ func TestDefaultIndexRowMeta(t *testing.T) {
 		t.Fatal("meta type should be PTypeUInt32")
 	}
 }
+
+func TestTotalOrder(t *testing.T) {
+	nan := math.NaN()
+	floats := []float64{math.Inf(-1), -1, 0, 1, math.Inf(1), nan}
+	for i := range floats {
+		for j := range floats {
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			if ValidNewFloat64(floats[i]).Compare(ValidNewFloat64(floats[j])) != want {
+				t.Fatal(floats[i], " compare ", floats[j], " should be ", want)
+			}
+			if ValidNewFloat32(float32(floats[i])).Compare(ValidNewFloat32(float32(floats[j]))) != want {
+				t.Fatal(floats[i], " compare ", floats[j], " should be ", want)
+			}
+		}
+	}
+	if ValidNewBool(false).Compare(ValidNewBool(true)) != -1 || ValidNewBool(true).Compare(ValidNewBool(false)) != 1 {
+		t.Fatal("false should be before true")
+	}
+	if ValidNewAtomicBool(false).Compare(ValidNewAtomicBool(true)) != -1 {
+		t.Fatal("false should be before true")
+	}
+}
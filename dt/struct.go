@@ -0,0 +1,241 @@
+package dt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var ErrStructField = errors.New("no such field in the struct")
+var ErrNoStructMeta = errors.New("a struct needs the RowMeta of its fields")
+
+// Struct is a nested record, its fields are the cells of a RowMeta. A field
+// that is not set is nil and only the fields that are set are saved.
+type Struct struct {
+	DtRefer
+	value []DtRefer
+
+	//it's a template that never be saved, see CellMeta.WithStructMeta
+	meta *RowMeta
+}
+
+func NewStruct(meta *RowMeta) *Struct {
+	p := &Struct{meta: meta}
+	if nil != meta {
+		p.value = make([]DtRefer, meta.GetCellSize())
+	}
+	return p
+}
+
+func (p *Struct) GetMeta() *RowMeta {
+	return p.meta
+}
+
+// Fields returns the fields by their position, the fields not set are nil
+func (p *Struct) Fields() []DtRefer {
+	return p.value
+}
+
+func (p *Struct) fieldPos(name string) int {
+	if nil == p.meta {
+		return -1
+	}
+	for _, item := range p.meta.GetItems() {
+		if nil != item && item.GetName() == name {
+			return item.GetPos()
+		}
+	}
+	return -1
+}
+
+// Field returns nil if the field is not set, or ErrStructField if there is no such field
+func (p *Struct) Field(name string) (DtRefer, error) {
+	pos := p.fieldPos(name)
+	if pos < 0 {
+		return nil, ErrStructField
+	}
+	return p.value[pos], nil
+}
+
+// SetField casts v to the type of the field, a nil v clears the field
+func (p *Struct) SetField(name string, v ValueRefer) error {
+	pos := p.fieldPos(name)
+	if pos < 0 {
+		return ErrStructField
+	}
+	if nil == v {
+		p.value[pos] = nil
+		return nil
+	}
+	item := p.meta.GetItems()[pos]
+	if d, ok := v.(DtRefer); ok {
+		if typ, _ := TypeOf(d); typ == item.GetMType() {
+			p.value[pos] = d
+			return nil
+		}
+	}
+	cell := item.newDtRefer()
+	if err := TrySetValue(cell, v); err != nil {
+		return err
+	}
+	p.value[pos] = cell
+	return nil
+}
+
+// SetFields sets every field of m, p is not changed if one of them fails
+func (p *Struct) SetFields(m map[string]ValueRefer) error {
+	ret := p.Copy().(*Struct)
+	for name, v := range m {
+		if err := ret.SetField(name, v); err != nil {
+			return err
+		}
+	}
+	p.value = ret.value
+	return nil
+}
+
+// Encode saves the number of fields and a bitmap of the fields that are set,
+// then the fields that are set. The fields added to the meta later are not
+// set when an old struct is decoded.
+func (p *Struct) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.BigEndian, uint16(len(p.value)))
+	bitmap := make([]byte, (len(p.value)+7)/8)
+	for i, field := range p.value {
+		if nil != field {
+			bitmap[i/8] |= 1 << uint(i%8)
+		}
+	}
+	buf.Write(bitmap)
+	for _, field := range p.value {
+		if nil == field {
+			continue
+		}
+		b, err := field.Encode()
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+	}
+	return buf.Bytes(), nil
+}
+
+func (p *Struct) Decode(buf []byte, offset int) (int, error) {
+	if nil == p.meta {
+		return 0, ErrNoStructMeta
+	}
+	count := int(binary.BigEndian.Uint16(buf[offset : offset+2]))
+	index := 2
+	bitmap := buf[offset+index : offset+index+(count+7)/8]
+	index += len(bitmap)
+
+	items := p.meta.GetItems()
+	p.value = make([]DtRefer, len(items))
+	for i := 0; i < count; i++ {
+		if bitmap[i/8]&(1<<uint(i%8)) == 0 {
+			continue
+		}
+		if i >= len(items) || nil == items[i] {
+			return 0, ErrStructField
+		}
+		field := items[i].newDtRefer()
+		l, err := field.Decode(buf, offset+index)
+		if err != nil {
+			return 0, err
+		}
+		index += l
+		p.value[i] = field
+	}
+	return index, nil
+}
+
+// set replaces the fields by a map of the field names, a []DtRefer by the
+// positions or the fields of a *Struct of the same meta
+func (p *Struct) set(v ValueRefer) error {
+	switch x := v.(type) {
+	case map[string]ValueRefer:
+		ret := NewStruct(p.meta)
+		if err := ret.SetFields(x); err != nil {
+			return err
+		}
+		p.value = ret.value
+	case *Struct:
+		if len(x.value) != len(p.value) {
+			return ErrCastType
+		}
+		p.value = append([]DtRefer(nil), x.value...)
+	case []DtRefer:
+		p.value = x
+	default:
+		return ErrCastType
+	}
+	return nil
+}
+
+// SetValue takes a map of the field names, a []DtRefer by the positions or
+// a *Struct, it panics if a field can not be set
+func (p *Struct) SetValue(v ValueRefer) {
+	if err := p.set(v); err != nil {
+		panic(err)
+	}
+}
+
+// GetValue returns the values of the fields that are set by their names
+func (p *Struct) GetValue() ValueRefer {
+	m := make(map[string]ValueRefer)
+	if nil == p.meta {
+		return m
+	}
+	for _, item := range p.meta.GetItems() {
+		if nil == item || item.GetPos() >= len(p.value) || nil == p.value[item.GetPos()] {
+			continue
+		}
+		m[item.GetName()] = p.value[item.GetPos()].GetValue()
+	}
+	return m
+}
+
+func (p *Struct) GetLen() int {
+	i := 2 + (len(p.value)+7)/8
+	for _, field := range p.value {
+		if nil != field {
+			i += field.GetLen()
+		}
+	}
+	return i
+}
+
+// Copy returns a struct that shares the fields with p
+func (p *Struct) Copy() DtRefer {
+	return &Struct{meta: p.meta, value: append([]DtRefer(nil), p.value...)}
+}
+
+func (p *Struct) DeepCopy() DtRefer {
+	ret := &Struct{meta: p.meta, value: make([]DtRefer, len(p.value))}
+	for i, field := range p.value {
+		if nil != field {
+			ret.value[i] = field.DeepCopy()
+		}
+	}
+	return ret
+}
+
+// Compare orders the structs field by field, a field not set is before any value
+func (p *Struct) Compare(v Comparator) int {
+	o := v.(*Struct)
+	for i := 0; i < len(p.value) && i < len(o.value); i++ {
+		a, b := p.value[i], o.value[i]
+		switch {
+		case nil == a && nil == b:
+			continue
+		case nil == a:
+			return -1
+		case nil == b:
+			return 1
+		}
+		if c := a.Compare(b); c != 0 {
+			return c
+		}
+	}
+	return compareInt64(int64(len(p.value)), int64(len(o.value)))
+}
@@ -0,0 +1,82 @@
+package dt
+
+import "testing"
+
+func newAddressMeta() *RowMeta {
+	meta := NewRowMeta()
+	meta.AddCellMeta(NewCellMetaRaw(0, StringType, "city", "", nil))
+	meta.AddCellMeta(NewCellMetaRaw(1, Int32Type, "zip", "", nil))
+	meta.AddCellMeta(NewCellMetaRaw(2, DecimalType, "lat", "", nil).WithDecimal(9, 6))
+	return meta
+}
+
+func TestStruct(t *testing.T) {
+	cell := NewCellMetaRaw(0, StructType, "address", "", nil).WithStructMeta(newAddressMeta())
+
+	s1 := cell.NewCell().(*Struct)
+	if err := s1.SetFields(map[string]ValueRefer{"city": "Paris", "zip": int64(75001)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s1.SetField("street", "x"); err != ErrStructField {
+		t.Fatal("street is not a field")
+	}
+	if err := s1.SetFields(map[string]ValueRefer{"city": "Lyon", "zip": "x"}); err == nil {
+		t.Fatal("x is not a zip")
+	}
+	if city, _ := s1.Field("city"); city.GetValue() != "Paris" {
+		t.Fatal("the struct should not be changed by a failed SetFields")
+	}
+	if lat, _ := s1.Field("lat"); nil != lat {
+		t.Fatal("lat is not set")
+	}
+
+	buf, _ := s1.Encode()
+	if len(buf) != s1.GetLen() || len(buf) != 2+1+ValidNewString("Paris").GetLen()+4 {
+		t.Fatal("only the fields that are set should be saved,but ", len(buf))
+	}
+	s2 := cell.NewCell().(*Struct)
+	l, err := s2.Decode(buf, 0)
+	if err != nil || l != len(buf) || s2.Compare(s1) != 0 {
+		t.Fatal("the struct should be decoded", err)
+	}
+	m := s2.GetValue().(map[string]ValueRefer)
+	if len(m) != 2 || m["city"] != "Paris" || m["zip"] != int32(75001) {
+		t.Fatal("the fields should be decoded,but ", m)
+	}
+
+	//a field not set is first, then the fields are compared in order
+	s2.SetField("zip", nil)
+	if s2.Compare(s1) != -1 || s1.Compare(s2) != 1 {
+		t.Fatal("the struct without zip should be first")
+	}
+	s2.SetField("zip", 75002)
+	if s2.Compare(s1) != 1 {
+		t.Fatal("75002 should be after 75001")
+	}
+
+	s3 := s1.DeepCopy().(*Struct)
+	s3.SetField("city", "Lyon")
+	if city, _ := s1.Field("city"); city.GetValue() != "Paris" {
+		t.Fatal("the deep copy should not share the fields")
+	}
+}
+
+func TestStruct_NewField(t *testing.T) {
+	meta := newAddressMeta()
+	s1 := NewStruct(meta)
+	s1.SetField("city", "Paris")
+	buf, _ := s1.Encode()
+
+	//a struct saved before a field is added
+	meta.AddCellMeta(NewCellMetaRaw(3, StringType, "country", "", nil))
+	s2 := NewStruct(meta)
+	if _, err := s2.Decode(buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	if c, err := s2.Field("country"); err != nil || nil != c {
+		t.Fatal("country should not be set")
+	}
+	if _, err := NewStruct(nil).Decode(buf, 0); err != ErrNoStructMeta {
+		t.Fatal("a struct should need its meta")
+	}
+}
@@ -0,0 +1,223 @@
+package dt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// TimeKind is the declared type of a TimeType cell
+type TimeKind byte
+
+const (
+	TimestampKind   TimeKind = iota //an instant, kept in UTC
+	DateKind                        //a calendar date
+	TimeOfDayKind                   //a wall clock time without a date
+	TimestampTZKind                 //an instant and the zone offset it was written in
+)
+
+const (
+	DateLayout      = "2006-01-02"
+	TimeOfDayLayout = "15:04:05.999999999"
+)
+
+const nanosPerDay = int64(24 * time.Hour)
+
+var ErrTimeSyntax = errors.New("invalid time")
+
+// Time holds a time with nanosecond precision between the years 1678 and 2262.
+// The value is time.Time.UnixNano(), or the nanoseconds since midnight for
+// TimeOfDayKind, and it is encoded with the sign bit flipped so the bytes
+// sort in time order.
+type Time struct {
+	DtRefer
+	kind   TimeKind
+	value  int64
+	offset int32 //seconds east of UTC, only used by TimestampTZKind
+}
+
+func NewTime() *Time {
+	return NewTimeWithKind(TimestampKind)
+}
+
+func NewTimeWithKind(kind TimeKind) *Time {
+	return &Time{kind: kind}
+}
+
+func ValidNewTime(t time.Time) *Time {
+	p := NewTime()
+	p.SetTime(t)
+	return p
+}
+
+// ParseTime parses s in RFC 3339, or in DateLayout and TimeOfDayLayout
+// for the kinds without a zone
+func ParseTime(kind TimeKind, s string) (*Time, error) {
+	p := NewTimeWithKind(kind)
+	if err := p.SetString(s); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Time) Kind() TimeKind {
+	return p.kind
+}
+
+// SetTime stores t as the kind of p, the zone of t is only kept by TimestampTZKind
+func (p *Time) SetTime(t time.Time) {
+	switch p.kind {
+	case DateKind:
+		y, m, d := t.Date()
+		p.value = time.Date(y, m, d, 0, 0, 0, 0, time.UTC).UnixNano()
+	case TimeOfDayKind:
+		h, m, s := t.Clock()
+		p.value = int64(h)*int64(time.Hour) + int64(m)*int64(time.Minute) +
+			int64(s)*int64(time.Second) + int64(t.Nanosecond())
+	case TimestampTZKind:
+		_, offset := t.Zone()
+		p.value = t.UnixNano()
+		p.offset = int32(offset)
+	default:
+		p.value = t.UnixNano()
+	}
+}
+
+// Time returns the value as a time.Time, a TimeOfDayKind value is on 1970-01-01 UTC
+func (p *Time) Time() time.Time {
+	t := time.Unix(0, p.value).UTC()
+	if p.kind == TimestampTZKind {
+		return t.In(time.FixedZone("", int(p.offset)))
+	}
+	return t
+}
+
+func (p *Time) SetString(s string) error {
+	var t time.Time
+	var err error
+	switch p.kind {
+	case DateKind:
+		t, err = time.Parse(DateLayout, s)
+		if err != nil {
+			t, err = time.Parse(time.RFC3339Nano, s)
+		}
+	case TimeOfDayKind:
+		t, err = time.Parse(TimeOfDayLayout, s)
+	default:
+		t, err = time.Parse(time.RFC3339Nano, s)
+	}
+	if err != nil {
+		return ErrTimeSyntax
+	}
+	p.SetTime(t)
+	return nil
+}
+
+// String formats the value in RFC 3339, or in DateLayout and TimeOfDayLayout
+func (p *Time) String() string {
+	switch p.kind {
+	case DateKind:
+		return p.Time().Format(DateLayout)
+	case TimeOfDayKind:
+		return p.Time().Format(TimeOfDayLayout)
+	}
+	return p.Time().Format(time.RFC3339Nano)
+}
+
+// Add returns the time d later, a TimeOfDayKind value wraps around midnight
+func (p *Time) Add(d time.Duration) *Time {
+	ret := *p
+	ret.value += int64(d)
+	if p.kind == TimeOfDayKind {
+		ret.value = ((ret.value % nanosPerDay) + nanosPerDay) % nanosPerDay
+	}
+	return &ret
+}
+
+// AddDate returns the time moved by the calendar, it follows time.Time.AddDate
+// in the zone of the value
+func (p *Time) AddDate(years int, months int, days int) *Time {
+	ret := NewTimeWithKind(p.kind)
+	ret.SetTime(p.Time().AddDate(years, months, days))
+	return ret
+}
+
+// Sub returns the interval p - o
+func (p *Time) Sub(o *Time) time.Duration {
+	return time.Duration(p.value - o.value)
+}
+
+// Encode saves the kind, the value with the sign bit flipped and the zone offset
+func (p *Time) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.WriteByte(byte(p.kind))
+	binary.Write(buf, binary.BigEndian, uint64(p.value)^(1<<63))
+	if p.kind == TimestampTZKind {
+		binary.Write(buf, binary.BigEndian, uint32(p.offset)^(1<<31))
+	}
+	return buf.Bytes(), nil
+}
+
+func (p *Time) Decode(buf []byte, offset int) (int, error) {
+	p.kind = TimeKind(buf[offset])
+	p.value = int64(binary.BigEndian.Uint64(buf[offset+1:offset+9]) ^ (1 << 63))
+	if p.kind == TimestampTZKind {
+		p.offset = int32(binary.BigEndian.Uint32(buf[offset+9:offset+13]) ^ (1 << 31))
+		return 13, nil
+	}
+	return 9, nil
+}
+
+// SetValue takes a time.Time, a string or an int64 of time.Time.UnixNano(),
+// it panics if the string can not be parsed
+func (p *Time) SetValue(v ValueRefer) {
+	switch x := v.(type) {
+	case time.Time:
+		p.SetTime(x)
+	case string:
+		if err := p.SetString(x); err != nil {
+			panic(err)
+		}
+	case *Time:
+		p.SetTime(x.Time())
+	default:
+		p.SetTime(time.Unix(0, v.(int64)).UTC())
+	}
+}
+
+// GetValue returns a time.Time
+func (p *Time) GetValue() ValueRefer {
+	return p.Time()
+}
+
+func (p *Time) GetLen() int {
+	if p.kind == TimestampTZKind {
+		return 13
+	}
+	return 9
+}
+
+func (p *Time) Copy() DtRefer {
+	ret := *p
+	return &ret
+}
+func (p *Time) DeepCopy() DtRefer {
+	return p.Copy()
+}
+
+// Compare orders by time and then by zone offset, the same order as the bytes
+func (p *Time) Compare(v Comparator) int {
+	o := v.(*Time)
+	switch {
+	case p.value < o.value:
+		return -1
+	case p.value > o.value:
+		return 1
+	case p.offset < o.offset:
+		return -1
+	case p.offset > o.offset:
+		return 1
+	}
+	return 0
+}
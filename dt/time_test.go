@@ -0,0 +1,77 @@
+package dt
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestTime(t *testing.T) {
+	cases := []struct {
+		kind TimeKind
+		in   string
+		out  string
+		len  int
+	}{
+		{TimestampKind, "2017-03-04T05:06:07.123456789+08:00", "2017-03-03T21:06:07.123456789Z", 9},
+		{TimestampTZKind, "2017-03-04T05:06:07.5+08:00", "2017-03-04T05:06:07.5+08:00", 13},
+		{DateKind, "2017-03-04", "2017-03-04", 9},
+		{TimeOfDayKind, "23:59:59.000000001", "23:59:59.000000001", 9},
+	}
+	for _, c := range cases {
+		t1, err := ParseTime(c.kind, c.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b1, _ := t1.Encode()
+
+		t2 := NewTime()
+		l, _ := t2.Decode(b1, 0)
+		if l != c.len || l != t2.GetLen() {
+			t.Fatal("len should be ", c.len, ",but ", l)
+		}
+		if t2.Kind() != c.kind || t2.String() != c.out {
+			t.Fatal("value should be ", c.out, ",but ", t2.String())
+		}
+	}
+	if _, err := ParseTime(DateKind, "2017-13-01"); err != ErrTimeSyntax {
+		t.Fatal("2017-13-01 should be invalid")
+	}
+}
+
+func TestTimeOrder(t *testing.T) {
+	values := []string{"1800-01-01T00:00:00Z", "1969-12-31T23:59:59.999999999Z", "1970-01-01T00:00:00Z", "2017-03-04T05:06:07Z"}
+	for i := 1; i < len(values); i++ {
+		a, _ := ParseTime(TimestampKind, values[i-1])
+		b, _ := ParseTime(TimestampKind, values[i])
+		ba, _ := a.Encode()
+		bb, _ := b.Encode()
+		if a.Compare(b) != -1 || b.Compare(a) != 1 || bytes.Compare(ba, bb) != -1 {
+			t.Fatal(values[i-1], " should be before ", values[i])
+		}
+	}
+}
+
+func TestTimeInterval(t *testing.T) {
+	a, _ := ParseTime(TimestampKind, "2017-01-31T10:00:00Z")
+	b := a.Add(90 * time.Minute)
+	if b.String() != "2017-01-31T11:30:00Z" || b.Sub(a) != 90*time.Minute {
+		t.Fatal("a+90m should be 2017-01-31T11:30:00Z,but ", b)
+	}
+	d, _ := ParseTime(DateKind, "2017-01-31")
+	if s := d.AddDate(0, 1, 0).String(); s != "2017-03-03" {
+		t.Fatal("2017-01-31 plus one month should be 2017-03-03,but ", s)
+	}
+	c, _ := ParseTime(TimeOfDayKind, "23:30:00")
+	if s := c.Add(time.Hour).String(); s != "00:30:00" {
+		t.Fatal("23:30 plus one hour should wrap to 00:30:00,but ", s)
+	}
+}
+
+func TestTimeCellMeta(t *testing.T) {
+	meta := NewCellMetaRaw(0, TimeType, "birthday", "", "2017-03-04").WithTimeKind(DateKind)
+	cell := meta.NewCell().(*Time)
+	if cell.Kind() != DateKind || cell.String() != "2017-03-04" {
+		t.Fatal("the cell should be the date 2017-03-04,but ", cell)
+	}
+}
@@ -0,0 +1,166 @@
+package dt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrUUIDSyntax = errors.New("invalid uuid")
+
+// UUID is a 16 bytes id, the bytes are compared in order so the
+// time ordered version 7 ids sort by the time they are made
+type UUID struct {
+	DtRefer
+	value [16]byte
+}
+
+func NewUUID() *UUID {
+	return &UUID{}
+}
+
+// NewUUIDv4 returns a random uuid
+func NewUUIDv4() (*UUID, error) {
+	p := NewUUID()
+	if _, err := rand.Read(p.value[:]); err != nil {
+		return nil, err
+	}
+	p.setVersion(4)
+	return p, nil
+}
+
+// the last version 7 uuid, the ids made in the same millisecond count up
+var v7 = struct {
+	sync.Mutex
+	last [16]byte
+}{}
+
+// NewUUIDv7 returns a uuid that starts with the unix milliseconds, the ids made
+// by one process are increasing even in the same millisecond
+func NewUUIDv7() (*UUID, error) {
+	p := NewUUID()
+	if _, err := rand.Read(p.value[6:]); err != nil {
+		return nil, err
+	}
+	ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], ms)
+	copy(p.value[:6], ts[2:])
+	p.setVersion(7)
+
+	v7.Lock()
+	defer v7.Unlock()
+	if bytes.Compare(p.value[:], v7.last[:]) <= 0 {
+		//the clock did not move on, take the last id plus one
+		p.value = v7.last
+		for i := 15; i >= 9; i-- {
+			p.value[i]++
+			if p.value[i] != 0 {
+				break
+			}
+		}
+		p.setVersion(7)
+	}
+	v7.last = p.value
+	return p, nil
+}
+
+func (p *UUID) setVersion(version byte) {
+	p.value[6] = p.value[6]&0x0f | version<<4
+	p.value[8] = p.value[8]&0x3f | 0x80 //the RFC 4122 variant
+}
+
+func (p *UUID) Version() int {
+	return int(p.value[6] >> 4)
+}
+
+// ParseUUID parses "xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx" with or without
+// the dashes, the braces or the "urn:uuid:" prefix
+func ParseUUID(s string) (*UUID, error) {
+	s = strings.TrimPrefix(strings.ToLower(s), "urn:uuid:")
+	if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+		s = s[1 : len(s)-1]
+	}
+	if len(s) == 36 {
+		if s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+			return nil, ErrUUIDSyntax
+		}
+		s = s[:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	}
+	if len(s) != 32 {
+		return nil, ErrUUIDSyntax
+	}
+	p := NewUUID()
+	if _, err := hex.Decode(p.value[:], []byte(s)); err != nil {
+		return nil, ErrUUIDSyntax
+	}
+	return p, nil
+}
+
+func (p *UUID) String() string {
+	s := hex.EncodeToString(p.value[:])
+	return s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+func (p *UUID) Bytes() [16]byte {
+	return p.value
+}
+
+func (p *UUID) Encode() ([]byte, error) {
+	return append([]byte(nil), p.value[:]...), nil
+}
+
+func (p *UUID) Decode(buf []byte, offset int) (int, error) {
+	copy(p.value[:], buf[offset:offset+16])
+	return 16, nil
+}
+
+// SetValue takes the text of a uuid, a *UUID, a [16]byte or a []byte of 16 bytes,
+// it panics if the text is not a uuid
+func (p *UUID) SetValue(v ValueRefer) {
+	switch x := v.(type) {
+	case string:
+		u, err := ParseUUID(x)
+		if err != nil {
+			panic(err)
+		}
+		p.value = u.value
+	case *UUID:
+		p.value = x.value
+	case [16]byte:
+		p.value = x
+	default:
+		b := v.([]byte)
+		if len(b) != 16 {
+			panic(ErrUUIDSyntax)
+		}
+		copy(p.value[:], b)
+	}
+}
+
+// GetValue returns the text of the uuid
+func (p *UUID) GetValue() ValueRefer {
+	return p.String()
+}
+
+func (p *UUID) GetLen() int {
+	return 16
+}
+
+func (p *UUID) Copy() DtRefer {
+	return &UUID{value: p.value}
+}
+
+func (p *UUID) DeepCopy() DtRefer {
+	return p.Copy()
+}
+
+func (p *UUID) Compare(v Comparator) int {
+	o := v.(*UUID)
+	return bytes.Compare(p.value[:], o.value[:])
+}
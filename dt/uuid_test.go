@@ -0,0 +1,77 @@
+package dt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUUID(t *testing.T) {
+	u1, err := ParseUUID("6BA7B810-9DAD-11D1-80B4-00C04FD430C8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u1.String() != "6ba7b810-9dad-11d1-80b4-00c04fd430c8" || u1.Version() != 1 {
+		t.Fatal("the uuid should be parsed,but ", u1.String())
+	}
+	for _, s := range []string{"6ba7b8109dad11d180b400c04fd430c8", "{6ba7b810-9dad-11d1-80b4-00c04fd430c8}",
+		"urn:uuid:6ba7b810-9dad-11d1-80b4-00c04fd430c8"} {
+		u, err := ParseUUID(s)
+		if err != nil || u.Compare(u1) != 0 {
+			t.Fatal("should parse ", s)
+		}
+	}
+	for _, s := range []string{"", "6ba7b810-9dad-11d1-80b4-00c04fd430c", "6ba7b810x9dad-11d1-80b4-00c04fd430c8",
+		"6ba7b810-9dad-11d1-80b4-00c04fd430cz"} {
+		if _, err := ParseUUID(s); err != ErrUUIDSyntax {
+			t.Fatal("should not parse ", s)
+		}
+	}
+
+	buf, _ := u1.Encode()
+	u2 := NewUUID()
+	l, _ := u2.Decode(buf, 0)
+	if l != 16 || l != u2.GetLen() || u2.Compare(u1) != 0 || u2.GetValue() != u1.String() {
+		t.Fatal("the uuid should be decoded")
+	}
+}
+
+func TestUUID_Generate(t *testing.T) {
+	u4, err := NewUUIDv4()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u4.Version() != 4 || u4.value[8]&0xc0 != 0x80 {
+		t.Fatal("should be a version 4 uuid,but ", u4.String())
+	}
+
+	before := time.Now()
+	last := NewUUID()
+	for i := 0; i < 1000; i++ {
+		u, err := NewUUIDv7()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Version() != 7 || u.value[8]&0xc0 != 0x80 {
+			t.Fatal("should be a version 7 uuid,but ", u.String())
+		}
+		if u.Compare(last) != 1 {
+			t.Fatal("the version 7 uuids should increase")
+		}
+		last = u
+	}
+	ms := int64(last.value[0])<<40 | int64(last.value[1])<<32 | int64(last.value[2])<<24 |
+		int64(last.value[3])<<16 | int64(last.value[4])<<8 | int64(last.value[5])
+	if ms < before.UnixNano()/int64(time.Millisecond) {
+		t.Fatal("the uuid should start with the time")
+	}
+}
+
+func TestUUID_TrySetValue(t *testing.T) {
+	u := NewUUID()
+	if err := TrySetValue(u, "6ba7b810-9dad-11d1-80b4-00c04fd430c8"); err != nil || u.Version() != 1 {
+		t.Fatal("the text should be set")
+	}
+	if err := TrySetValue(u, "6ba7b810"); err != ErrCastType || u.Version() != 1 {
+		t.Fatal("a bad text should not be set")
+	}
+}
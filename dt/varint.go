@@ -0,0 +1,134 @@
+package dt
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// IntEncoding is the way Int32, UInt32, Int64 and UInt64 are saved
+type IntEncoding byte
+
+const (
+	FixedEncoding         IntEncoding = iota //big-endian in 4 or 8 bytes
+	VarintEncoding                           //7 bits a byte, a negative value takes 10 bytes
+	ZigzagEncoding                           //varint of the zigzag value, small negative values are short too
+	OrderedVarintEncoding                    //a length header and big-endian bytes, the bytes sort like the values
+)
+
+var ErrVarintOverflow = errors.New("varint overflows the type")
+
+// the ordered header of a non-negative value is orderedZero plus the byte count,
+// the one of a negative value is orderedZero-1 minus the byte count
+const orderedZero = 9
+
+// encodeVarint saves a signed value, the unsigned ones use encodeUvarint
+func encodeVarint(enc IntEncoding, v int64) []byte {
+	switch enc {
+	case ZigzagEncoding:
+		buf := make([]byte, binary.MaxVarintLen64)
+		return buf[:binary.PutVarint(buf, v)]
+	case OrderedVarintEncoding:
+		if v >= 0 {
+			return encodeOrdered(orderedZero, uint64(v))
+		}
+		//the more negative the value, the more bytes and the smaller the header
+		n := byteCount(^uint64(v))
+		buf := make([]byte, 1+n)
+		buf[0] = byte(orderedZero - 1 - n)
+		putBigEndian(buf[1:], uint64(v))
+		return buf
+	}
+	return encodeUvarint(enc, uint64(v))
+}
+
+func encodeUvarint(enc IntEncoding, v uint64) []byte {
+	if enc == OrderedVarintEncoding {
+		return encodeOrdered(orderedZero, v)
+	}
+	buf := make([]byte, binary.MaxVarintLen64)
+	return buf[:binary.PutUvarint(buf, v)]
+}
+
+func encodeOrdered(zero int, v uint64) []byte {
+	n := byteCount(v)
+	buf := make([]byte, 1+n)
+	buf[0] = byte(zero + n)
+	putBigEndian(buf[1:], v)
+	return buf
+}
+
+// byteCount returns the bytes needed by v, 0 needs none
+func byteCount(v uint64) int {
+	n := 0
+	for ; v > 0; v >>= 8 {
+		n++
+	}
+	return n
+}
+
+// putBigEndian saves the low len(buf) bytes of v
+func putBigEndian(buf []byte, v uint64) {
+	for i := len(buf) - 1; i >= 0; i-- {
+		buf[i] = byte(v)
+		v >>= 8
+	}
+}
+
+func decodeVarint(enc IntEncoding, buf []byte, offset int) (int64, int, error) {
+	switch enc {
+	case ZigzagEncoding:
+		v, l := binary.Varint(buf[offset:])
+		if l <= 0 {
+			return 0, 0, ErrVarintOverflow
+		}
+		return v, l, nil
+	case OrderedVarintEncoding:
+		header := int(buf[offset])
+		if header >= orderedZero {
+			v, l, err := decodeUvarint(enc, buf, offset)
+			if v > math.MaxInt64 {
+				return 0, 0, ErrVarintOverflow
+			}
+			return int64(v), l, err
+		}
+		n := orderedZero - 1 - header
+		if n > 8 || offset+1+n > len(buf) {
+			return 0, 0, ErrVarintOverflow
+		}
+		v := ^uint64(0)
+		for _, b := range buf[offset+1 : offset+1+n] {
+			v = v<<8 | uint64(b)
+		}
+		return int64(v), 1 + n, nil
+	}
+	v, l, err := decodeUvarint(enc, buf, offset)
+	return int64(v), l, err
+}
+
+func decodeUvarint(enc IntEncoding, buf []byte, offset int) (uint64, int, error) {
+	if enc == OrderedVarintEncoding {
+		n := int(buf[offset]) - orderedZero
+		if n < 0 || n > 8 || offset+1+n > len(buf) {
+			return 0, 0, ErrVarintOverflow
+		}
+		v := uint64(0)
+		for _, b := range buf[offset+1 : offset+1+n] {
+			v = v<<8 | uint64(b)
+		}
+		return v, 1 + n, nil
+	}
+	v, l := binary.Uvarint(buf[offset:])
+	if l <= 0 {
+		return 0, 0, ErrVarintOverflow
+	}
+	return v, l, nil
+}
+
+func varintLen(enc IntEncoding, v int64) int {
+	return len(encodeVarint(enc, v))
+}
+
+func uvarintLen(enc IntEncoding, v uint64) int {
+	return len(encodeUvarint(enc, v))
+}
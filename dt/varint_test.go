@@ -0,0 +1,88 @@
+package dt
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"testing"
+)
+
+var varintEncodings = []IntEncoding{VarintEncoding, ZigzagEncoding, OrderedVarintEncoding}
+
+func TestVarint_Int64(t *testing.T) {
+	values := []int64{math.MinInt64, -70000, -257, -256, -2, -1, 0, 1, 127, 128, 255, 256, 70000, math.MaxInt64}
+	for _, enc := range varintEncodings {
+		for _, v := range values {
+			i1 := NewIntWithEncoding(Int64Type, enc)
+			i1.SetValue(v)
+			b1, _ := i1.Encode()
+
+			i2 := NewIntWithEncoding(Int64Type, enc)
+			l, err := i2.Decode(append([]byte{0xff}, b1...), 1)
+			if err != nil || l != len(b1) || l != i1.GetLen() {
+				t.Fatal("len should be ", len(b1), ",but ", l, err)
+			}
+			if i2.GetValue().(int64) != v {
+				t.Fatal("value should be ", v, ",but ", i2.GetValue())
+			}
+		}
+	}
+}
+
+func TestVarint_Len(t *testing.T) {
+	small := NewIntWithEncoding(UInt32Type, VarintEncoding)
+	small.SetValue(uint32(100))
+	if small.GetLen() != 1 {
+		t.Fatal("100 should take 1 byte,but ", small.GetLen())
+	}
+	neg := NewIntWithEncoding(Int32Type, ZigzagEncoding)
+	neg.SetValue(int32(-3))
+	if neg.GetLen() != 1 {
+		t.Fatal("-3 should take 1 byte by zigzag,but ", neg.GetLen())
+	}
+	neg = NewIntWithEncoding(Int32Type, VarintEncoding)
+	neg.SetValue(int32(-3))
+	if neg.GetLen() != 10 {
+		t.Fatal("-3 should take 10 bytes by varint,but ", neg.GetLen())
+	}
+}
+
+func TestVarint_Ordered(t *testing.T) {
+	values := []int64{math.MinInt64, -70000, -257, -256, -255, -2, -1, 0, 1, 255, 256, 70000, math.MaxInt64}
+	keys := make([][]byte, len(values))
+	for i, v := range values {
+		cell := NewIntWithEncoding(Int64Type, OrderedVarintEncoding)
+		cell.SetValue(v)
+		keys[i], _ = cell.Encode()
+	}
+	if !sort.SliceIsSorted(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i], keys[j]) < 0
+	}) {
+		t.Fatal("the ordered encoding should sort like the values")
+	}
+	for i := 1; i < len(keys); i++ {
+		if bytes.Equal(keys[i-1], keys[i]) {
+			t.Fatal("the keys should be unique")
+		}
+	}
+}
+
+func TestVarint_Overflow(t *testing.T) {
+	big := NewIntWithEncoding(UInt64Type, VarintEncoding)
+	big.SetValue(uint64(math.MaxUint32 + 1))
+	b, _ := big.Encode()
+	if _, err := NewIntWithEncoding(UInt32Type, VarintEncoding).Decode(b, 0); err != ErrVarintOverflow {
+		t.Fatal("a uint64 should overflow a uint32")
+	}
+	if _, err := NewIntWithEncoding(UInt32Type, VarintEncoding).Decode([]byte{0x80}, 0); err != ErrVarintOverflow {
+		t.Fatal("a torn varint should fail")
+	}
+}
+
+func TestVarint_CellMeta(t *testing.T) {
+	meta := NewCellMetaRaw(0, Int64Type, "counter", "", int64(5)).WithEncoding(ZigzagEncoding)
+	cell := meta.NewCell()
+	if cell.GetLen() != 1 || cell.Copy().GetLen() != 1 {
+		t.Fatal("the cell should keep the encoding of the meta")
+	}
+}
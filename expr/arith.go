@@ -0,0 +1,244 @@
+package expr
+
+import (
+	"errors"
+	"github.com/lycying/pitydb/dt"
+	"math"
+)
+
+var ErrNotNumber = errors.New("the value is not a number")
+var ErrOverflow = errors.New("the result is out of the range of the type")
+
+// divScale is the scale added to a decimal division
+const divScale = 6
+
+// Arith is an arithmetic on two numbers. The result is a Float64 if one of
+// them is a float, a Decimal if one of them is a decimal and an Int64 if both
+// are integers, an Int64 or a Float64 that overflows is an error. The result
+// of % has the sign of the left side.
+type Arith struct {
+	Op          Op
+	Left, Right Expr
+}
+
+func (e *Arith) Eval(row Row) (dt.DtRefer, error) {
+	l, err := e.Left.Eval(row)
+	if err != nil {
+		return nil, err
+	}
+	r, err := e.Right.Eval(row)
+	if err != nil || nil == l || nil == r {
+		return nil, err
+	}
+	return arith(e.Op, l, r)
+}
+
+func (e *Arith) String() string {
+	return "(" + e.Left.String() + " " + e.Op.String() + " " + e.Right.String() + ")"
+}
+
+// Neg is -x
+type Neg struct {
+	X Expr
+}
+
+func (e *Neg) Eval(row Row) (dt.DtRefer, error) {
+	v, err := e.X.Eval(row)
+	if err != nil || nil == v {
+		return nil, err
+	}
+	return arith(OpSub, dt.ValidInt64(0), v)
+}
+
+func (e *Neg) String() string {
+	return "(-" + e.X.String() + ")"
+}
+
+type numKind int
+
+const (
+	intKind numKind = iota
+	decimalKind
+	floatKind
+)
+
+func kindOf(v dt.DtRefer) (numKind, error) {
+	switch v.(type) {
+	case *dt.Byte, *dt.Int32, *dt.UInt32, *dt.Int64, *dt.UInt64:
+		return intKind, nil
+	case *dt.Decimal:
+		return decimalKind, nil
+	case *dt.Float32, *dt.Float64:
+		return floatKind, nil
+	}
+	return 0, ErrNotNumber
+}
+
+func arith(op Op, l dt.DtRefer, r dt.DtRefer) (dt.DtRefer, error) {
+	if op < OpAdd || op > OpMod {
+		return nil, ErrOp
+	}
+	lk, err := kindOf(l)
+	if err != nil {
+		return nil, err
+	}
+	rk, err := kindOf(r)
+	if err != nil {
+		return nil, err
+	}
+	kind := lk
+	if rk > kind {
+		kind = rk
+	}
+	switch kind {
+	case floatKind:
+		a, _ := dt.Cast(l, dt.Float64Type)
+		b, _ := dt.Cast(r, dt.Float64Type)
+		v, err := ArithFloat64(op, a.(float64), b.(float64))
+		if err != nil {
+			return nil, err
+		}
+		return dt.ValidNewFloat64(v), nil
+	case decimalKind:
+		a, _ := dt.Cast(l, dt.DecimalType)
+		b, _ := dt.Cast(r, dt.DecimalType)
+		return arithDecimal(op, a.(*dt.Decimal), b.(*dt.Decimal))
+	}
+	a, err := dt.Cast(l, dt.Int64Type)
+	if err != nil {
+		return nil, ErrOverflow
+	}
+	b, err := dt.Cast(r, dt.Int64Type)
+	if err != nil {
+		return nil, ErrOverflow
+	}
+	v, err := ArithInt64(op, a.(int64), b.(int64))
+	if err != nil {
+		return nil, err
+	}
+	return dt.ValidInt64(v), nil
+}
+
+// ArithFloat64 is op on two floats, a division by zero is dt.ErrDivisionByZero
+// and an infinite result of two finite floats is ErrOverflow
+func ArithFloat64(op Op, a float64, b float64) (float64, error) {
+	var v float64
+	switch op {
+	case OpAdd:
+		v = a + b
+	case OpSub:
+		v = a - b
+	case OpMul:
+		v = a * b
+	case OpDiv:
+		if b == 0 {
+			return 0, dt.ErrDivisionByZero
+		}
+		v = a / b
+	case OpMod:
+		if b == 0 {
+			return 0, dt.ErrDivisionByZero
+		}
+		v = math.Mod(a, b)
+	default:
+		return 0, ErrOp
+	}
+	if math.IsInf(v, 0) && !math.IsInf(a, 0) && !math.IsInf(b, 0) {
+		return 0, ErrOverflow
+	}
+	return v, nil
+}
+
+func arithDecimal(op Op, a *dt.Decimal, b *dt.Decimal) (dt.DtRefer, error) {
+	switch op {
+	case OpAdd:
+		return a.Add(b), nil
+	case OpSub:
+		return a.Sub(b), nil
+	case OpMul:
+		return a.Mul(b), nil
+	case OpMod:
+		return a.Mod(b)
+	}
+	scale := a.Scale()
+	if b.Scale() > scale {
+		scale = b.Scale()
+	}
+	d, err := a.Div(b, scale+divScale, dt.RoundHalfEven)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// ArithInt64 is op on two integers, it divides toward zero like go and an
+// integer that overflows is ErrOverflow
+func ArithInt64(op Op, a int64, b int64) (int64, error) {
+	switch op {
+	case OpAdd:
+		v := a + b
+		if (b > 0 && v < a) || (b < 0 && v > a) {
+			return 0, ErrOverflow
+		}
+		return v, nil
+	case OpSub:
+		v := a - b
+		if (b > 0 && v > a) || (b < 0 && v < a) {
+			return 0, ErrOverflow
+		}
+		return v, nil
+	case OpMul:
+		if a == 0 || b == 0 {
+			return 0, nil
+		}
+		v := a * b
+		if v/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+			return 0, ErrOverflow
+		}
+		return v, nil
+	case OpDiv:
+		if b == 0 {
+			return 0, dt.ErrDivisionByZero
+		}
+		if a == math.MinInt64 && b == -1 {
+			return 0, ErrOverflow
+		}
+		return a / b, nil
+	case OpMod:
+		if b == 0 {
+			return 0, dt.ErrDivisionByZero
+		}
+		//MinInt64 % -1 is 0 in go
+		return a % b, nil
+	}
+	return 0, ErrOp
+}
+
+// Concat is a || b, the values are cast to text like 'no.' || 1
+type Concat struct {
+	Left, Right Expr
+}
+
+func (e *Concat) Eval(row Row) (dt.DtRefer, error) {
+	l, err := e.Left.Eval(row)
+	if err != nil {
+		return nil, err
+	}
+	r, err := e.Right.Eval(row)
+	if err != nil || nil == l || nil == r {
+		return nil, err
+	}
+	a, err := dt.Cast(l, dt.StringType)
+	if err != nil {
+		return nil, err
+	}
+	b, err := dt.Cast(r, dt.StringType)
+	if err != nil {
+		return nil, err
+	}
+	return dt.ValidNewString(a.(string) + b.(string)), nil
+}
+
+func (e *Concat) String() string {
+	return "(" + e.Left.String() + " || " + e.Right.String() + ")"
+}
@@ -0,0 +1,90 @@
+package expr
+
+import (
+	"fmt"
+	"github.com/lycying/pitydb/dt"
+	"math"
+	"testing"
+)
+
+func TestArith(t *testing.T) {
+	row := mapRow{
+		"price": dt.ValidNewFloat64(9.5),
+		"qty":   dt.ValidNewInt32(3),
+		"cost":  dt.NewDecimalWithPrecision(10, 2),
+		"big":   dt.ValidInt64(1 << 62),
+		"huge":  dt.ValidNewFloat64(math.MaxFloat64),
+		"inf":   dt.ValidNewFloat64(math.Inf(1)),
+		"note":  nil,
+	}
+	row["cost"].SetValue("2.50")
+
+	cases := []struct{ in, want string }{
+		{"qty * 2 + 1", "7"},
+		{"-qty - 1", "-4"},
+		{"7 / 2", "3"},
+		{"-7 / 2", "-3"},
+		{"price * qty", "28.5"},
+		{"cost * qty", "7.50"},
+		{"cost / 3", "0.83333333"},
+		{"cost + 0.005", "2.505"},
+		{"qty + note", "NULL"},
+		{"inf * 2", "+Inf"},
+		{"-7 % 3", "-1"},
+		{"price % 4", "1.5"},
+		{"cost % 1", "0.50"},
+		{"'no.' || qty || '/' || cost", "no.3/2.50"},
+		{"qty || note", "NULL"},
+	}
+	for _, c := range cases {
+		v, err := MustParse(c.in).Eval(row)
+		if err != nil {
+			t.Fatal(c.in, err)
+		}
+		got := "NULL"
+		if nil != v {
+			got = fmt.Sprint(v.GetValue())
+		}
+		if got != c.want {
+			t.Fatal(c.in, " should be ", c.want, ",but ", got)
+		}
+	}
+
+	errs := []struct {
+		in  string
+		err error
+	}{
+		{"big * 4", ErrOverflow},
+		{"huge * 2", ErrOverflow},
+		{"-huge - huge", ErrOverflow},
+		{"huge / 0.5", ErrOverflow},
+		{"qty / 0", dt.ErrDivisionByZero},
+		{"cost / 0", dt.ErrDivisionByZero},
+		{"qty + 'x'", ErrNotNumber},
+		{"qty % 0", dt.ErrDivisionByZero},
+	}
+	for _, c := range errs {
+		if _, err := MustParse(c.in).Eval(row); err != c.err {
+			t.Fatal(c.in, " should fail with ", c.err, ",but ", err)
+		}
+	}
+}
+
+func TestEval_Error(t *testing.T) {
+	row := mapRow{"qty": dt.ValidNewInt32(3)}
+	cases := []struct {
+		e   Expr
+		err error
+	}{
+		{&Binary{Op: OpAdd, Left: &Column{Name: "qty"}, Right: &Literal{Value: dt.ValidInt64(1)}}, ErrOp},
+		{&Arith{Op: OpEq, Left: &Column{Name: "qty"}, Right: &Literal{Value: dt.ValidInt64(1)}}, ErrOp},
+		{&Call{Name: "foo"}, ErrNoFunction},
+		{&Call{Name: "nextval"}, ErrNoFunction},
+		{&Column{Name: "nope"}, ErrNoColumn},
+	}
+	for _, c := range cases {
+		if _, err := c.e.Eval(row); err != c.err {
+			t.Fatal(c.e, " should fail with ", c.err, ",but ", err)
+		}
+	}
+}
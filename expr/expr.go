@@ -0,0 +1,324 @@
+// Package expr evaluates the expressions of the schema, like the CHECK
+// constraints, against a row. A NULL is a nil dt.DtRefer.
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"github.com/lycying/pitydb/dt"
+	"strings"
+)
+
+var ErrNoColumn = errors.New("no such column")
+var ErrNotBool = errors.New("the expression is not a bool")
+var ErrOp = errors.New("the operator is not known by the node")
+
+func init() {
+	dt.CheckValidator = func(meta *dt.RowMeta, check string) error {
+		_, err := ParseCheck(check, meta)
+		return err
+	}
+}
+
+// Row is what an expression is evaluated against, yard.Row implements it
+type Row interface {
+	// GetCellByName returns nil for a NULL cell, or ErrNoColumn
+	GetCellByName(name string) (dt.DtRefer, error)
+}
+
+// Expr is a node of an expression tree
+type Expr interface {
+	// Eval returns nil for NULL
+	Eval(row Row) (dt.DtRefer, error)
+	// String returns the text of the expression, Parse reads it back
+	String() string
+}
+
+type Op int
+
+const (
+	OpEq Op = iota
+	OpNe
+	OpLt
+	OpLe
+	OpGt
+	OpGe
+	OpAnd
+	OpOr
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+	OpConcat
+)
+
+var opNames = []string{"=", "<>", "<", "<=", ">", ">=", "AND", "OR", "+", "-", "*", "/", "%", "||"}
+
+func (op Op) String() string {
+	if op < 0 || int(op) >= len(opNames) {
+		return fmt.Sprintf("Op(%d)", int(op))
+	}
+	return opNames[op]
+}
+
+func (op Op) isComparison() bool {
+	return op >= OpEq && op <= OpGe
+}
+
+// Literal is a constant, a nil value is NULL
+type Literal struct {
+	Value dt.DtRefer
+}
+
+func (e *Literal) Eval(row Row) (dt.DtRefer, error) {
+	return e.Value, nil
+}
+
+func (e *Literal) String() string {
+	switch v := e.Value.(type) {
+	case nil:
+		return "NULL"
+	case *dt.String:
+		return quote(v.GetValue().(string), '\'')
+	case *dt.Bool:
+		if v.GetValue().(bool) {
+			return "TRUE"
+		}
+		return "FALSE"
+	case *dt.Decimal:
+		return v.String()
+	}
+	return fmt.Sprint(e.Value.GetValue())
+}
+
+// Column is the cell of the row of the name
+type Column struct {
+	Name string
+}
+
+func (e *Column) Eval(row Row) (dt.DtRefer, error) {
+	return row.GetCellByName(e.Name)
+}
+
+func (e *Column) String() string {
+	if isIdent(e.Name) {
+		return e.Name
+	}
+	return quote(e.Name, '"')
+}
+
+// Binary is a comparison or AND, OR
+type Binary struct {
+	Op          Op
+	Left, Right Expr
+}
+
+func (e *Binary) Eval(row Row) (dt.DtRefer, error) {
+	switch {
+	case e.Op == OpAnd || e.Op == OpOr:
+		return e.evalLogic(row)
+	case !e.Op.isComparison():
+		return nil, ErrOp
+	}
+	l, err := e.Left.Eval(row)
+	if err != nil {
+		return nil, err
+	}
+	r, err := e.Right.Eval(row)
+	if err != nil || nil == l || nil == r {
+		return nil, err
+	}
+	c, err := compare(l, r)
+	if err != nil {
+		return nil, err
+	}
+	var ret bool
+	switch e.Op {
+	case OpEq:
+		ret = c == 0
+	case OpNe:
+		ret = c != 0
+	case OpLt:
+		ret = c < 0
+	case OpLe:
+		ret = c <= 0
+	case OpGt:
+		ret = c > 0
+	case OpGe:
+		ret = c >= 0
+	}
+	return dt.ValidNewBool(ret), nil
+}
+
+// evalLogic follows the three valued logic, false AND NULL is false and true OR NULL is true
+func (e *Binary) evalLogic(row Row) (dt.DtRefer, error) {
+	l, err := evalBool(e.Left, row)
+	if err != nil {
+		return nil, err
+	}
+	//the right side is not evaluated if the left side decides
+	if nil != l && *l == (e.Op == OpOr) {
+		return dt.ValidNewBool(*l), nil
+	}
+	r, err := evalBool(e.Right, row)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case nil != r && *r == (e.Op == OpOr):
+		return dt.ValidNewBool(*r), nil
+	case nil == l || nil == r:
+		return nil, nil
+	}
+	return dt.ValidNewBool(*r), nil
+}
+
+func (e *Binary) String() string {
+	return "(" + e.Left.String() + " " + e.Op.String() + " " + e.Right.String() + ")"
+}
+
+// Not is NOT x, NOT NULL is NULL
+type Not struct {
+	X Expr
+}
+
+func (e *Not) Eval(row Row) (dt.DtRefer, error) {
+	b, err := evalBool(e.X, row)
+	if err != nil || nil == b {
+		return nil, err
+	}
+	return dt.ValidNewBool(!*b), nil
+}
+
+func (e *Not) String() string {
+	return "(NOT " + e.X.String() + ")"
+}
+
+// IsNull is x IS NULL, or x IS NOT NULL if Not is true
+type IsNull struct {
+	X   Expr
+	Not bool
+}
+
+func (e *IsNull) Eval(row Row) (dt.DtRefer, error) {
+	v, err := e.X.Eval(row)
+	if err != nil {
+		return nil, err
+	}
+	return dt.ValidNewBool((nil == v) != e.Not), nil
+}
+
+func (e *IsNull) String() string {
+	if e.Not {
+		return "(" + e.X.String() + " IS NOT NULL)"
+	}
+	return "(" + e.X.String() + " IS NULL)"
+}
+
+// In is x IN (list...), or x NOT IN (list...) if Not is true. It is NULL
+// if x is NULL, or if x is not found and the list has a NULL.
+type In struct {
+	X    Expr
+	List []Expr
+	Not  bool
+}
+
+func (e *In) Eval(row Row) (dt.DtRefer, error) {
+	v, err := e.X.Eval(row)
+	if err != nil || nil == v {
+		return nil, err
+	}
+	hasNull := false
+	for _, item := range e.List {
+		o, err := item.Eval(row)
+		if err != nil {
+			return nil, err
+		}
+		if nil == o {
+			hasNull = true
+			continue
+		}
+		c, err := compare(v, o)
+		if err != nil {
+			return nil, err
+		}
+		if c == 0 {
+			return dt.ValidNewBool(!e.Not), nil
+		}
+	}
+	if hasNull {
+		return nil, nil
+	}
+	return dt.ValidNewBool(e.Not), nil
+}
+
+func (e *In) String() string {
+	items := make([]string, len(e.List))
+	for i, item := range e.List {
+		items[i] = item.String()
+	}
+	op := " IN ("
+	if e.Not {
+		op = " NOT IN ("
+	}
+	return "(" + e.X.String() + op + strings.Join(items, ", ") + "))"
+}
+
+// ParseCheck parses the condition of a CHECK and checks it is a bool of the
+// cells of meta
+func ParseCheck(check string, meta *dt.RowMeta) (Expr, error) {
+	cond, err := Parse(check)
+	if err != nil {
+		return nil, err
+	}
+	typ, err := TypeOf(cond, meta)
+	if err != nil {
+		return nil, err
+	}
+	if typ != dt.BoolType && typ != NullType {
+		return nil, ErrNotBool
+	}
+	return cond, nil
+}
+
+// EvalBool evaluates a condition, a NULL condition returns nil
+func EvalBool(e Expr, row Row) (*bool, error) {
+	return evalBool(e, row)
+}
+
+func evalBool(e Expr, row Row) (*bool, error) {
+	v, err := e.Eval(row)
+	if err != nil || nil == v {
+		return nil, err
+	}
+	b, ok := v.GetValue().(bool)
+	if !ok {
+		return nil, ErrNotBool
+	}
+	return &b, nil
+}
+
+// compare compares the values of two types by casting one of them to the
+// type of the other, like the text of a time to the time
+func compare(a dt.DtRefer, b dt.DtRefer) (int, error) {
+	c, err := dt.CompareValues(a, b)
+	if err != dt.ErrIncomparable {
+		return c, err
+	}
+	//a text is cast to the other type first, a copy keeps the declared type like the labels of an enum
+	if _, ok := a.(*dt.String); !ok {
+		if o := a.Copy(); dt.TrySetValue(o, b) == nil {
+			return a.Compare(o), nil
+		}
+	}
+	if o := b.Copy(); dt.TrySetValue(o, a) == nil {
+		return o.Compare(b), nil
+	}
+	return 0, dt.ErrIncomparable
+}
+
+func quote(s string, q byte) string {
+	qs := string(q)
+	return qs + strings.Replace(s, qs, qs+qs, -1) + qs
+}
@@ -0,0 +1,77 @@
+package expr
+
+import (
+	"github.com/lycying/pitydb/dt"
+	"testing"
+)
+
+// mapRow is a row of the cells by name
+type mapRow map[string]dt.DtRefer
+
+func (r mapRow) GetCellByName(name string) (dt.DtRefer, error) {
+	cell, ok := r[name]
+	if !ok {
+		return nil, ErrNoColumn
+	}
+	return cell, nil
+}
+
+func evalString(t *testing.T, s string, row Row) string {
+	b, err := EvalBool(MustParse(s), row)
+	if err != nil {
+		t.Fatal(s, err)
+	}
+	if nil == b {
+		return "NULL"
+	}
+	if *b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+func TestEval(t *testing.T) {
+	at := dt.NewTimeWithKind(dt.DateKind)
+	at.SetString("2024-02-29")
+	row := mapRow{
+		"price":  dt.ValidNewFloat64(9.5),
+		"qty":    dt.ValidNewInt32(3),
+		"name":   dt.ValidNewString("Pity"),
+		"status": dt.NewEnum([]string{"new", "paid", "shipped"}),
+		"at":     at,
+		"note":   nil,
+	}
+	row["status"].SetValue("paid")
+
+	cases := []struct{ in, want string }{
+		{"price > 9 AND qty <= 3", "TRUE"},
+		{"price = 9.5 AND qty = 3.0", "TRUE"},
+		{"name = 'pity'", "TRUE"}, //the collation of the cell
+		{"status > 'new' AND status < 'shipped'", "TRUE"},
+		{"status IN ('new', 'shipped')", "FALSE"},
+		{"at >= '2024-01-01'", "TRUE"},
+		{"note = 'x'", "NULL"},
+		{"note = 'x' OR qty > 1", "TRUE"},
+		{"note = 'x' AND qty > 5", "FALSE"},
+		{"note = 'x' AND qty > 1", "NULL"},
+		{"NOT (note = 'x')", "NULL"},
+		{"note IS NULL AND name IS NOT NULL", "TRUE"},
+		{"qty IN (1, NULL)", "NULL"},
+		{"qty NOT IN (1, 2)", "TRUE"},
+	}
+	for _, c := range cases {
+		if got := evalString(t, c.in, row); got != c.want {
+			t.Fatal(c.in, " should be ", c.want, ",but ", got)
+		}
+	}
+
+	for _, s := range []string{"missing > 1", "name > 1", "qty"} {
+		if _, err := EvalBool(MustParse(s), row); err == nil {
+			t.Fatal(s, " should fail")
+		}
+	}
+	//the right side is never evaluated
+	if evalString(t, "qty > 5 AND missing > 1", row) != "FALSE" {
+		t.Fatal("AND should stop at false")
+	}
+}
@@ -0,0 +1,89 @@
+package expr
+
+import (
+	"errors"
+	"github.com/lycying/pitydb/dt"
+	"strings"
+	"time"
+)
+
+var ErrNoSequence = errors.New("the row can not hand out the values of a sequence")
+var ErrNoFunction = errors.New("no such function")
+
+// SequenceRow is a Row that finds the sequences of nextval, yard.Row implements it
+type SequenceRow interface {
+	Row
+	NextValue(sequence string) (int64, error)
+}
+
+type function struct {
+	args int
+	typ  dt.DType //the type of the result
+	eval func(row Row, args []dt.DtRefer) (dt.DtRefer, error)
+}
+
+// functions are called by name in any case
+var functions = map[string]*function{
+	"now": {0, dt.TimeType, func(row Row, args []dt.DtRefer) (dt.DtRefer, error) {
+		t := dt.NewTime()
+		t.SetTime(time.Now())
+		return t, nil
+	}},
+	"current_date": {0, dt.TimeType, func(row Row, args []dt.DtRefer) (dt.DtRefer, error) {
+		t := dt.NewTimeWithKind(dt.DateKind)
+		t.SetTime(time.Now())
+		return t, nil
+	}},
+	"uuid": {0, dt.UUIDType, func(row Row, args []dt.DtRefer) (dt.DtRefer, error) {
+		return dt.NewUUIDv4()
+	}},
+	"uuid_v7": {0, dt.UUIDType, func(row Row, args []dt.DtRefer) (dt.DtRefer, error) {
+		return dt.NewUUIDv7()
+	}},
+	"nextval": {1, dt.Int64Type, func(row Row, args []dt.DtRefer) (dt.DtRefer, error) {
+		seq, ok := row.(SequenceRow)
+		if !ok {
+			return nil, ErrNoSequence
+		}
+		name, ok := args[0].GetValue().(string)
+		if !ok {
+			return nil, ErrNoSequence
+		}
+		v, err := seq.NextValue(name)
+		if err != nil {
+			return nil, err
+		}
+		return dt.ValidInt64(v), nil
+	}},
+}
+
+// Call is a function like now(), uuid(), uuid_v7(), current_date() or nextval('name')
+type Call struct {
+	Name string
+	Args []Expr
+}
+
+func (e *Call) Eval(row Row) (dt.DtRefer, error) {
+	fn, ok := functions[strings.ToLower(e.Name)]
+	if !ok || len(e.Args) != fn.args {
+		return nil, ErrNoFunction
+	}
+	args := make([]dt.DtRefer, len(e.Args))
+	for i, arg := range e.Args {
+		v, err := arg.Eval(row)
+		if err != nil || nil == v {
+			//a function of NULL is NULL
+			return nil, err
+		}
+		args[i] = v
+	}
+	return fn.eval(row, args)
+}
+
+func (e *Call) String() string {
+	args := make([]string, len(e.Args))
+	for i, arg := range e.Args {
+		args[i] = arg.String()
+	}
+	return strings.ToLower(e.Name) + "(" + strings.Join(args, ", ") + ")"
+}
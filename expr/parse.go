@@ -0,0 +1,458 @@
+package expr
+
+import (
+	"fmt"
+	"github.com/lycying/pitydb/dt"
+	"strings"
+	"unicode"
+)
+
+// SyntaxError is returned by Parse, Pos is the byte offset of the error
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at %d: %s", e.Pos, e.Msg)
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokQuotedIdent
+	tokNumber
+	tokString
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// keyword reports whether the token is the keyword kw, in any case
+func (t token) keyword(kw string) bool {
+	return t.kind == tokIdent && strings.EqualFold(t.text, kw)
+}
+
+func isIdentStart(c rune) bool {
+	return c == '_' || unicode.IsLetter(c)
+}
+
+func isIdentPart(c rune) bool {
+	return isIdentStart(c) || unicode.IsDigit(c)
+}
+
+var keywords = []string{"AND", "OR", "NOT", "IS", "NULL", "IN", "TRUE", "FALSE"}
+
+// isIdent reports whether name can be written without quotes
+func isIdent(name string) bool {
+	for i, c := range name {
+		if !isIdentPart(c) || (i == 0 && !isIdentStart(c)) {
+			return false
+		}
+	}
+	for _, kw := range keywords {
+		if strings.EqualFold(name, kw) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+func lex(s string) ([]token, error) {
+	var tokens []token
+	rs := []rune(s)
+	pos := func(i int) int {
+		return len(string(rs[:i]))
+	}
+	for i := 0; i < len(rs); {
+		c := rs[i]
+		start := i
+		switch {
+		case unicode.IsSpace(c):
+			i++
+			continue
+		case isIdentStart(c):
+			for i < len(rs) && isIdentPart(rs[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokIdent, string(rs[start:i]), pos(start)})
+			continue
+		case unicode.IsDigit(c) || (c == '.' && i+1 < len(rs) && unicode.IsDigit(rs[i+1])):
+			for i < len(rs) && (unicode.IsDigit(rs[i]) || rs[i] == '.') {
+				i++
+			}
+			//an exponent
+			if i < len(rs) && (rs[i] == 'e' || rs[i] == 'E') {
+				i++
+				if i < len(rs) && (rs[i] == '+' || rs[i] == '-') {
+					i++
+				}
+				for i < len(rs) && unicode.IsDigit(rs[i]) {
+					i++
+				}
+			}
+			tokens = append(tokens, token{tokNumber, string(rs[start:i]), pos(start)})
+			continue
+		case c == '\'' || c == '"':
+			var b strings.Builder
+			i++
+			for {
+				if i >= len(rs) {
+					return nil, &SyntaxError{pos(start), "the quote is not closed"}
+				}
+				if rs[i] == c {
+					//a doubled quote is the quote itself
+					if i+1 < len(rs) && rs[i+1] == c {
+						b.WriteRune(c)
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				b.WriteRune(rs[i])
+				i++
+			}
+			kind := tokString
+			if c == '"' {
+				kind = tokQuotedIdent
+			}
+			tokens = append(tokens, token{kind, b.String(), pos(start)})
+			continue
+		}
+		//the operators of two runes first
+		if i+1 < len(rs) {
+			switch op := string(rs[i : i+2]); op {
+			case "<=", ">=", "<>", "!=", "||":
+				tokens = append(tokens, token{tokOp, op, pos(start)})
+				i += 2
+				continue
+			}
+		}
+		switch c {
+		case '=', '<', '>', '(', ')', ',', '+', '-', '*', '/', '%':
+			tokens = append(tokens, token{tokOp, string(c), pos(start)})
+			i++
+		default:
+			return nil, &SyntaxError{pos(start), fmt.Sprintf("unexpected %q", c)}
+		}
+	}
+	return append(tokens, token{tokEOF, "", len(s)}), nil
+}
+
+type parser struct {
+	tokens []token
+	i      int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return &SyntaxError{t.pos, fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) expectOp(op string) error {
+	if t := p.next(); t.kind != tokOp || t.text != op {
+		return p.errorf(t, "%s expected", op)
+	}
+	return nil
+}
+
+// Parse reads an expression like "price > 0 AND status IN ('new', 'paid')".
+// The keywords are in any case, a column may be quoted like "order" and a
+// quote is written twice in a quoted text.
+func Parse(s string) (Expr, error) {
+	tokens, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "unexpected %q", t.text)
+	}
+	return e, nil
+}
+
+// MustParse is Parse that panics, for the expressions known to be right
+func MustParse(s string) Expr {
+	e, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().keyword("OR") {
+		p.next()
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = &Binary{Op: OpOr, Left: l, Right: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	l, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().keyword("AND") {
+		p.next()
+		r, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l = &Binary{Op: OpAnd, Left: l, Right: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseNot() (Expr, error) {
+	if p.peek().keyword("NOT") {
+		p.next()
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{X: x}, nil
+	}
+	return p.parseComparison()
+}
+
+var compareOps = map[string]Op{
+	"=": OpEq, "<>": OpNe, "!=": OpNe, "<": OpLt, "<=": OpLe, ">": OpGt, ">=": OpGe,
+}
+
+func (p *parser) parseComparison() (Expr, error) {
+	l, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if op, ok := compareOps[t.text]; ok && t.kind == tokOp {
+		p.next()
+		r, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return &Binary{Op: op, Left: l, Right: r}, nil
+	}
+	if t.keyword("IS") {
+		p.next()
+		not := p.peek().keyword("NOT")
+		if not {
+			p.next()
+		}
+		if t := p.next(); !t.keyword("NULL") {
+			return nil, p.errorf(t, "NULL expected")
+		}
+		return &IsNull{X: l, Not: not}, nil
+	}
+	not := false
+	if t.keyword("NOT") && p.tokens[p.i+1].keyword("IN") {
+		p.next()
+		not = true
+	}
+	if p.peek().keyword("IN") {
+		p.next()
+		list, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return &In{X: l, List: list, Not: not}, nil
+	}
+	return l, nil
+}
+
+func (p *parser) parseList() ([]Expr, error) {
+	if err := p.expectOp("("); err != nil {
+		return nil, err
+	}
+	var list []Expr
+	for {
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+		if t := p.peek(); t.kind == tokOp && t.text == "," {
+			p.next()
+			continue
+		}
+		return list, p.expectOp(")")
+	}
+}
+
+// isOp reports whether the next token is one of ops
+func (p *parser) isOp(ops ...string) bool {
+	t := p.peek()
+	if t.kind != tokOp {
+		return false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+var arithOps = map[string]Op{"+": OpAdd, "-": OpSub, "*": OpMul, "/": OpDiv, "%": OpMod}
+
+func (p *parser) parseAdditive() (Expr, error) {
+	l, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+", "-", "||") {
+		t := p.next()
+		r, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		if t.text == "||" {
+			l = &Concat{Left: l, Right: r}
+			continue
+		}
+		l = &Arith{Op: arithOps[t.text], Left: l, Right: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseMultiplicative() (Expr, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*", "/", "%") {
+		op := arithOps[p.next().text]
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = &Arith{Op: op, Left: l, Right: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if !p.isOp("-") {
+		return p.parseOperand()
+	}
+	p.next()
+	//a negative number is a literal
+	if t := p.peek(); t.kind == tokNumber {
+		p.next()
+		return parseNumber(token{tokNumber, "-" + t.text, t.pos - 1})
+	}
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return &Neg{X: x}, nil
+}
+
+// parseCall reads the arguments of the function name
+func (p *parser) parseCall(name token) (Expr, error) {
+	fn, ok := functions[strings.ToLower(name.text)]
+	if !ok {
+		return nil, p.errorf(name, "unknown function %s", name.text)
+	}
+	var args []Expr
+	if p.isOp("(") && p.tokens[p.i+1].kind == tokOp && p.tokens[p.i+1].text == ")" {
+		p.next()
+		p.next()
+	} else {
+		var err error
+		if args, err = p.parseList(); err != nil {
+			return nil, err
+		}
+	}
+	if len(args) != fn.args {
+		return nil, p.errorf(name, "%s takes %d arguments", name.text, fn.args)
+	}
+	return &Call{Name: strings.ToLower(name.text), Args: args}, nil
+}
+
+// parseOperand reads a value, a function or an expression in parentheses
+func (p *parser) parseOperand() (Expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		return parseNumber(t)
+	case tokString:
+		return &Literal{Value: dt.ValidNewString(t.text)}, nil
+	case tokQuotedIdent:
+		return &Column{Name: t.text}, nil
+	case tokIdent:
+		switch {
+		case t.keyword("NULL"):
+			return &Literal{}, nil
+		case t.keyword("TRUE"), t.keyword("FALSE"):
+			return &Literal{Value: dt.ValidNewBool(t.keyword("TRUE"))}, nil
+		}
+		for _, kw := range keywords {
+			if t.keyword(kw) {
+				return nil, p.errorf(t, "unexpected %s", strings.ToUpper(t.text))
+			}
+		}
+		if p.isOp("(") {
+			return p.parseCall(t)
+		}
+		return &Column{Name: t.text}, nil
+	case tokOp:
+		if t.text == "(" {
+			e, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return e, p.expectOp(")")
+		}
+	case tokEOF:
+		return nil, p.errorf(t, "unexpected end")
+	}
+	return nil, p.errorf(t, "unexpected %q", t.text)
+}
+
+// parseNumber reads an integer as Int64Type and the other numbers as DecimalType,
+// so a literal keeps its exact value
+func parseNumber(t token) (Expr, error) {
+	if !strings.ContainsAny(t.text, ".eE") {
+		v, err := dt.Cast(t.text, dt.Int64Type)
+		if err == nil {
+			return &Literal{Value: dt.ValidInt64(v.(int64))}, nil
+		}
+	}
+	d, err := dt.ValidNewDecimal(t.text)
+	if err != nil {
+		return nil, &SyntaxError{t.pos, fmt.Sprintf("bad number %q", t.text)}
+	}
+	return &Literal{Value: d}, nil
+}
@@ -0,0 +1,58 @@
+package expr
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	cases := []struct{ in, out string }{
+		{"price > 0", "(price > 0)"},
+		{"a = 1 AND b <> 'x' OR NOT c", "(((a = 1) AND (b <> 'x')) OR (NOT c))"},
+		{"a != 1.50 and (b or c)", "((a <> 1.50) AND (b OR c))"},
+		{"status in ('new', 'it''s') AND x NOT IN (1, NULL)", "((status IN ('new', 'it''s')) AND (x NOT IN (1, NULL)))"},
+		{"\"my col\" is not null and \"in\" is null", "((\"my col\" IS NOT NULL) AND (\"in\" IS NULL))"},
+		{"flag = true", "(flag = TRUE)"},
+		{"n >= 1e3", "(n >= 1000)"},
+		{"a + b * -c - -2 / (d - 1)", "((a + (b * (-c))) - (-2 / (d - 1)))"},
+		{"price * qty > 100", "((price * qty) > 100)"},
+		{"NOW() > at AND id = nextval('ids')", "((now() > at) AND (id = nextval('ids')))"},
+		{"a % 2 = 0 AND 'x' || a || b <> ''", "(((a % 2) = 0) AND ((('x' || a) || b) <> ''))"},
+	}
+	for _, c := range cases {
+		e, err := Parse(c.in)
+		if err != nil {
+			t.Fatal(c.in, err)
+		}
+		if e.String() != c.out {
+			t.Fatal(c.in, " should be ", c.out, ",but ", e.String())
+		}
+		//the text is read back to the same expression
+		if again, err := Parse(e.String()); err != nil || again.String() != c.out {
+			t.Fatal("should parse ", e.String(), err)
+		}
+	}
+}
+
+func TestParse_Error(t *testing.T) {
+	cases := []struct {
+		in  string
+		pos int
+	}{
+		{"a >", 3},
+		{"a > 'x", 4},
+		{"(a > 1", 6},
+		{"a > 1 b", 6},
+		{"a ? 1", 2},
+		{"a is 1", 5},
+		{"and > 1", 0},
+		{"a in 1", 5},
+		{"a > foo()", 4},
+		{"a > now(1)", 4},
+		{"a + * 1", 4},
+	}
+	for _, c := range cases {
+		_, err := Parse(c.in)
+		se, ok := err.(*SyntaxError)
+		if !ok || se.Pos != c.pos {
+			t.Fatal(c.in, " should fail at ", c.pos, ",but ", err)
+		}
+	}
+}
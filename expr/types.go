@@ -0,0 +1,183 @@
+package expr
+
+import (
+	"github.com/lycying/pitydb/dt"
+	"strings"
+)
+
+// NullType is the type of the NULL literal, it is promoted to any type
+const NullType dt.DType = -1
+
+// TypeOf checks the expression against the cells of meta and returns the type
+// of its result, so a wrong expression is found before it is evaluated. The
+// arithmetic promotes the integers to Int64Type, to DecimalType if one side
+// is a decimal and to Float64Type if one side is a float.
+func TypeOf(e Expr, meta *dt.RowMeta) (dt.DType, error) {
+	switch e := e.(type) {
+	case *Literal:
+		if nil == e.Value {
+			return NullType, nil
+		}
+		typ, ok := dt.TypeOf(e.Value)
+		if !ok {
+			return 0, dt.ErrCastType
+		}
+		return typ, nil
+	case *Column:
+		item := meta.GetCellMeta(e.Name)
+		if nil == item {
+			return 0, ErrNoColumn
+		}
+		return item.GetMType(), nil
+	case *Binary:
+		l, err := TypeOf(e.Left, meta)
+		if err != nil {
+			return 0, err
+		}
+		r, err := TypeOf(e.Right, meta)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case e.Op == OpAnd || e.Op == OpOr:
+			if !isBoolType(l) || !isBoolType(r) {
+				return 0, ErrNotBool
+			}
+		case e.Op.isComparison():
+			if !comparableTypes(l, r) {
+				return 0, dt.ErrIncomparable
+			}
+		default:
+			return 0, ErrOp
+		}
+		return dt.BoolType, nil
+	case *Not:
+		x, err := TypeOf(e.X, meta)
+		if err != nil {
+			return 0, err
+		}
+		if !isBoolType(x) {
+			return 0, ErrNotBool
+		}
+		return dt.BoolType, nil
+	case *IsNull:
+		if _, err := TypeOf(e.X, meta); err != nil {
+			return 0, err
+		}
+		return dt.BoolType, nil
+	case *In:
+		x, err := TypeOf(e.X, meta)
+		if err != nil {
+			return 0, err
+		}
+		for _, item := range e.List {
+			typ, err := TypeOf(item, meta)
+			if err != nil {
+				return 0, err
+			}
+			if !comparableTypes(x, typ) {
+				return 0, dt.ErrIncomparable
+			}
+		}
+		return dt.BoolType, nil
+	case *Arith:
+		if e.Op < OpAdd || e.Op > OpMod {
+			return 0, ErrOp
+		}
+		l, err := TypeOf(e.Left, meta)
+		if err != nil {
+			return 0, err
+		}
+		r, err := TypeOf(e.Right, meta)
+		if err != nil {
+			return 0, err
+		}
+		return promote(l, r)
+	case *Neg:
+		x, err := TypeOf(e.X, meta)
+		if err != nil {
+			return 0, err
+		}
+		return promote(dt.Int64Type, x)
+	case *Concat:
+		if _, err := TypeOf(e.Left, meta); err != nil {
+			return 0, err
+		}
+		if _, err := TypeOf(e.Right, meta); err != nil {
+			return 0, err
+		}
+		return dt.StringType, nil
+	case *Call:
+		fn, ok := functions[strings.ToLower(e.Name)]
+		if !ok || len(e.Args) != fn.args {
+			return 0, ErrNoFunction
+		}
+		for _, arg := range e.Args {
+			if _, err := TypeOf(arg, meta); err != nil {
+				return 0, err
+			}
+		}
+		return fn.typ, nil
+	}
+	return 0, ErrOp
+}
+
+func isBoolType(typ dt.DType) bool {
+	return typ == dt.BoolType || typ == NullType
+}
+
+func numKindOf(typ dt.DType) (numKind, bool) {
+	switch typ {
+	case dt.ByteType, dt.Int32Type, dt.UInt32Type, dt.Int64Type, dt.UInt64Type:
+		return intKind, true
+	case dt.DecimalType:
+		return decimalKind, true
+	case dt.Float32Type, dt.Float64Type:
+		return floatKind, true
+	}
+	return 0, false
+}
+
+// promote returns the type of an arithmetic on l and r
+func promote(l dt.DType, r dt.DType) (dt.DType, error) {
+	if l == NullType && r == NullType {
+		return NullType, nil
+	}
+	kind := intKind
+	for _, typ := range []dt.DType{l, r} {
+		if typ == NullType {
+			continue
+		}
+		k, ok := numKindOf(typ)
+		if !ok {
+			return 0, ErrNotNumber
+		}
+		if k > kind {
+			kind = k
+		}
+	}
+	switch kind {
+	case floatKind:
+		return dt.Float64Type, nil
+	case decimalKind:
+		return dt.DecimalType, nil
+	}
+	return dt.Int64Type, nil
+}
+
+// comparableTypes follows compare, the numbers are compared with each other,
+// a text is cast to the other type and an integer to the ordinal of an enum
+func comparableTypes(l dt.DType, r dt.DType) bool {
+	if l == r || l == NullType || r == NullType || l == dt.StringType || r == dt.StringType {
+		return true
+	}
+	lk, lok := numKindOf(l)
+	rk, rok := numKindOf(r)
+	switch {
+	case l == dt.EnumType:
+		return rok && rk == intKind
+	case r == dt.EnumType:
+		return lok && lk == intKind
+	}
+	return lok && rok
+}
@@ -0,0 +1,55 @@
+package expr
+
+import (
+	"github.com/lycying/pitydb/dt"
+	"testing"
+)
+
+func TestTypeOf(t *testing.T) {
+	meta := dt.NewRowMeta()
+	meta.AddCellMeta(dt.NewCellMetaRaw(0, dt.Int32Type, "qty", "", nil))
+	meta.AddCellMeta(dt.NewCellMetaRaw(1, dt.DecimalType, "price", "", nil))
+	meta.AddCellMeta(dt.NewCellMetaRaw(2, dt.Float32Type, "weight", "", nil))
+	meta.AddCellMeta(dt.NewCellMetaRaw(3, dt.StringType, "name", "", nil))
+	meta.AddCellMeta(dt.NewCellMetaRaw(4, dt.TimeType, "at", "", nil))
+	meta.AddCellMeta(dt.NewCellMetaRaw(5, dt.EnumType, "status", "", nil).WithEnumLabels("new", "paid"))
+
+	cases := []struct {
+		in   string
+		want dt.DType
+	}{
+		{"qty * 2 % 3", dt.Int64Type},
+		{"qty * price", dt.DecimalType},
+		{"price - weight", dt.Float64Type},
+		{"-qty", dt.Int64Type},
+		{"qty + NULL", dt.Int64Type},
+		{"NULL + NULL", NullType},
+		{"name || qty", dt.StringType},
+		{"at > '2024-01-01' AND status IN ('new', 1) OR NULL", dt.BoolType},
+		{"now()", dt.TimeType},
+		{"nextval('ids') + 1", dt.Int64Type},
+	}
+	for _, c := range cases {
+		typ, err := TypeOf(MustParse(c.in), meta)
+		if err != nil || typ != c.want {
+			t.Fatal(c.in, " should be ", c.want, ",but ", typ, err)
+		}
+	}
+
+	errs := []struct {
+		in  string
+		err error
+	}{
+		{"qty + name", ErrNotNumber},
+		{"at - 1", ErrNotNumber},
+		{"qty AND name = 'x'", ErrNotBool},
+		{"NOT price", ErrNotBool},
+		{"at = 1", dt.ErrIncomparable},
+		{"nope IS NULL", ErrNoColumn},
+	}
+	for _, c := range errs {
+		if _, err := TypeOf(MustParse(c.in), meta); err != c.err {
+			t.Fatal(c.in, " should fail with ", c.err, ",but ", err)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package yard
+
+import (
+	"github.com/lycying/pitydb/dt"
+	"github.com/lycying/pitydb/utils"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// AnalyzeOptions controls how Analyze samples the tree
+type AnalyzeOptions struct {
+	SamplePages int //data pages read, 0 reads every page
+	SampleSize  int //rows kept to build the histograms
+	Buckets     int //buckets of each equi-depth histogram
+}
+
+func DefaultAnalyzeOptions() *AnalyzeOptions {
+	return &AnalyzeOptions{
+		SamplePages: 1000,
+		SampleSize:  30000,
+		Buckets:     100,
+	}
+}
+
+// PageStats is the fill statistics of the pages of a tree
+type PageStats struct {
+	DataPages  uint32
+	IndexPages uint32
+	MinFill    uint32 //bytes used by the emptiest data page
+	MaxFill    uint32 //bytes used by the fullest data page
+	TotalFill  uint64 //bytes used by all the data pages
+}
+
+// AvgFill is the average bytes used by a data page
+func (s *PageStats) AvgFill() float64 {
+	if s.DataPages == 0 {
+		return 0
+	}
+	return float64(s.TotalFill) / float64(s.DataPages)
+}
+
+// FillFactor is the average part of a data page that is used
+func (s *PageStats) FillFactor() float64 {
+	return s.AvgFill() / float64(DefaultPageSize)
+}
+
+// ColumnStats is the data distribution of one CellMeta
+type ColumnStats struct {
+	Pos      int
+	Name     string
+	Type     dt.DType
+	NullFrac float64
+	NDV      uint64 //estimated number of distinct values
+
+	Min       dt.DtRefer   //nil if the type is not histogramable or all the values are null
+	Max       dt.DtRefer   //nil if the type is not histogramable or all the values are null
+	Histogram []dt.DtRefer //upper bounds of the equi-depth buckets, built from the sample
+
+	Sketch *utils.HyperLogLog
+}
+
+// TableStats is the result of Analyze
+type TableStats struct {
+	Rows        uint64
+	SampledRows uint32
+	AnalyzedAt  uint64 //time.Now().UnixNano()
+	Pages       *PageStats
+	Columns     []*ColumnStats
+}
+
+// histogramable reports the types that can be ordered and decoded without more meta
+func histogramable(typ dt.DType) bool {
+	switch typ {
+	case dt.ByteType, dt.Int32Type, dt.UInt32Type, dt.Int64Type, dt.UInt64Type,
+		dt.Float32Type, dt.Float64Type, dt.BoolType, dt.StringType, dt.DecimalType, dt.TimeType,
+		dt.JsonType, dt.BytesType, dt.UUIDType, dt.EnumType:
+		return true
+	}
+	return false
+}
+
+func (tree *PageTree) pageStats() *PageStats {
+	s := &PageStats{}
+	tree.walk(func(pg *Page) bool {
+		if pg.isIndexPage() {
+			s.IndexPages++
+			return true
+		}
+		fill := uint32(pg.GetLen())
+		if s.DataPages == 0 || fill < s.MinFill {
+			s.MinFill = fill
+		}
+		if fill > s.MaxFill {
+			s.MaxFill = fill
+		}
+		s.DataPages++
+		s.TotalFill += uint64(fill)
+		return true
+	})
+	return s
+}
+
+// PageStats returns the fill statistics of the pages
+func (tree *PageTree) PageStats() *PageStats {
+	tree.lock.RLock()
+	defer tree.lock.RUnlock()
+
+	return tree.pageStats()
+}
+
+// Analyze collects the statistics of the tree from SamplePages data pages
+// picked at random, every row of a picked page is read and the histograms are
+// built from SampleSize of them. If not every page is read, the row count is
+// scaled to the whole tree, the min, max and null fraction are those of the
+// picked pages, and the distinct values of a column that looks unique in them
+// are scaled like the rows while the others are a lower bound.
+func (tree *PageTree) Analyze(opts *AnalyzeOptions) *TableStats {
+	if nil == opts {
+		opts = DefaultAnalyzeOptions()
+	}
+
+	tree.lock.RLock()
+	defer tree.lock.RUnlock()
+
+	items := tree.meta.GetItems()
+	cols := make([]*ColumnStats, len(items))
+	nulls := make([]uint64, len(items))
+	for i, item := range items {
+		cols[i] = &ColumnStats{
+			Pos:    item.GetPos(),
+			Name:   item.GetName(),
+			Type:   item.GetMType(),
+			Sketch: utils.NewHyperLogLog(),
+		}
+	}
+
+	pages := make([]*Page, 0)
+	stored := 0
+	tree.walk(func(pg *Page) bool {
+		if pg.isDataPage() {
+			pages = append(pages, pg)
+			stored += len(pg.rows)
+		}
+		return true
+	})
+	picked := pages
+	if opts.SamplePages > 0 && len(pages) > opts.SamplePages {
+		picked = make([]*Page, opts.SamplePages)
+		for i, j := range rand.Perm(len(pages))[:opts.SamplePages] {
+			picked[i] = pages[j]
+		}
+	}
+
+	rows, read := uint64(0), 0
+	sample := make([]*Row, 0, opts.SampleSize)
+	now := time.Now().UnixNano()
+	for _, pg := range picked {
+		read += len(pg.rows)
+		for _, r := range pg.rows {
+			if r.expired(now) {
+				continue
+			}
+			rows++
+			for i := range cols {
+				if i >= len(r.cells) || nil == r.cells[i] {
+					nulls[i]++
+					continue
+				}
+				cell := r.cells[i]
+				b, _ := cell.Encode()
+				cols[i].Sketch.Add(b)
+				if histogramable(cols[i].Type) {
+					if nil == cols[i].Min || cell.Compare(cols[i].Min) < 0 {
+						cols[i].Min = cell
+					}
+					if nil == cols[i].Max || cell.Compare(cols[i].Max) > 0 {
+						cols[i].Max = cell
+					}
+				}
+			}
+			//reservoir sampling
+			if len(sample) < opts.SampleSize {
+				sample = append(sample, r)
+			} else if j := rand.Int63n(int64(rows)); j < int64(opts.SampleSize) {
+				sample[j] = r
+			}
+		}
+	}
+
+	//the expired rows of the other pages are taken in the same part
+	total := rows
+	if len(picked) < len(pages) && read > 0 {
+		total = uint64(float64(rows) * float64(stored) / float64(read))
+	}
+	for i, col := range cols {
+		col.NDV = col.Sketch.Estimate()
+		if rows > 0 {
+			col.NullFrac = float64(nulls[i]) / float64(rows)
+		}
+		if total > rows && float64(col.NDV) >= 0.9*float64(rows-nulls[i]) {
+			col.NDV = uint64(float64(col.NDV) * float64(total) / float64(rows))
+		}
+		if nil != col.Min {
+			col.Min = col.Min.Copy()
+			col.Max = col.Max.Copy()
+			fillHistogram(col, i, sample, opts.Buckets)
+		}
+	}
+
+	return &TableStats{
+		Rows:        total,
+		SampledRows: uint32(len(sample)),
+		AnalyzedAt:  uint64(time.Now().UnixNano()),
+		Pages:       tree.pageStats(),
+		Columns:     cols,
+	}
+}
+
+func fillHistogram(col *ColumnStats, idx int, sample []*Row, buckets int) {
+	values := make([]dt.DtRefer, 0, len(sample))
+	for _, r := range sample {
+		if idx < len(r.cells) && nil != r.cells[idx] {
+			values = append(values, r.cells[idx])
+		}
+	}
+	if len(values) == 0 {
+		return
+	}
+	sort.SliceStable(values, func(i, j int) bool {
+		return values[i].Compare(values[j]) < 0
+	})
+
+	if buckets > len(values) {
+		buckets = len(values)
+	}
+	col.Histogram = make([]dt.DtRefer, 0, buckets)
+	for b := 1; b <= buckets; b++ {
+		col.Histogram = append(col.Histogram, values[b*len(values)/buckets-1].Copy())
+	}
+}
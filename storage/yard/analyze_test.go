@@ -0,0 +1,133 @@
+package yard
+
+import (
+	"github.com/lycying/pitydb/dt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPageTree_Analyze(t *testing.T) {
+	tree := newTestTree(1000)
+	stats := tree.Analyze(nil)
+
+	if stats.Rows != 1000 || stats.SampledRows != 1000 {
+		t.Fatal("rows should be 1000, but ", stats.Rows, stats.SampledRows)
+	}
+	id := stats.Columns[0]
+	if id.NDV < 950 || id.NDV > 1050 {
+		t.Fatal("id should have about 1000 distinct values, but ", id.NDV)
+	}
+	if id.Min.GetValue().(uint32) != 1 || id.Max.GetValue().(uint32) != 1000 {
+		t.Fatal("id should be in 1..1000, but ", id.Min.GetValue(), id.Max.GetValue())
+	}
+	if len(id.Histogram) != 100 || id.Histogram[0].GetValue().(uint32) != 10 {
+		t.Fatal("every bucket should hold 10 rows")
+	}
+	if stats.Columns[1].NDV != 1 || stats.Columns[1].NullFrac != 0 {
+		t.Fatal("col2 has only the default value")
+	}
+	if stats.Pages.DataPages == 0 || stats.Pages.FillFactor() <= 0 || stats.Pages.MinFill > stats.Pages.MaxFill {
+		t.Fatalf("bad page stats %+v", stats.Pages)
+	}
+}
+
+func TestPageTree_AnalyzeSample(t *testing.T) {
+	tree := newTestTree(20000)
+	stats := tree.Analyze(&AnalyzeOptions{SamplePages: 10, SampleSize: 1000, Buckets: 10})
+
+	if stats.Pages.DataPages <= 10 {
+		t.Fatal("the tree should have more pages than the sample, but ", stats.Pages.DataPages)
+	}
+	if stats.Rows != 20000 || stats.SampledRows == 0 || stats.SampledRows >= 20000 {
+		t.Fatal("the rows should be scaled to the tree, but ", stats.Rows, stats.SampledRows)
+	}
+	if id := stats.Columns[0]; id.NDV < 18000 || id.NDV > 22000 {
+		t.Fatal("the distinct ids should be scaled to the tree, but ", id.NDV)
+	}
+	if stats.Columns[1].NDV != 1 {
+		t.Fatal("col2 has only the default value, but ", stats.Columns[1].NDV)
+	}
+}
+
+func TestCatalog_Save(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pitydb")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "catalog")
+
+	cat := NewCatalog()
+	stats := cat.Analyze("t1", newTestTree(300), &AnalyzeOptions{SampleSize: 100, Buckets: 10})
+	if err := cat.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	cat2, err := LoadCatalog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats2 := cat2.GetTableStats("t1")
+	if stats2.Rows != stats.Rows || stats2.SampledRows != 100 || *stats2.Pages != *stats.Pages {
+		t.Fatal("the stats should survive the save")
+	}
+	for i, col := range stats.Columns {
+		col2 := stats2.Columns[i]
+		if col2.Name != col.Name || col2.NDV != col.NDV || col2.Sketch.Estimate() != col.NDV {
+			t.Fatal("the column stats should survive the save")
+		}
+		if col2.Min.Compare(col.Min) != 0 || len(col2.Histogram) != len(col.Histogram) {
+			t.Fatal("the histogram should survive the save")
+		}
+	}
+
+	b, _ := ioutil.ReadFile(path)
+	b[0] ^= 0xff
+	ioutil.WriteFile(path, b, 0666)
+	if _, err := LoadCatalog(path); err != ErrCatalogChecksum {
+		t.Fatal("a broken catalog should be detected, but ", err)
+	}
+}
+
+func TestCatalog_SaveEncoding(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pitydb")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "catalog")
+
+	meta := dt.NewRowMeta()
+	meta.AddCellMeta(dt.NewCellMetaRaw(0, dt.Int64Type, "delta", "", nil).WithEncoding(dt.ZigzagEncoding))
+	meta.AddCellMeta(dt.NewCellMetaRaw(1, dt.UInt32Type, "qty", "", nil).WithEncoding(dt.VarintEncoding))
+	tree := NewPageTree(meta, nil)
+	for i := 1; i <= 100; i++ {
+		r := NewRow(meta)
+		r.WithDefaultValues()
+		r.SetKey(uint32(i))
+		r.SetCellValue(meta.GetItems()[0], int64(i-50))
+		r.SetCellValue(meta.GetItems()[1], uint32(i*1000))
+		tree.Insert(r)
+	}
+
+	cat := NewCatalog()
+	stats := cat.Analyze("t1", tree, &AnalyzeOptions{SampleSize: 100, Buckets: 10})
+	if err := cat.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	cat2, err := LoadCatalog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats2 := cat2.GetTableStats("t1")
+	for i, col := range stats.Columns {
+		col2 := stats2.Columns[i]
+		if col2.Min.Compare(col.Min) != 0 || col2.Max.Compare(col.Max) != 0 || len(col2.Histogram) != 10 {
+			t.Fatal("the stats of an encoded column should survive the save ", col.Name)
+		}
+		for j, bound := range col.Histogram {
+			if col2.Histogram[j].Compare(bound) != 0 {
+				t.Fatal("the histogram of an encoded column should survive the save ", col.Name)
+			}
+		}
+	}
+	if stats2.Columns[0].Min.GetValue().(int64) != -49 {
+		t.Fatal("the min delta should be -49, but ", stats2.Columns[0].Min.GetValue())
+	}
+}
@@ -0,0 +1,290 @@
+package yard
+
+import (
+	"bytes"
+	"errors"
+	"github.com/lycying/pitydb/dt"
+	"github.com/lycying/pitydb/utils"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+)
+
+const backupMagic uint32 = 0x50424b50 //PBKP
+
+var ErrBadBackup = errors.New("bad backup")
+var ErrBackupChain = errors.New("the backups do not follow each other")
+var ErrRestorePoint = errors.New("the restore point is before the last backup")
+
+// BackupManifest describes one backup, a backup holds the pages
+// changed after BaseLSN and the log written while it is taken
+type BackupManifest struct {
+	BaseLSN    uint64 //0 for a full backup
+	LSN        uint64 //the pages are a consistent copy of the tree at this lsn
+	EndLSN     uint64 //the log tail saved with the backup ends at this lsn
+	Time       uint64 //time.Now().UnixNano() when the pages are copied
+	RootID     uint32
+	NextPageID uint32
+	Pages      uint32
+	Records    uint32
+}
+
+func (m *BackupManifest) IsFull() bool {
+	return m.BaseLSN == 0
+}
+
+func (m *BackupManifest) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	writeDt(buf, dt.ValidNewUInt32(backupMagic))
+	writeDt(buf, dt.ValidNewUInt64(m.BaseLSN))
+	writeDt(buf, dt.ValidNewUInt64(m.LSN))
+	writeDt(buf, dt.ValidNewUInt64(m.EndLSN))
+	writeDt(buf, dt.ValidNewUInt64(m.Time))
+	writeDt(buf, dt.ValidNewUInt32(m.RootID))
+	writeDt(buf, dt.ValidNewUInt32(m.NextPageID))
+	writeDt(buf, dt.ValidNewUInt32(m.Pages))
+	writeDt(buf, dt.ValidNewUInt32(m.Records))
+	return buf.Bytes(), nil
+}
+
+func (m *BackupManifest) Decode(buf []byte, offset int) (int, error) {
+	if len(buf)-offset < 4+8*4+4*4 {
+		return 0, ErrBadBackup
+	}
+	magic := dt.NewUInt32()
+	baseLSN, lsn, endLSN, ts := dt.NewUInt64(), dt.NewUInt64(), dt.NewUInt64(), dt.NewUInt64()
+	rootID, nextPageID, pages, records := dt.NewUInt32(), dt.NewUInt32(), dt.NewUInt32(), dt.NewUInt32()
+
+	idx := offset
+	idx = readDt(buf, idx, magic)
+	if magic.GetValue().(uint32) != backupMagic {
+		return 0, ErrBadBackup
+	}
+	idx = readDt(buf, idx, baseLSN)
+	idx = readDt(buf, idx, lsn)
+	idx = readDt(buf, idx, endLSN)
+	idx = readDt(buf, idx, ts)
+	idx = readDt(buf, idx, rootID)
+	idx = readDt(buf, idx, nextPageID)
+	idx = readDt(buf, idx, pages)
+	idx = readDt(buf, idx, records)
+
+	m.BaseLSN = baseLSN.GetValue().(uint64)
+	m.LSN = lsn.GetValue().(uint64)
+	m.EndLSN = endLSN.GetValue().(uint64)
+	m.Time = ts.GetValue().(uint64)
+	m.RootID = rootID.GetValue().(uint32)
+	m.NextPageID = nextPageID.GetValue().(uint32)
+	m.Pages = pages.GetValue().(uint32)
+	m.Records = records.GetValue().(uint32)
+	return idx - offset, nil
+}
+
+// snapshot copies the header and the row list of the page, the rows
+// are shared because a write replaces a row instead of changing it
+func (p *Page) snapshot() *Page {
+	cp := p.tree.allocPage(p.pgID.GetValue().(uint32), p.level.GetValue().(uint32), p.pgType.GetValue().(byte))
+	cp.pageHeader.left.SetValue(p.pageHeader.left.GetValue())
+	cp.pageHeader.right.SetValue(p.pageHeader.right.GetValue())
+	cp.checksum.SetValue(p.checksum.GetValue())
+	cp.lastModify.SetValue(p.lastModify.GetValue())
+	cp.lsn.SetValue(p.lsn.GetValue())
+	cp.rows = append([]*Row(nil), p.rows...)
+	cp.size.SetValue(uint32(len(cp.rows)))
+	return cp
+}
+
+// Backup writes a copy of the tree to w. Only the pages changed after baseLSN
+// are saved, so baseLSN 0 takes a full backup and the LSN of the last backup
+// takes an incremental one. The writes are only held while the page list
+// is copied, the log written until the backup is done is saved as its tail.
+// A Flush waits for the backup, it would cut the log the backup reads.
+// The large objects of the rows are not copied, see AttachLobStore.
+func (tree *PageTree) Backup(w io.Writer, baseLSN uint64) (*BackupManifest, error) {
+	m := &BackupManifest{BaseLSN: baseLSN}
+
+	tree.flushLock.Lock()
+	defer tree.flushLock.Unlock()
+
+	tree.lock.RLock()
+	m.LSN = tree.lsn
+	m.Time = uint64(time.Now().UnixNano())
+	m.RootID = tree.root.pgID.GetValue().(uint32)
+	m.NextPageID = tree.mgr.nextPageID
+	pages := make([]*Page, 0)
+	tree.walk(func(pg *Page) bool {
+		if baseLSN == 0 || pg.lsn.GetValue().(uint64) > baseLSN {
+			pages = append(pages, pg.snapshot())
+		}
+		return true
+	})
+	wal := tree.wal
+	tree.lock.RUnlock()
+
+	body := new(bytes.Buffer)
+	for _, pg := range pages {
+		writePage(body, pg)
+	}
+	m.Pages = uint32(len(pages))
+
+	m.EndLSN = m.LSN
+	if nil != wal {
+		records, err := wal.readAfter(m.LSN)
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range records {
+			b, _ := rec.Encode()
+			body.Write(b)
+			m.EndLSN = rec.lsn
+		}
+		m.Records = uint32(len(records))
+	}
+
+	bm, _ := m.Encode()
+	buf := new(bytes.Buffer)
+	buf.Write(bm)
+	buf.Write(body.Bytes())
+	writeDt(buf, dt.ValidNewUInt32(utils.Sum32(buf.Bytes())))
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+	tree.lock.Lock()
+	if m.LSN > tree.backupLSN {
+		tree.backupLSN = m.LSN
+	}
+	tree.lock.Unlock()
+	return m, nil
+}
+
+// RestoreOptions chooses the point in time that Restore goes back to
+type RestoreOptions struct {
+	StopLSN  uint64    //replay the log up to this lsn, 0 means all of it
+	StopTime time.Time //replay the log written before this time, zero means all of it
+	Wal      string    //the log to replay after the log tails of the backups, optional
+	Path     string    //the page file of the restored tree, optional
+}
+
+func (opts *RestoreOptions) stopAt(rec *walRecord) bool {
+	if opts.StopLSN > 0 && rec.lsn > opts.StopLSN {
+		return true
+	}
+	if !opts.StopTime.IsZero() && rec.ts > uint64(opts.StopTime.UnixNano()) {
+		return true
+	}
+	return false
+}
+
+// Restore rebuilds a tree from a full backup followed by its incremental backups,
+// and then replays the log up to the point chosen by opts
+func Restore(meta *dt.RowMeta, backups []io.Reader, opts *RestoreOptions) (*PageTree, error) {
+	if nil == opts {
+		opts = &RestoreOptions{}
+	}
+	tree := &PageTree{
+		meta: meta,
+		mgr:  NewPageMgr(),
+	}
+
+	var last *BackupManifest
+	records := make(map[uint64]*walRecord)
+	for _, r := range backups {
+		buf, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(buf) < 4 {
+			return nil, ErrBadBackup
+		}
+		sum := dt.NewUInt32()
+		readDt(buf, len(buf)-4, sum)
+		buf = buf[:len(buf)-4]
+		if utils.Sum32(buf) != sum.GetValue().(uint32) {
+			return nil, ErrBadBackup
+		}
+
+		m := &BackupManifest{}
+		idx, err := m.Decode(buf, 0)
+		if err != nil {
+			return nil, err
+		}
+		if (nil == last) != m.IsFull() || (nil != last && m.BaseLSN > last.LSN) {
+			return nil, ErrBackupChain
+		}
+		idx += tree.readPages(buf, idx, m.Pages, tree.mgr)
+		tail, _, err := decodeWalRecords(buf[idx:])
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range tail {
+			records[rec.lsn] = rec
+		}
+		last = m
+	}
+	if nil == last {
+		return nil, ErrBackupChain
+	}
+	if (opts.StopLSN > 0 && opts.StopLSN < last.LSN) ||
+		(!opts.StopTime.IsZero() && uint64(opts.StopTime.UnixNano()) < last.Time) {
+		return nil, ErrRestorePoint
+	}
+
+	root, ok := tree.mgr.pageMap[last.RootID]
+	if !ok {
+		return nil, ErrBadBackup
+	}
+	//only the pages that can be reached from the last root are alive
+	mgr := NewPageMgr()
+	_walk(tree.mgr, root, func(pg *Page) bool {
+		mgr.AddPage(pg)
+		return true
+	})
+	mgr.nextPageID = last.NextPageID
+	linkParents(mgr, root)
+	tree.mgr = mgr
+	tree.root = root
+	tree.lsn = last.LSN
+
+	if opts.Wal != "" {
+		tail, err := readWalAfter(opts.Wal, last.LSN)
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range tail {
+			records[rec.lsn] = rec
+		}
+	}
+	lsns := make([]uint64, 0, len(records))
+	for lsn := range records {
+		if lsn > last.LSN {
+			lsns = append(lsns, lsn)
+		}
+	}
+	sort.Slice(lsns, func(i, j int) bool {
+		return lsns[i] < lsns[j]
+	})
+	for i, lsn := range lsns {
+		if opts.stopAt(records[lsn]) {
+			break
+		}
+		//a hole would silently skip the writes in it
+		if lsn != last.LSN+uint64(i)+1 {
+			return nil, ErrWalGap
+		}
+		tree.replay(records[lsn])
+	}
+
+	if opts.Path != "" {
+		if err := writeFileAtomic(opts.Path, encodePages(tree.mgr, tree.root)); err != nil {
+			return nil, err
+		}
+		link, err := os.OpenFile(opts.Path, os.O_RDWR, 0666)
+		if err != nil {
+			return nil, err
+		}
+		tree.link = link
+	}
+	return tree, nil
+}
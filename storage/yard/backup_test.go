@@ -0,0 +1,101 @@
+package yard
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func insertTestRows(tree *PageTree, from int, to int) {
+	for i := from; i <= to; i++ {
+		r := NewRow(tree.meta)
+		r.WithDefaultValues()
+		r.SetKey(uint32(i))
+		r.SetCellValueForTest(tree.meta.GetItems()[0], uint32(i))
+		tree.Insert(r)
+	}
+}
+
+func TestPageTree_Backup(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pitydb")
+	defer os.RemoveAll(dir)
+	walPath := filepath.Join(dir, "t1.wal")
+
+	w, _ := OpenWal(walPath)
+	defer w.Close()
+	tree := newTestTree(0)
+	tree.AttachWal(w)
+
+	insertTestRows(tree, 1, 500)
+	full := new(bytes.Buffer)
+	m1, err := tree.Backup(full, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	insertTestRows(tree, 501, 520)
+	for i := 1; i <= 50; i++ {
+		tree.Delete(uint32(i))
+	}
+	incr := new(bytes.Buffer)
+	m2, err := tree.Backup(incr, m1.LSN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m2.IsFull() || m2.Pages == 0 || m2.Pages >= uint32(len(tree.Export().Pages)) {
+		t.Fatal("the incremental backup should only hold the changed pages, but ", m2.Pages)
+	}
+
+	time.Sleep(time.Millisecond)
+	stopTime := time.Now()
+	time.Sleep(time.Millisecond)
+	insertTestRows(tree, 521, 530)
+	stopLSN := tree.LSN()
+	insertTestRows(tree, 531, 540)
+
+	restore := func(opts *RestoreOptions) *PageTree {
+		restored, err := Restore(tree.meta, []io.Reader{bytes.NewReader(full.Bytes()), bytes.NewReader(incr.Bytes())}, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return restored
+	}
+	check := func(restored *PageTree, last int) {
+		for i := 1; i <= 540; i++ {
+			_, found := restored.Get(uint32(i))
+			if found != (i > 50 && i <= last) {
+				t.Fatal("bad row after restore ", i, last)
+			}
+		}
+	}
+
+	check(restore(&RestoreOptions{StopTime: stopTime, Wal: walPath}), 520)
+	check(restore(&RestoreOptions{StopLSN: stopLSN, Wal: walPath}), 530)
+	check(restore(&RestoreOptions{Wal: walPath}), 540)
+	check(restore(nil), 520)
+
+	path := filepath.Join(dir, "t1.pity")
+	restore(&RestoreOptions{Wal: walPath, Path: path})
+	loaded, err := OpenPageTree(tree.meta, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(loaded, 540)
+
+	if _, err := Restore(tree.meta, []io.Reader{bytes.NewReader(incr.Bytes())}, nil); err != ErrBackupChain {
+		t.Fatal("an incremental backup can not be restored alone, but ", err)
+	}
+	if _, err := Restore(tree.meta, []io.Reader{bytes.NewReader(full.Bytes()), bytes.NewReader(incr.Bytes())},
+		&RestoreOptions{StopLSN: m1.LSN}); err != ErrRestorePoint {
+		t.Fatal("the restore point can not be before the last backup, but ", err)
+	}
+	broken := append([]byte(nil), full.Bytes()...)
+	broken[len(broken)/2] ^= 0xff
+	if _, err := Restore(tree.meta, []io.Reader{bytes.NewReader(broken)}, nil); err != ErrBadBackup {
+		t.Fatal("a broken backup should be detected, but ", err)
+	}
+}
@@ -0,0 +1,387 @@
+package yard
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/lycying/pitydb/dt"
+	"github.com/lycying/pitydb/utils"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+)
+
+var ErrCatalogChecksum = errors.New("catalog checksum mismatch")
+
+// Catalog keeps the information about the tables that must survive a restart
+type Catalog struct {
+	lock      sync.RWMutex
+	stats     map[string]*TableStats
+	sequences map[string]*Sequence
+	schemas   map[string]*dt.RowMeta
+
+	path     string     //the changes of the sequences are saved here at once, see OpenCatalog
+	syncLock sync.Mutex //one writer of path at a time
+}
+
+func NewCatalog() *Catalog {
+	return &Catalog{
+		stats:     make(map[string]*TableStats),
+		sequences: make(map[string]*Sequence),
+		schemas:   make(map[string]*dt.RowMeta),
+	}
+}
+
+// OpenCatalog loads the catalog of path or creates an empty one, the
+// catalog is saved to path every time a sequence reserves values
+func OpenCatalog(path string) (*Catalog, error) {
+	cat, err := LoadCatalog(path)
+	if os.IsNotExist(err) {
+		cat = NewCatalog()
+		cat.path = path
+		return cat, cat.sync()
+	}
+	return cat, err
+}
+
+// sync saves the catalog if it is opened from a file
+func (cat *Catalog) sync() error {
+	if cat.path == "" {
+		return nil
+	}
+	cat.syncLock.Lock()
+	defer cat.syncLock.Unlock()
+	return cat.Save(cat.path)
+}
+
+// SetSchema saves the RowMeta of table with its constraints, a CHECK that
+// does not parse or is not a bool is refused
+func (cat *Catalog) SetSchema(table string, meta *dt.RowMeta) error {
+	if err := meta.ValidateChecks(); err != nil {
+		return err
+	}
+	meta.WithSequences(cat)
+	cat.lock.Lock()
+	old, ok := cat.schemas[table]
+	cat.schemas[table] = meta
+	cat.lock.Unlock()
+
+	if err := cat.sync(); err != nil {
+		cat.lock.Lock()
+		if ok {
+			cat.schemas[table] = old
+		} else {
+			delete(cat.schemas, table)
+		}
+		cat.lock.Unlock()
+		return err
+	}
+	return nil
+}
+
+// GetSchema returns nil if the schema of table is never saved
+func (cat *Catalog) GetSchema(table string) *dt.RowMeta {
+	cat.lock.RLock()
+	defer cat.lock.RUnlock()
+	return cat.schemas[table]
+}
+
+// bindSequences makes the auto-increment cells of a decoded meta use the
+// sequences of the catalog again, the sequences are decoded before the schemas
+func (cat *Catalog) bindSequences(meta *dt.RowMeta) {
+	meta.WithSequences(cat)
+	for _, item := range meta.GetItems() {
+		if nil == item {
+			continue
+		}
+		if seq, ok := cat.sequences[item.GetAutoIncrementName()]; ok {
+			item.WithAutoIncrement(seq)
+		}
+		if nil != item.GetStructMeta() {
+			cat.bindSequences(item.GetStructMeta())
+		}
+	}
+}
+
+func (cat *Catalog) SetTableStats(table string, stats *TableStats) {
+	cat.lock.Lock()
+	defer cat.lock.Unlock()
+	cat.stats[table] = stats
+}
+
+// GetTableStats returns nil if the table is never analyzed
+func (cat *Catalog) GetTableStats(table string) *TableStats {
+	cat.lock.RLock()
+	defer cat.lock.RUnlock()
+	return cat.stats[table]
+}
+
+// Analyze analyzes the tree and saves the result as the stats of table
+func (cat *Catalog) Analyze(table string, tree *PageTree, opts *AnalyzeOptions) *TableStats {
+	stats := tree.Analyze(opts)
+	cat.SetTableStats(table, stats)
+	return stats
+}
+
+func (cat *Catalog) Encode() ([]byte, error) {
+	cat.lock.RLock()
+	defer cat.lock.RUnlock()
+
+	names := make([]string, 0, len(cat.stats))
+	for name := range cat.stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := new(bytes.Buffer)
+	writeDt(buf, dt.ValidNewUInt32(uint32(len(names))))
+	for _, name := range names {
+		writeDt(buf, dt.ValidNewString(name))
+		b, err := cat.stats[name].Encode()
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+	}
+
+	seqNames := make([]string, 0, len(cat.sequences))
+	for name := range cat.sequences {
+		seqNames = append(seqNames, name)
+	}
+	sort.Strings(seqNames)
+	writeDt(buf, dt.ValidNewUInt32(uint32(len(seqNames))))
+	for _, name := range seqNames {
+		cat.sequences[name].encode(buf)
+	}
+
+	tables := make([]string, 0, len(cat.schemas))
+	for name := range cat.schemas {
+		tables = append(tables, name)
+	}
+	sort.Strings(tables)
+	writeDt(buf, dt.ValidNewUInt32(uint32(len(tables))))
+	for _, name := range tables {
+		writeDt(buf, dt.ValidNewString(name))
+		b, err := cat.schemas[name].Encode()
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+	}
+	return buf.Bytes(), nil
+}
+
+func (cat *Catalog) Decode(buf []byte, offset int) (int, error) {
+	cat.lock.Lock()
+	defer cat.lock.Unlock()
+
+	idx := offset
+	count := dt.NewUInt32()
+	idx = readDt(buf, idx, count)
+	stats := make(map[string]*TableStats)
+	for i := uint32(0); i < count.GetValue().(uint32); i++ {
+		name := dt.NewString()
+		idx = readDt(buf, idx, name)
+		s := &TableStats{}
+		sLen, err := s.Decode(buf, idx)
+		if err != nil {
+			return idx - offset, err
+		}
+		idx += sLen
+		stats[name.GetValue().(string)] = s
+	}
+	cat.stats = stats
+
+	//the catalogs written before the sequences end here
+	sequences := make(map[string]*Sequence)
+	if idx < len(buf) {
+		idx = readDt(buf, idx, count)
+		for i := uint32(0); i < count.GetValue().(uint32); i++ {
+			seq := &Sequence{cat: cat}
+			idx += seq.decode(buf, idx)
+			sequences[seq.name] = seq
+		}
+	}
+	cat.sequences = sequences
+
+	//the catalogs written before the schemas end here
+	schemas := make(map[string]*dt.RowMeta)
+	if idx < len(buf) {
+		idx = readDt(buf, idx, count)
+		for i := uint32(0); i < count.GetValue().(uint32); i++ {
+			name := dt.NewString()
+			idx = readDt(buf, idx, name)
+			meta := dt.NewRowMeta()
+			l, err := meta.Decode(buf, idx)
+			if err != nil {
+				return idx - offset, err
+			}
+			idx += l
+			cat.bindSequences(meta)
+			schemas[name.GetValue().(string)] = meta
+		}
+	}
+	cat.schemas = schemas
+	return idx - offset, nil
+}
+
+// Save writes the catalog to path, a crash leaves either the old or the new catalog
+func (cat *Catalog) Save(path string) error {
+	b, err := cat.Encode()
+	if err != nil {
+		return err
+	}
+	sum := make([]byte, 4)
+	binary.BigEndian.PutUint32(sum, utils.Sum32(b))
+
+	return writeFileAtomic(path, append(b, sum...))
+}
+
+// LoadCatalog reads the catalog written by Save, the sequences of it are saved to path
+func LoadCatalog(path string) (*Catalog, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 4 {
+		return nil, ErrCatalogChecksum
+	}
+	data, sum := b[:len(b)-4], b[len(b)-4:]
+	if utils.Sum32(data) != binary.BigEndian.Uint32(sum) {
+		return nil, ErrCatalogChecksum
+	}
+	cat := NewCatalog()
+	if _, err := cat.Decode(data, 0); err != nil {
+		return nil, err
+	}
+	cat.path = path
+	return cat, nil
+}
+
+func (s *TableStats) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	writeDt(buf, dt.ValidNewUInt64(s.Rows))
+	writeDt(buf, dt.ValidNewUInt32(s.SampledRows))
+	writeDt(buf, dt.ValidNewUInt64(s.AnalyzedAt))
+	writeDt(buf, dt.ValidNewUInt32(s.Pages.DataPages))
+	writeDt(buf, dt.ValidNewUInt32(s.Pages.IndexPages))
+	writeDt(buf, dt.ValidNewUInt32(s.Pages.MinFill))
+	writeDt(buf, dt.ValidNewUInt32(s.Pages.MaxFill))
+	writeDt(buf, dt.ValidNewUInt64(s.Pages.TotalFill))
+
+	writeDt(buf, dt.ValidNewUInt32(uint32(len(s.Columns))))
+	for _, col := range s.Columns {
+		writeDt(buf, dt.ValidNewInt32(int32(col.Pos)))
+		writeDt(buf, dt.ValidNewString(col.Name))
+		writeDt(buf, dt.ValidNewInt32(int32(col.Type)))
+		writeDt(buf, dt.ValidNewFloat64(col.NullFrac))
+		writeDt(buf, dt.ValidNewUInt64(col.NDV))
+
+		writeDt(buf, dt.ValidNewBool(nil != col.Min))
+		if nil != col.Min {
+			writeStatsValue(buf, col.Type, col.Min)
+			writeStatsValue(buf, col.Type, col.Max)
+		}
+		writeDt(buf, dt.ValidNewUInt32(uint32(len(col.Histogram))))
+		for _, bound := range col.Histogram {
+			writeStatsValue(buf, col.Type, bound)
+		}
+		writeDt(buf, dt.ValidNewString(string(col.Sketch.Bytes())))
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *TableStats) Decode(buf []byte, offset int) (int, error) {
+	rows, sampled, analyzedAt := dt.NewUInt64(), dt.NewUInt32(), dt.NewUInt64()
+	dataPages, indexPages, minFill, maxFill, totalFill := dt.NewUInt32(), dt.NewUInt32(), dt.NewUInt32(), dt.NewUInt32(), dt.NewUInt64()
+
+	idx := offset
+	idx = readDt(buf, idx, rows)
+	idx = readDt(buf, idx, sampled)
+	idx = readDt(buf, idx, analyzedAt)
+	idx = readDt(buf, idx, dataPages)
+	idx = readDt(buf, idx, indexPages)
+	idx = readDt(buf, idx, minFill)
+	idx = readDt(buf, idx, maxFill)
+	idx = readDt(buf, idx, totalFill)
+
+	s.Rows = rows.GetValue().(uint64)
+	s.SampledRows = sampled.GetValue().(uint32)
+	s.AnalyzedAt = analyzedAt.GetValue().(uint64)
+	s.Pages = &PageStats{
+		DataPages:  dataPages.GetValue().(uint32),
+		IndexPages: indexPages.GetValue().(uint32),
+		MinFill:    minFill.GetValue().(uint32),
+		MaxFill:    maxFill.GetValue().(uint32),
+		TotalFill:  totalFill.GetValue().(uint64),
+	}
+
+	count := dt.NewUInt32()
+	idx = readDt(buf, idx, count)
+	s.Columns = make([]*ColumnStats, count.GetValue().(uint32))
+	for i := range s.Columns {
+		pos, name, typ := dt.NewInt32(), dt.NewString(), dt.NewInt32()
+		nullFrac, ndv, hasRange := dt.NewFloat64(), dt.NewUInt64(), dt.NewBool()
+		idx = readDt(buf, idx, pos)
+		idx = readDt(buf, idx, name)
+		idx = readDt(buf, idx, typ)
+		idx = readDt(buf, idx, nullFrac)
+		idx = readDt(buf, idx, ndv)
+
+		col := &ColumnStats{
+			Pos:      int(pos.GetValue().(int32)),
+			Name:     name.GetValue().(string),
+			Type:     dt.DType(typ.GetValue().(int32)),
+			NullFrac: nullFrac.GetValue().(float64),
+			NDV:      ndv.GetValue().(uint64),
+		}
+
+		idx = readDt(buf, idx, hasRange)
+		if hasRange.GetValue().(bool) {
+			col.Min = dt.NewDtRefer(col.Type)
+			col.Max = dt.NewDtRefer(col.Type)
+			idx = readDt(buf, idx, col.Min)
+			idx = readDt(buf, idx, col.Max)
+		}
+		bounds := dt.NewUInt32()
+		idx = readDt(buf, idx, bounds)
+		col.Histogram = make([]dt.DtRefer, bounds.GetValue().(uint32))
+		for j := range col.Histogram {
+			col.Histogram[j] = dt.NewDtRefer(col.Type)
+			idx = readDt(buf, idx, col.Histogram[j])
+		}
+
+		sketch := dt.NewString()
+		idx = readDt(buf, idx, sketch)
+		hll, err := utils.NewHyperLogLogFrom([]byte(sketch.GetValue().(string)))
+		if err != nil {
+			return idx - offset, err
+		}
+		col.Sketch = hll
+		s.Columns[i] = col
+	}
+	return idx - offset, nil
+}
+
+func writeDt(buf *bytes.Buffer, d dt.DtRefer) {
+	b, _ := d.Encode()
+	buf.Write(b)
+}
+
+// writeStatsValue saves v with the fixed encoding of typ, the stats are
+// decoded by dt.NewDtRefer without the CellMeta of the column
+func writeStatsValue(buf *bytes.Buffer, typ dt.DType, v dt.DtRefer) {
+	switch typ {
+	case dt.Int32Type, dt.UInt32Type, dt.Int64Type, dt.UInt64Type:
+		fixed := dt.NewDtRefer(typ)
+		fixed.SetValue(v.GetValue())
+		v = fixed
+	}
+	writeDt(buf, v)
+}
+
+func readDt(buf []byte, offset int, d dt.DtRefer) int {
+	l, _ := d.Decode(buf, offset)
+	return offset + l
+}
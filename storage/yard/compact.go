@@ -0,0 +1,212 @@
+package yard
+
+import (
+	"github.com/lycying/pitydb/dt"
+	"time"
+)
+
+// CompactOptions controls the background compaction
+type CompactOptions struct {
+	FillFactor     float64               //part of each page filled by the rewrite
+	PagesPerSecond int                   //0 means no throttle
+	Progress       func(done, total int) //called after each data page is copied
+}
+
+func DefaultCompactOptions() *CompactOptions {
+	return &CompactOptions{
+		FillFactor:     0.9,
+		PagesPerSecond: 0,
+	}
+}
+
+// Compactor rewrites the live rows of a tree into dense pages.
+// The rows of the data pages are taken at once under the read lock, so the
+// readers are never blocked, and the new pages are built from them without a
+// lock. The tree keeps the writes made in the meantime, they are applied to
+// the new pages when they replace the old ones at the end, then the tree is
+// flushed.
+type Compactor struct {
+	tree *PageTree
+	opts *CompactOptions
+
+	done  *dt.UInt32
+	total *dt.UInt32
+
+	finished chan struct{}
+	err      error
+}
+
+func NewCompactor(tree *PageTree, opts *CompactOptions) *Compactor {
+	if nil == opts {
+		opts = DefaultCompactOptions()
+	}
+	return &Compactor{
+		tree:  tree,
+		opts:  opts,
+		done:  dt.NewUInt32(),
+		total: dt.NewUInt32(),
+	}
+}
+
+// Start runs the compaction in the background
+func (c *Compactor) Start() {
+	c.finished = make(chan struct{})
+	go func() {
+		c.err = c.Run()
+		close(c.finished)
+	}()
+}
+
+// Wait waits for the compaction started by Start
+func (c *Compactor) Wait() error {
+	<-c.finished
+	return c.err
+}
+
+// Progress returns the data pages copied and the data pages to copy
+func (c *Compactor) Progress() (int, int) {
+	return int(c.done.GetValue().(uint32)), int(c.total.GetValue().(uint32))
+}
+
+// change is a write of the tree while a Compactor runs, a nil row is a delete
+type change struct {
+	key uint32
+	row *Row
+}
+
+func (c *Compactor) Run() error {
+	tree := c.tree
+	tree.flushLock.Lock()
+	defer tree.flushLock.Unlock()
+
+	//the writes wait for the read lock, so they are all kept after the rows are taken
+	tree.lock.RLock()
+	pages := make([][]*Row, 0)
+	tree.walk(func(pg *Page) bool {
+		if pg.isDataPage() {
+			pages = append(pages, append([]*Row(nil), pg.rows...))
+		}
+		return true
+	})
+	tree.changes = make([]change, 0)
+	tree.lock.RUnlock()
+
+	c.done.SetValue(uint32(0))
+	c.total.SetValue(uint32(len(pages)))
+
+	mgr := NewPageMgr()
+	loader := newBulkLoader(tree, mgr, int(c.opts.FillFactor*DefaultPageSize))
+	for _, rows := range pages {
+		for _, r := range rows {
+			loader.add(r)
+		}
+		done := c.done.IncrementAndGet()
+		if nil != c.opts.Progress {
+			c.opts.Progress(int(done), len(pages))
+		}
+		if c.opts.PagesPerSecond > 0 {
+			time.Sleep(time.Second / time.Duration(c.opts.PagesPerSecond))
+		}
+	}
+	root := loader.finish()
+
+	tree.lock.Lock()
+	changes := tree.changes
+	tree.changes = nil
+	//all the pages are new, so the next incremental backup takes all of them
+	if err := tree.log(&walRecord{op: walCompact}); err != nil {
+		tree.lock.Unlock()
+		return err
+	}
+	for _, pg := range mgr.pageMap {
+		pg.lsn.SetValue(tree.lsn)
+	}
+	tree.root = root
+	tree.mgr = mgr
+	for _, ch := range changes {
+		node, idx, find := tree.root.findOne(ch.key)
+		if nil != ch.row {
+			node.insert(ch.row, idx, find)
+		} else if find {
+			node.delete(ch.key, idx)
+		}
+	}
+	tree.lock.Unlock()
+
+	return tree.flush()
+}
+
+// bulkLoader builds a tree from rows that are added in key order
+type bulkLoader struct {
+	tree   *PageTree
+	mgr    *PageManagement
+	limit  int
+	leaves []*Page
+}
+
+func newBulkLoader(tree *PageTree, mgr *PageManagement, limit int) *bulkLoader {
+	return &bulkLoader{
+		tree:   tree,
+		mgr:    mgr,
+		limit:  limit,
+		leaves: make([]*Page, 0),
+	}
+}
+
+func (b *bulkLoader) newPage(lvl uint32, typ byte) *Page {
+	pg := b.tree.allocPage(b.mgr.NextPageID(), lvl, typ)
+	b.mgr.AddPage(pg)
+	return pg
+}
+
+func (b *bulkLoader) add(r *Row) {
+	var pg *Page
+	if len(b.leaves) > 0 {
+		pg = b.leaves[len(b.leaves)-1]
+	}
+	if nil == pg || (len(pg.rows) > 0 && pg._len+r.GetLen() > b.limit) {
+		pg = b.newPage(0, dataPageType)
+		b.leaves = append(b.leaves, pg)
+	}
+	pg.append(r)
+}
+
+// finish builds the index pages above the leaves and returns the root
+func (b *bulkLoader) finish() *Page {
+	if len(b.leaves) == 0 {
+		return b.newPage(0, dataPageType)
+	}
+
+	level := b.leaves
+	for lvl := uint32(1); ; lvl++ {
+		linkSiblings(level)
+		if len(level) == 1 {
+			return level[0]
+		}
+		parents := make([]*Page, 0)
+		var parent *Page
+		for _, child := range level {
+			indexRow := child.NewIndexRow()
+			if nil == parent || parent._len+indexRow.GetLen() > b.limit {
+				parent = b.newPage(lvl, indexPageType)
+				parents = append(parents, parent)
+			}
+			parent.append(indexRow)
+			child.parent = parent
+		}
+		level = parents
+	}
+}
+
+func linkSiblings(pages []*Page) {
+	for i, pg := range pages {
+		if i > 0 {
+			pg.left = pages[i-1]
+			pg.pageHeader.left.SetValue(pages[i-1].pgID.GetValue())
+		}
+		if i < len(pages)-1 {
+			pg.right = pages[i+1]
+			pg.pageHeader.right.SetValue(pages[i+1].pgID.GetValue())
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package yard
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompactor_Run(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pitydb")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "t1.pity")
+
+	tree := newTestTree(2000)
+	tree.link, _ = os.Create(path)
+	for i := 1; i <= 2000; i++ {
+		if i%10 != 0 {
+			tree.Delete(uint32(i))
+		}
+	}
+	before := tree.PageStats()
+
+	calls := 0
+	c := NewCompactor(tree, &CompactOptions{
+		FillFactor:     0.9,
+		PagesPerSecond: 10000,
+		Progress: func(done, total int) {
+			calls++
+		},
+	})
+	c.Start()
+	if err := c.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	done, total := c.Progress()
+	if done != total || calls != total || total != int(before.DataPages) {
+		t.Fatal("every data page should be reported, but ", done, total, calls)
+	}
+	after := tree.PageStats()
+	if after.DataPages >= before.DataPages || after.FillFactor() <= before.FillFactor() {
+		t.Fatalf("the pages should be denser, before %+v after %+v", before, after)
+	}
+	for i := 1; i <= 2000; i++ {
+		_, found := tree.Get(uint32(i))
+		if found != (i%10 == 0) {
+			t.Fatal("bad row after compaction ", i)
+		}
+	}
+
+	loaded, err := OpenPageTree(tree.meta, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 10; i <= 2000; i += 10 {
+		row, found := loaded.Get(uint32(i))
+		if !found || row.cells[0].GetValue().(uint32) != uint32(i) {
+			t.Fatal("the page file should hold the row ", i)
+		}
+	}
+	if *loaded.PageStats() != *after {
+		t.Fatal("the page file should hold the same pages")
+	}
+}
+
+func TestCompactor_Writes(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pitydb")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "t1.pity")
+
+	tree := newTestTree(500)
+	tree.link, _ = os.Create(path)
+	w, _ := OpenWal(filepath.Join(dir, "t1.wal"))
+	defer w.Close()
+	tree.AttachWal(w)
+	oldRoot := tree.root
+	c := NewCompactor(tree, &CompactOptions{
+		FillFactor: 0.9,
+		Progress: func(done, total int) {
+			//a steady load of writes while the pages are built
+			tree.Delete(uint32(done))
+			insertTestRows(tree, 1000+done, 1000+done)
+		},
+	})
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if tree.root == oldRoot {
+		t.Fatal("the pages should be replaced")
+	}
+	_, total := c.Progress()
+	loaded, err := OpenPageTree(tree.meta, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tr := range []*PageTree{tree, loaded} {
+		for i := 1; i <= 500; i++ {
+			if _, found := tr.Get(uint32(i)); found != (i > total) {
+				t.Fatal("the delete while compacting should be kept ", i)
+			}
+		}
+		for i := 1001; i <= 1000+total; i++ {
+			if _, found := tr.Get(uint32(i)); !found {
+				t.Fatal("the insert while compacting should be kept ", i)
+			}
+		}
+	}
+	if records, _ := w.readAfter(0); len(records) != 0 || loaded.LSN() != tree.LSN() {
+		t.Fatal("the compacted tree should be flushed")
+	}
+}
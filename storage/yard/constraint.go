@@ -0,0 +1,264 @@
+package yard
+
+import (
+	"fmt"
+	"github.com/lycying/pitydb/dt"
+	"github.com/lycying/pitydb/expr"
+	"sort"
+	"time"
+)
+
+// ConstraintError is returned by a write that breaks a constraint of the RowMeta
+type ConstraintError struct {
+	Kind     dt.ConstraintKind
+	Name     string
+	Key      uint32 //the key of the row that is written
+	Conflict uint32 //the key of the row of the same cells if Kind is UniqueConstraint
+}
+
+func (e *ConstraintError) Error() string {
+	if e.Kind == dt.UniqueConstraint {
+		return fmt.Sprintf("row %d violates UNIQUE constraint %q, row %d has the same cells", e.Key, e.Name, e.Conflict)
+	}
+	return fmt.Sprintf("row %d violates %s constraint %q", e.Key, e.Kind, e.Name)
+}
+
+// GetCellByName implements expr.Row, a cell that is not set is NULL
+func (r *Row) GetCellByName(name string) (dt.DtRefer, error) {
+	item := r.meta.GetCellMeta(name)
+	if nil == item {
+		return nil, expr.ErrNoColumn
+	}
+	if item.GetPos() >= len(r.cells) {
+		return nil, nil
+	}
+	return r.cells[item.GetPos()], nil
+}
+
+func (r *Row) isNull(pos int) bool {
+	return pos >= len(r.cells) || nil == r.cells[pos]
+}
+
+type checkConstraint struct {
+	c    *dt.Constraint
+	cond expr.Expr
+}
+
+type notNullConstraint struct {
+	c   *dt.Constraint
+	pos int
+}
+
+// constraints are the constraints of a tree ready to be checked
+type constraints struct {
+	list     []*dt.Constraint //the constraints of the meta they are built from
+	notNulls []*notNullConstraint
+	checks   []*checkConstraint
+	uniques  []*uniqueIndex
+}
+
+// getConstraints builds the constraints again if the constraints of the meta are changed
+func (tree *PageTree) getConstraints() (*constraints, error) {
+	list := tree.meta.GetConstraints()
+	if nil != tree.cons && sameConstraints(tree.cons.list, list) {
+		return tree.cons, nil
+	}
+	cons := &constraints{list: list}
+	for _, c := range list {
+		switch c.Kind {
+		case dt.NotNullConstraint, dt.UniqueConstraint:
+			var pos []int
+			for _, name := range c.Columns {
+				item := tree.meta.GetCellMeta(name)
+				if nil == item {
+					return nil, fmt.Errorf("constraint %q: %v %q", c.Name, expr.ErrNoColumn, name)
+				}
+				pos = append(pos, item.GetPos())
+			}
+			if c.Kind == dt.NotNullConstraint {
+				for _, p := range pos {
+					cons.notNulls = append(cons.notNulls, &notNullConstraint{c: c, pos: p})
+				}
+				continue
+			}
+			idx, err := tree.buildUniqueIndex(c, pos)
+			if err != nil {
+				return nil, err
+			}
+			cons.uniques = append(cons.uniques, idx)
+		case dt.CheckConstraint:
+			//the cells may be changed after the check is validated
+			cond, err := expr.ParseCheck(c.Check, tree.meta)
+			if err != nil {
+				return nil, fmt.Errorf("constraint %q: %v", c.Name, err)
+			}
+			cons.checks = append(cons.checks, &checkConstraint{c: c, cond: cond})
+		}
+	}
+	tree.cons = cons
+	return cons, nil
+}
+
+func sameConstraints(a []*dt.Constraint, b []*dt.Constraint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].Kind != b[i].Kind || a[i].Check != b[i].Check ||
+			fmt.Sprint(a[i].Columns) != fmt.Sprint(b[i].Columns) {
+			return false
+		}
+	}
+	return true
+}
+
+// check returns a *ConstraintError if r can not be written by the tree
+func (cons *constraints) check(tree *PageTree, r *Row) error {
+	for _, nn := range cons.notNulls {
+		if r.isNull(nn.pos) {
+			return &ConstraintError{Kind: dt.NotNullConstraint, Name: nn.c.Name, Key: r.GetKey()}
+		}
+	}
+	for _, ck := range cons.checks {
+		ok, err := expr.EvalBool(ck.cond, r)
+		if err != nil {
+			return fmt.Errorf("constraint %q: %v", ck.c.Name, err)
+		}
+		//NULL is not false
+		if nil != ok && !*ok {
+			return &ConstraintError{Kind: dt.CheckConstraint, Name: ck.c.Name, Key: r.GetKey()}
+		}
+	}
+	now := time.Now().UnixNano()
+	for _, idx := range cons.uniques {
+		if conflict, ok := idx.conflict(tree, r, now); ok {
+			return &ConstraintError{Kind: dt.UniqueConstraint, Name: idx.c.Name, Key: r.GetKey(), Conflict: conflict}
+		}
+	}
+	return nil
+}
+
+// replace updates the unique indexes when old is replaced by r, old or r may be nil
+func (cons *constraints) replace(old *Row, r *Row) {
+	for _, idx := range cons.uniques {
+		if nil != old {
+			idx.remove(old)
+		}
+		if nil != r {
+			idx.add(r)
+		}
+	}
+}
+
+type uniqueEntry struct {
+	cells []dt.DtRefer
+	key   uint32
+}
+
+// uniqueIndex keeps the cells of a UNIQUE constraint of every row in order,
+// a row with a NULL cell is not in it. An expired row stays in the index until
+// it is deleted, so the entries of a value may be more than one.
+//
+// The index is not saved, it is a sorted slice in memory that is built by a
+// scan of all the rows on the first write after the tree is opened or the
+// constraints are changed, and an insert into it moves the entries after it.
+// It suits the tables that fit in memory.
+type uniqueIndex struct {
+	c       *dt.Constraint
+	pos     []int
+	entries []*uniqueEntry
+}
+
+func (tree *PageTree) buildUniqueIndex(c *dt.Constraint, pos []int) (*uniqueIndex, error) {
+	idx := &uniqueIndex{c: c, pos: pos}
+	now := time.Now().UnixNano()
+	var err error
+	tree.walk(func(pg *Page) bool {
+		if pg.isIndexPage() {
+			return true
+		}
+		for _, r := range pg.rows {
+			if conflict, ok := idx.conflict(tree, r, now); ok && !r.expired(now) {
+				err = &ConstraintError{Kind: dt.UniqueConstraint, Name: c.Name, Key: r.GetKey(), Conflict: conflict}
+				return false
+			}
+			idx.add(r)
+		}
+		return true
+	})
+	return idx, err
+}
+
+func (idx *uniqueIndex) entryOf(r *Row) *uniqueEntry {
+	cells := make([]dt.DtRefer, len(idx.pos))
+	for i, p := range idx.pos {
+		if r.isNull(p) {
+			return nil
+		}
+		//the row may be changed by the caller after it is written
+		cells[i] = r.cells[p].DeepCopy()
+	}
+	return &uniqueEntry{cells: cells, key: r.GetKey()}
+}
+
+func compareCells(a []dt.DtRefer, b []dt.DtRefer) int {
+	for i := range a {
+		if c := a[i].Compare(b[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// search returns the index of the first entry of cells
+func (idx *uniqueIndex) search(cells []dt.DtRefer) int {
+	return sort.Search(len(idx.entries), func(i int) bool {
+		return compareCells(idx.entries[i].cells, cells) >= 0
+	})
+}
+
+// conflict returns the key of a live row that has the cells of r
+func (idx *uniqueIndex) conflict(tree *PageTree, r *Row, now int64) (uint32, bool) {
+	e := idx.entryOf(r)
+	if nil == e {
+		return 0, false
+	}
+	for i := idx.search(e.cells); i < len(idx.entries) && compareCells(idx.entries[i].cells, e.cells) == 0; i++ {
+		key := idx.entries[i].key
+		if key == e.key {
+			continue
+		}
+		node, j, find := tree.root.findOne(key)
+		if find && !node.rows[j].expired(now) {
+			return key, true
+		}
+	}
+	return 0, false
+}
+
+func (idx *uniqueIndex) add(r *Row) {
+	e := idx.entryOf(r)
+	if nil == e {
+		return
+	}
+	i := idx.search(e.cells)
+	for i < len(idx.entries) && compareCells(idx.entries[i].cells, e.cells) == 0 && idx.entries[i].key < e.key {
+		i++
+	}
+	idx.entries = append(idx.entries, nil)
+	copy(idx.entries[i+1:], idx.entries[i:])
+	idx.entries[i] = e
+}
+
+func (idx *uniqueIndex) remove(r *Row) {
+	e := idx.entryOf(r)
+	if nil == e {
+		return
+	}
+	for i := idx.search(e.cells); i < len(idx.entries) && compareCells(idx.entries[i].cells, e.cells) == 0; i++ {
+		if idx.entries[i].key == e.key {
+			idx.entries = append(idx.entries[:i], idx.entries[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package yard
+
+import (
+	"github.com/lycying/pitydb/dt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newConstraintTree() (*PageTree, *dt.RowMeta) {
+	meta := dt.NewRowMeta()
+	meta.AddCellMeta(dt.NewCellMetaRaw(0, dt.UInt32Type, "id", "", nil))
+	meta.AddCellMeta(dt.NewCellMetaRaw(1, dt.StringType, "email", "", nil).WithNotNull().WithUnique())
+	meta.AddCellMeta(dt.NewCellMetaRaw(2, dt.Int32Type, "qty", "", nil).WithCheck("qty >= 0"))
+	return NewPageTree(meta, nil), meta
+}
+
+func newConstraintRow(meta *dt.RowMeta, id uint32, email string, qty int32) *Row {
+	r := NewRow(meta)
+	r.WithDefaultValues()
+	r.SetKey(id)
+	r.SetCellValue(meta.GetItems()[0], id)
+	r.SetCellValue(meta.GetItems()[1], email)
+	r.SetCellValue(meta.GetItems()[2], qty)
+	return r
+}
+
+func constraintName(err error) string {
+	if ce, ok := err.(*ConstraintError); ok {
+		return ce.Name
+	}
+	return ""
+}
+
+func TestPageTree_Constraints(t *testing.T) {
+	tree, meta := newConstraintTree()
+	if err := tree.Insert(newConstraintRow(meta, 1, "a@pity.db", 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	err := tree.Insert(newConstraintRow(meta, 2, "b@pity.db", -1))
+	if constraintName(err) != "qty_check" {
+		t.Fatal("a negative qty should be refused,but ", err)
+	}
+	//the collation of the cell makes them the same
+	err = tree.Insert(newConstraintRow(meta, 2, "A@pity.db", 1))
+	if ce, ok := err.(*ConstraintError); !ok || ce.Name != "email_unique" || ce.Conflict != 1 || ce.Kind != dt.UniqueConstraint {
+		t.Fatal("the email is used by row 1,but ", err)
+	}
+	r := newConstraintRow(meta, 2, "", 1)
+	r.cells[1] = nil
+	if constraintName(tree.Insert(r)) != "email_not_null" {
+		t.Fatal("the email can not be NULL")
+	}
+	if _, ok := tree.Get(2); ok {
+		t.Fatal("no row should be written")
+	}
+
+	//a NULL check passes, but a NULL can not be saved yet
+	r = newConstraintRow(meta, 2, "b@pity.db", 1)
+	r.cells[2] = nil
+	if err := tree.Insert(r); err != ErrNullCell {
+		t.Fatal("only the NULL should fail the write,but ", err)
+	}
+	if err := tree.Insert(newConstraintRow(meta, 2, "b@pity.db", 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	//the row may keep its own email when it is updated
+	if err := tree.Insert(newConstraintRow(meta, 1, "a@pity.db", 2)); err != nil {
+		t.Fatal(err)
+	}
+	//an email is free after it is changed or deleted
+	if err := tree.Insert(newConstraintRow(meta, 1, "c@pity.db", 2)); err != nil {
+		t.Fatal(err)
+	}
+	tree.Delete(2)
+	if err := tree.Insert(newConstraintRow(meta, 3, "a@pity.db", 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.Insert(newConstraintRow(meta, 4, "b@pity.db", 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	//a constraint added later is checked against the rows already written
+	meta.AddUnique("qty_unique", "qty")
+	if constraintName(tree.Insert(newConstraintRow(meta, 5, "e@pity.db", 1))) != "qty_unique" {
+		t.Fatal("the qty should be unique")
+	}
+	meta.DropConstraint("qty_unique")
+
+	//a bad check is refused when it is declared and the writes go on
+	if err := meta.AddCheck("bad", "qty >"); err == nil {
+		t.Fatal("a bad check should be refused")
+	}
+	if err := meta.AddCheck("not_bool", "qty + 1"); err == nil {
+		t.Fatal("a check that is not a bool should be refused")
+	}
+	if err := meta.AddCheck("no_cell", "price > 0"); err == nil {
+		t.Fatal("a check of an unknown cell should be refused")
+	}
+	if err := tree.Insert(newConstraintRow(meta, 5, "e@pity.db", 1)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCatalog_Schema(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pitydb")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "catalog")
+
+	cat, _ := OpenCatalog(path)
+	seq, _ := cat.CreateSequence("ids", nil)
+	_, meta := newConstraintTree()
+	meta.GetItems()[0].WithAutoIncrement(seq)
+	meta.AddCheck("qty_max", "qty < 100")
+	if err := cat.SetSchema("orders", meta); err != nil {
+		t.Fatal(err)
+	}
+
+	cat, err := LoadCatalog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := cat.GetSchema("orders")
+	if nil == loaded || len(loaded.GetConstraints()) != 4 {
+		t.Fatal("the schema should be loaded with the constraints")
+	}
+	if loaded.GetItems()[0].GetAutoIncrement() != cat.GetSequence("ids") {
+		t.Fatal("the sequence should be bound again")
+	}
+
+	tree := NewPageTree(loaded, nil)
+	r := newConstraintRow(loaded, 0, "a@pity.db", 100)
+	r.cells[0] = nil
+	if constraintName(tree.Insert(r)) != "qty_max" {
+		t.Fatal("the loaded check should be enforced")
+	}
+
+	bad := dt.NewRowMeta()
+	bad.AddCellMeta(dt.NewCellMetaRaw(0, dt.UInt32Type, "id", "", nil))
+	bad.AddCellMeta(dt.NewCellMetaRaw(1, dt.Int32Type, "qty", "", nil).WithCheck("qty >"))
+	if err := cat.SetSchema("bad", bad); err == nil || nil != cat.GetSchema("bad") {
+		t.Fatal("a schema with a bad check should be refused")
+	}
+}
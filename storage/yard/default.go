@@ -0,0 +1,68 @@
+package yard
+
+import (
+	"github.com/lycying/pitydb/dt"
+	"github.com/lycying/pitydb/expr"
+	"sync"
+)
+
+// the parsed default expressions by their text, the same schema is used by many rows
+var defaultExprs sync.Map
+
+func parseDefault(text string) (expr.Expr, error) {
+	if e, ok := defaultExprs.Load(text); ok {
+		return e.(expr.Expr), nil
+	}
+	e, err := expr.Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	defaultExprs.Store(text, e)
+	return e, nil
+}
+
+// NextValue implements expr.SequenceRow, the sequences are found by the
+// SequenceSource of the meta, see dt.RowMeta.WithSequences
+func (r *Row) NextValue(sequence string) (int64, error) {
+	src := r.meta.GetSequences()
+	if nil == src {
+		return 0, expr.ErrNoSequence
+	}
+	seq := src.LookupSequence(sequence)
+	if nil == seq {
+		return 0, expr.ErrNoSequence
+	}
+	return seq.NextValue()
+}
+
+// evalDefaults sets the NULL cells that have a default expression to its
+// value. A default expression may use the cells before it.
+func (r *Row) evalDefaults() error {
+	for _, item := range r.meta.GetItems() {
+		if nil == item || item.GetDefaultExpr() == "" {
+			continue
+		}
+		pos := item.GetPos()
+		if pos >= len(r.cells) || !r.isNull(pos) {
+			continue
+		}
+		e, err := parseDefault(item.GetDefaultExpr())
+		if err != nil {
+			return err
+		}
+		v, err := e.Eval(r)
+		if err != nil {
+			return err
+		}
+		if nil == v {
+			r.cells[pos] = nil
+			continue
+		}
+		cell := item.NewCell()
+		if err := dt.TrySetValue(cell, v.GetValue()); err != nil {
+			return err
+		}
+		r.cells[pos] = cell
+	}
+	return nil
+}
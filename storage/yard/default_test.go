@@ -0,0 +1,101 @@
+package yard
+
+import (
+	"github.com/lycying/pitydb/dt"
+	"github.com/lycying/pitydb/expr"
+	"testing"
+	"time"
+)
+
+func TestRow_DefaultExpr(t *testing.T) {
+	cat := NewCatalog()
+	if _, err := cat.CreateSequence("ids", &SequenceOptions{Start: 100, Step: 1, Cache: 10}); err != nil {
+		t.Fatal(err)
+	}
+	meta := dt.NewRowMeta()
+	id := dt.NewCellMetaRaw(0, dt.Int64Type, "id", "", nil).WithDefaultExpr("nextval('ids')")
+	price := dt.NewCellMetaRaw(1, dt.DecimalType, "price", "", "2.50").WithDecimal(10, 2)
+	qty := dt.NewCellMetaRaw(2, dt.Int32Type, "qty", "", int32(4))
+	total := dt.NewCellMetaRaw(3, dt.DecimalType, "total", "", nil).WithDecimal(10, 2).WithDefaultExpr("price * qty")
+	at := dt.NewCellMetaRaw(4, dt.TimeType, "at", "", nil).WithDefaultExpr("now()")
+	ref := dt.NewCellMetaRaw(5, dt.UUIDType, "ref", "", nil).WithDefaultExpr("uuid()")
+	for _, item := range []*dt.CellMeta{id, price, qty, total, at, ref} {
+		meta.AddCellMeta(item)
+	}
+	if err := cat.SetSchema("orders", meta); err != nil {
+		t.Fatal(err)
+	}
+
+	before := time.Now().Add(-time.Second)
+	r := NewRow(meta)
+	if err := r.WithDefaultValues(); err != nil {
+		t.Fatal(err)
+	}
+	if nil != r.GetCellAt(id) || nil != r.GetCellAt(total) || r.GetCellAt(price).GetValue() != "2.50" {
+		t.Fatal("the default expressions should wait for the insert")
+	}
+
+	//the expressions see the cells set after the defaults
+	r.SetKey(1)
+	r.SetCellValue(qty, 10)
+	tree := NewPageTree(meta, nil)
+	if err := tree.Insert(r); err != nil {
+		t.Fatal(err)
+	}
+	if r.GetCellAt(total).GetValue() != "25.00" || r.GetCellAt(id).GetValue() != int64(100) {
+		t.Fatal("the default expressions should be evaluated", r.GetCellAt(id).GetValue(), r.GetCellAt(total).GetValue())
+	}
+	if r.GetCellAt(at).(*dt.Time).Time().Before(before) {
+		t.Fatal("at should be now")
+	}
+	if r.GetCellAt(ref).(*dt.UUID).Version() != 4 {
+		t.Fatal("ref should be a random uuid")
+	}
+
+	//a cell that is set keeps its value and takes no value of the sequence
+	r = NewRow(meta)
+	r.WithDefaultValues()
+	r.SetKey(2)
+	r.SetCellValue(id, 7)
+	r.SetCellValue(total, "1.00")
+	if err := tree.Insert(r); err != nil {
+		t.Fatal(err)
+	}
+	if r.GetCellAt(id).GetValue() != int64(7) || r.GetCellAt(total).GetValue() != "1.00" {
+		t.Fatal("the cells should be kept")
+	}
+	r = NewRow(meta)
+	r.WithDefaultValues()
+	r.SetKey(3)
+	if err := tree.Insert(r); err != nil || r.GetCellAt(id).GetValue() != int64(101) {
+		t.Fatal("the sequence should go on at 101", err)
+	}
+
+	//the decoded schema finds the sequences of the catalog
+	buf, err := cat.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cat2 := NewCatalog()
+	if _, err := cat2.Decode(buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	r = NewRow(cat2.GetSchema("orders"))
+	r.WithDefaultValues()
+	r.SetKey(1)
+	if err := NewPageTree(cat2.GetSchema("orders"), nil).Insert(r); err != nil {
+		t.Fatal(err)
+	}
+	if v := r.cells[0].GetValue().(int64); v <= 101 {
+		t.Fatal("the sequence should go on after 101, not ", v)
+	}
+
+	//nextval needs a catalog
+	meta.WithSequences(nil)
+	r = NewRow(meta)
+	r.WithDefaultValues()
+	r.SetKey(4)
+	if err := tree.Insert(r); err != expr.ErrNoSequence {
+		t.Fatal("nextval should fail without sequences", err)
+	}
+}
@@ -0,0 +1,98 @@
+package yard
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// PageInfo is the exported structure of one page
+type PageInfo struct {
+	ID       uint32   `json:"id"`
+	Type     string   `json:"type"`
+	Level    uint32   `json:"level"`
+	ParentID uint32   `json:"parent"`
+	MinKey   uint32   `json:"minKey"`
+	MaxKey   uint32   `json:"maxKey"`
+	Fill     int      `json:"fill"` //bytes used by the rows
+	Rows     int      `json:"rows"`
+	Children []uint32 `json:"children,omitempty"`
+}
+
+// TreeInfo is the exported structure of a PageTree,
+// the pages are listed in depth-first order
+type TreeInfo struct {
+	RootID uint32      `json:"root"`
+	Pages  []*PageInfo `json:"pages"`
+}
+
+func pageTypeName(pg *Page) string {
+	if pg.isIndexPage() {
+		return "index"
+	}
+	return "data"
+}
+
+func newPageInfo(pg *Page) *PageInfo {
+	info := &PageInfo{
+		ID:       pg.pgID.GetValue().(uint32),
+		Type:     pageTypeName(pg),
+		Level:    pg.level.GetValue().(uint32),
+		ParentID: _getParentID(pg),
+		Fill:     pg.GetLen(),
+		Rows:     len(pg.rows),
+	}
+	if len(pg.rows) > 0 {
+		info.MinKey = pg.rows[0].GetKey()
+		info.MaxKey = pg.rows[len(pg.rows)-1].GetKey()
+	}
+	if pg.isIndexPage() {
+		info.Children = make([]uint32, 0, len(pg.rows))
+		for _, x := range pg.rows {
+			info.Children = append(info.Children, x.cells[0].GetValue().(uint32))
+		}
+	}
+	return info
+}
+
+// Export returns the structure of the tree
+func (tree *PageTree) Export() *TreeInfo {
+	tree.lock.RLock()
+	defer tree.lock.RUnlock()
+
+	info := &TreeInfo{
+		RootID: tree.root.pgID.GetValue().(uint32),
+		Pages:  make([]*PageInfo, 0),
+	}
+	tree.walk(func(pg *Page) bool {
+		info.Pages = append(info.Pages, newPageInfo(pg))
+		return true
+	})
+	return info
+}
+
+// ExportJSON writes the structure of the tree as json
+func (tree *PageTree) ExportJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(tree.Export())
+}
+
+// ExportDOT writes the structure of the tree as a Graphviz digraph
+func (tree *PageTree) ExportDOT(w io.Writer) error {
+	info := tree.Export()
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph PageTree {")
+	fmt.Fprintln(bw, "\tnode [shape=record];")
+	for _, pg := range info.Pages {
+		fmt.Fprintf(bw, "\tp%d [label=\"{%s %d|level %d|keys %d..%d|rows %d|fill %d}\"];\n",
+			pg.ID, pg.Type, pg.ID, pg.Level, pg.MinKey, pg.MaxKey, pg.Rows, pg.Fill)
+	}
+	for _, pg := range info.Pages {
+		for _, child := range pg.Children {
+			fmt.Fprintf(bw, "\tp%d -> p%d;\n", pg.ID, child)
+		}
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
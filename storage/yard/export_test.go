@@ -0,0 +1,83 @@
+package yard
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/lycying/pitydb/dt"
+	"strings"
+	"testing"
+)
+
+func newTestTree(count int) *PageTree {
+	rowMeta := dt.NewRowMeta()
+	slot0 := dt.NewCellMetaRaw(0, dt.UInt32Type, "id", "the auto incrementID", nil)
+	slot1 := dt.NewCellMetaRaw(1, dt.Float64Type, "col2", "a flot64...", float64(0.9999999))
+	slot2 := dt.NewCellMetaRaw(2, dt.StringType, "col3", "a string with default value pitydb...", "pitydb")
+
+	rowMeta.AddCellMeta(slot0)
+	rowMeta.AddCellMeta(slot1)
+	rowMeta.AddCellMeta(slot2)
+
+	tree := NewPageTree(rowMeta, nil)
+	for i := count; i >= 1; i-- {
+		r := NewRow(rowMeta)
+		r.WithDefaultValues()
+		r.SetKey(uint32(i))
+		r.SetCellValueForTest(rowMeta.GetItems()[0], uint32(i))
+		r.SetCellValueForTest(rowMeta.GetItems()[2], "Hard work make bird stupid!")
+		tree.Insert(r)
+	}
+	return tree
+}
+
+func TestPageTree_Export(t *testing.T) {
+	tree := newTestTree(100)
+	info := tree.Export()
+
+	if info.RootID != info.Pages[0].ID {
+		t.Fatal("the first page should be the root")
+	}
+	rows := 0
+	for _, pg := range info.Pages {
+		if pg.Type == "data" {
+			rows += pg.Rows
+			if pg.Level != 0 {
+				t.Fatal("data page should be at level 0, but ", pg.Level)
+			}
+		}
+		if pg.MinKey > pg.MaxKey {
+			t.Fatalf("page %d has a bad key range %d..%d", pg.ID, pg.MinKey, pg.MaxKey)
+		}
+	}
+	if rows != 100 {
+		t.Fatal("the data pages should hold 100 rows, but ", rows)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := tree.ExportJSON(buf); err != nil {
+		t.Fatal(err)
+	}
+	info2 := &TreeInfo{}
+	if err := json.Unmarshal(buf.Bytes(), info2); err != nil {
+		t.Fatal(err)
+	}
+	if len(info2.Pages) != len(info.Pages) || info2.RootID != info.RootID {
+		t.Fatal("the json export should keep the tree shape")
+	}
+}
+
+func TestPageTree_ExportDOT(t *testing.T) {
+	tree := newTestTree(100)
+	buf := new(bytes.Buffer)
+	if err := tree.ExportDOT(buf); err != nil {
+		t.Fatal(err)
+	}
+	dot := buf.String()
+	if !strings.HasPrefix(dot, "digraph PageTree {") || !strings.HasSuffix(dot, "}\n") {
+		t.Fatal("not a digraph: ", dot)
+	}
+	edges := strings.Count(dot, "->")
+	if edges != len(tree.Export().Pages)-1 {
+		t.Fatal("every page except the root should have one parent edge, but ", edges)
+	}
+}
@@ -0,0 +1,275 @@
+package yard
+
+import (
+	"bytes"
+	"errors"
+	"github.com/lycying/pitydb/dt"
+	"github.com/lycying/pitydb/utils"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const superBlockMagic uint32 = 0x50495459 //PITY
+const superBlockVersion byte = 1
+
+var ErrBadSuperBlock = errors.New("bad superblock")
+var ErrPageFileChecksum = errors.New("page file checksum mismatch")
+
+// superBlock is the head of a page file, the pages follow it one by one
+// and each page is saved with its length before it
+type superBlock struct {
+	dt.Encoder
+	dt.DeCoder
+
+	magic      *dt.UInt32
+	version    *dt.Byte
+	pageSize   *dt.UInt32
+	rootID     *dt.UInt32
+	pageCount  *dt.UInt32
+	nextPageID *dt.UInt32
+	checksum   *dt.UInt32 //checksum of all the pages
+}
+
+func newSuperBlock() *superBlock {
+	return &superBlock{
+		magic:      dt.ValidNewUInt32(superBlockMagic),
+		version:    dt.ValidNewByte(superBlockVersion),
+		pageSize:   dt.ValidNewUInt32(DefaultPageSize),
+		rootID:     dt.NewUInt32(),
+		pageCount:  dt.NewUInt32(),
+		nextPageID: dt.NewUInt32(),
+		checksum:   dt.NewUInt32(),
+	}
+}
+
+func (sb *superBlock) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	writeDt(buf, sb.magic)
+	writeDt(buf, sb.version)
+	writeDt(buf, sb.pageSize)
+	writeDt(buf, sb.rootID)
+	writeDt(buf, sb.pageCount)
+	writeDt(buf, sb.nextPageID)
+	writeDt(buf, sb.checksum)
+	return buf.Bytes(), nil
+}
+
+func (sb *superBlock) Decode(buf []byte, offset int) (int, error) {
+	if len(buf)-offset < sb.GetLen() {
+		return 0, ErrBadSuperBlock
+	}
+	idx := offset
+	idx = readDt(buf, idx, sb.magic)
+	idx = readDt(buf, idx, sb.version)
+	idx = readDt(buf, idx, sb.pageSize)
+	idx = readDt(buf, idx, sb.rootID)
+	idx = readDt(buf, idx, sb.pageCount)
+	idx = readDt(buf, idx, sb.nextPageID)
+	idx = readDt(buf, idx, sb.checksum)
+	if sb.magic.GetValue().(uint32) != superBlockMagic || sb.version.GetValue().(byte) != superBlockVersion {
+		return idx - offset, ErrBadSuperBlock
+	}
+	return idx - offset, nil
+}
+
+func (sb *superBlock) GetLen() int {
+	return 4 + 1 + 4 + 4 + 4 + 4 + 4
+}
+
+// encodePages writes the pages of mgr that can be reached from root
+func encodePages(mgr *PageManagement, root *Page) []byte {
+	body := new(bytes.Buffer)
+	count := uint32(0)
+	_walk(mgr, root, func(pg *Page) bool {
+		writePage(body, pg)
+		count++
+		return true
+	})
+
+	sb := newSuperBlock()
+	sb.rootID.SetValue(root.pgID.GetValue())
+	sb.pageCount.SetValue(count)
+	sb.nextPageID.SetValue(mgr.nextPageID)
+	sb.checksum.SetValue(utils.Sum32(body.Bytes()))
+
+	bSb, _ := sb.Encode()
+	buf := new(bytes.Buffer)
+	buf.Write(bSb)
+	buf.Write(body.Bytes())
+	return buf.Bytes()
+}
+
+// writePage saves the page with its length before it
+func writePage(buf *bytes.Buffer, pg *Page) {
+	b, _ := pg.Encode()
+	writeDt(buf, dt.ValidNewUInt32(uint32(len(b))))
+	buf.Write(b)
+}
+
+// readPages reads count pages saved by writePage into mgr
+func (tree *PageTree) readPages(buf []byte, offset int, count uint32, mgr *PageManagement) int {
+	idx := offset
+	for i := uint32(0); i < count; i++ {
+		pgLen := dt.NewUInt32()
+		idx = readDt(buf, idx, pgLen)
+		pg := tree.allocPage(0, 0, dataPageType)
+		pg.Decode(buf, idx)
+		mgr.AddPage(pg)
+		idx += int(pgLen.GetValue().(uint32))
+	}
+	return idx - offset
+}
+
+// linkParents sets the parent of the pages that can be reached from root
+// and returns the largest lsn of them
+func linkParents(mgr *PageManagement, root *Page) uint64 {
+	lsn := uint64(0)
+	_walk(mgr, root, func(pg *Page) bool {
+		if pg.lsn.GetValue().(uint64) > lsn {
+			lsn = pg.lsn.GetValue().(uint64)
+		}
+		if pg.isIndexPage() {
+			for _, x := range pg.rows {
+				mgr.GetPage(x.cells[0].GetValue().(uint32)).parent = pg
+			}
+		}
+		return true
+	})
+	return lsn
+}
+
+// decodePages reads the pages into mgr, fixes the parent pointers and returns the root
+func (tree *PageTree) decodePages(buf []byte, mgr *PageManagement) (*Page, error) {
+	sb := newSuperBlock()
+	idx, err := sb.Decode(buf, 0)
+	if err != nil {
+		return nil, err
+	}
+	if utils.Sum32(buf[idx:]) != sb.checksum.GetValue().(uint32) {
+		return nil, ErrPageFileChecksum
+	}
+	tree.readPages(buf, idx, sb.pageCount.GetValue().(uint32), mgr)
+	mgr.nextPageID = sb.nextPageID.GetValue().(uint32)
+
+	root, ok := mgr.pageMap[sb.rootID.GetValue().(uint32)]
+	if !ok {
+		return nil, ErrBadSuperBlock
+	}
+	tree.lsn = linkParents(mgr, root)
+	return root, nil
+}
+
+// writeFileAtomic writes b to a temp file and renames it to path after fsync,
+// the readers of path see either the old or the new file
+func writeFileAtomic(path string, b []byte) error {
+	tmp := path + ".tmp"
+	if err := writeFileSync(tmp, b); err != nil {
+		return err
+	}
+	return renameSync(tmp, path)
+}
+
+func writeFileSync(path string, b []byte) error {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(b); err == nil {
+		err = f.Sync()
+	}
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+func renameSync(from string, to string) error {
+	if err := os.Rename(from, to); err != nil {
+		return err
+	}
+	//make the rename durable
+	if dir, err := os.Open(filepath.Dir(to)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
+// OpenPageTree loads the tree saved in the page file of path
+func OpenPageTree(meta *dt.RowMeta, path string) (*PageTree, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tree := &PageTree{
+		meta: meta,
+		mgr:  NewPageMgr(),
+	}
+	root, err := tree.decodePages(buf, tree.mgr)
+	if err != nil {
+		return nil, err
+	}
+	tree.root = root
+	tree.link, err = os.OpenFile(path, os.O_RDWR, 0666)
+	if err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// Flush writes all the pages of the tree to the linked file, then the wal
+// records that are in the pages are cut off. The records after the last
+// backup are kept for a point-in-time restore, see Restore.
+func (tree *PageTree) Flush() error {
+	tree.flushLock.Lock()
+	defer tree.flushLock.Unlock()
+	return tree.flush()
+}
+
+// flush is Flush with the flushLock held
+func (tree *PageTree) flush() error {
+	tree.lock.RLock()
+	link := tree.link
+	lsn := tree.lsn
+	var b []byte
+	if nil != link {
+		b = encodePages(tree.mgr, tree.root)
+	}
+	tree.lock.RUnlock()
+
+	if nil == link {
+		return nil
+	}
+	if err := writeFileAtomic(link.Name(), b); err != nil {
+		return err
+	}
+
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+	if err := tree.relink(); err != nil {
+		return err
+	}
+	if nil == tree.wal {
+		return nil
+	}
+	if tree.backupLSN > 0 && tree.backupLSN < lsn {
+		lsn = tree.backupLSN
+	}
+	return tree.wal.truncate(lsn)
+}
+
+// relink opens the linked file again after it is replaced by a rename,
+// the space of the old file is released when it is closed
+func (tree *PageTree) relink() error {
+	link, err := os.OpenFile(tree.link.Name(), os.O_RDWR, 0666)
+	if err != nil {
+		return err
+	}
+	tree.link.Close()
+	tree.link = link
+	return nil
+}
@@ -0,0 +1,66 @@
+package yard
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/lycying/pitydb/dt"
+)
+
+// MarshalJSON writes the row as an object of its cells by name, a NULL cell
+// is null, see dt.ToJsonValue
+func (r *Row) MarshalJSON() ([]byte, error) {
+	obj := make(map[string]interface{}, len(r.cells))
+	for _, item := range r.meta.GetItems() {
+		if nil == item {
+			continue
+		}
+		v, err := dt.ToJsonValue(r.GetCellAt(item))
+		if err != nil {
+			return nil, fmt.Errorf("cell %s: %v", item.GetName(), err)
+		}
+		obj[item.GetName()] = v
+	}
+	return json.Marshal(obj)
+}
+
+// UnmarshalJSON sets the cells of a row made by NewRow from an object of the
+// cells by name. A cell that is not in the object keeps its default value, a
+// name that is not a cell is an error. The key is the first cell if it is an
+// integer, like the key of a struct, see MarshalRow.
+func (r *Row) UnmarshalJSON(data []byte) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var obj map[string]interface{}
+	if err := d.Decode(&obj); err != nil {
+		return err
+	}
+	if nil == obj {
+		return fmt.Errorf("a row is a json object, not null")
+	}
+	if err := r.WithDefaultValues(); err != nil {
+		return err
+	}
+	for name, v := range obj {
+		item := r.meta.GetCellMeta(name)
+		if nil == item {
+			return fmt.Errorf("%s: no such cell", name)
+		}
+		cell, err := item.FromJsonValue(v)
+		if err != nil {
+			return err
+		}
+		r.cells[item.GetPos()] = cell
+	}
+	if first := r.GetCellByPos(0); nil != first {
+		switch typ, _ := dt.TypeOf(first); typ {
+		case dt.ByteType, dt.Int32Type, dt.UInt32Type, dt.Int64Type, dt.UInt64Type:
+			key, err := dt.Cast(first, dt.UInt32Type)
+			if err != nil {
+				return fmt.Errorf("cell %s: %v", r.meta.GetItems()[0].GetName(), err)
+			}
+			r.SetKey(key.(uint32))
+		}
+	}
+	return nil
+}
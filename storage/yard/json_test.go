@@ -0,0 +1,74 @@
+package yard
+
+import (
+	"encoding/json"
+	"github.com/lycying/pitydb/dt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRow_JSON(t *testing.T) {
+	meta, err := StructMeta(&testOrder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := testOrder{
+		ID: 9, Name: "tea", Status: "paid", Price: "4.50", Qty: 2,
+		At:     time.Date(2024, 2, 29, 8, 0, 0, 0, time.UTC),
+		Ref:    [16]byte{1, 2, 3},
+		Tags:   []string{"hot", "green"},
+		Extra:  map[string]int32{"cups": 3},
+		ShipTo: testAddress{City: "Oslo"},
+	}
+	r, err := MarshalRow(meta, &order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `{"at":"2024-02-29T08:00:00Z","extra":{"cups":3},"id":9,"name":"tea","note":null,"price":"4.50",` +
+		`"qty":2,"ref":"01020300-0000-0000-0000-000000000000","ship_to":{"Zip":null,"city":"Oslo"},"status":"paid","tags":["hot","green"]}`
+	if string(b) != expect {
+		t.Fatal("the row should be an object of its cells", string(b))
+	}
+
+	r2 := NewRow(meta)
+	if err := json.Unmarshal(b, r2); err != nil {
+		t.Fatal(err)
+	}
+	var back testOrder
+	if err := UnmarshalRow(r2, &back); err != nil {
+		t.Fatal(err)
+	}
+	if r2.GetKey() != 9 || !reflect.DeepEqual(back, order) {
+		t.Fatal("the row should be decoded", back)
+	}
+
+	r3 := NewRow(meta)
+	if err := json.Unmarshal([]byte(`{"id":3,"name":"milk"}`), r3); err != nil {
+		t.Fatal(err)
+	}
+	if r3.GetKey() != 3 || r3.GetCellAt(meta.GetCellMeta("price")).(*dt.Decimal).String() != "0.00" ||
+		r3.GetCellAt(meta.GetCellMeta("status")).GetValue() != "new" {
+		t.Fatal("a missing cell should be its default")
+	}
+
+	errs := map[string]string{
+		`{"id":"3"}`:                    "id: json string can not be uint32",
+		`{"id":-3}`:                     "id: json number can not be uint32, ",
+		`{"ship_to":{"city":[]}}`:       "ship_to.city: json array can not be string",
+		`{"tags":["a",1]}`:              "tags[1]: json number can not be string",
+		`{"colour":"red"}`:              "colour: no such cell",
+		`[1,2]`:                         "json: cannot unmarshal array",
+		`{"at":"2024-02-30T08:00:00Z"}`: "at: json string can not be time, ",
+	}
+	for text, msg := range errs {
+		if err := json.Unmarshal([]byte(text), NewRow(meta)); nil == err || !strings.HasPrefix(err.Error(), msg) {
+			t.Fatal(text, "should fail with", msg, "not", err)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package yard
+
+import (
+	"errors"
+	"fmt"
+	"github.com/lycying/pitydb/dt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	lobSuffix  = ".lob"
+	lobIDsFile = "ids" //the ids below the number in it may have been used
+	lobIDBatch = 1024  //the ids saved to lobIDsFile at a time
+)
+
+var ErrLobNotFound = errors.New("large object not found")
+
+// FileLobStore saves every large object of a tree as a file of a directory,
+// a large object is streamed to a temp file and renamed when it is complete,
+// so a crash never leaves half of an object behind its id. The ids are saved
+// in batches, an id is never used again even if its object is deleted.
+type FileLobStore struct {
+	lock   sync.Mutex
+	dir    string
+	nextID uint64
+	limit  uint64 //the ids from it are not saved yet
+}
+
+// OpenFileLobStore opens the directory dir, it is created if it does not exist
+func OpenFileLobStore(dir string) (*FileLobStore, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &FileLobStore{dir: dir, nextID: 1}
+	if b, err := ioutil.ReadFile(filepath.Join(dir, lobIDsFile)); err == nil {
+		limit, err := strconv.ParseUint(string(b), 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad %s: %v", lobIDsFile, err)
+		}
+		s.nextID = limit
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, info := range infos {
+		name := info.Name()
+		if strings.HasSuffix(name, ".tmp") {
+			//left by a crash while it was written
+			os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		if !strings.HasSuffix(name, lobSuffix) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, lobSuffix), 16, 64)
+		if err == nil && id >= s.nextID {
+			s.nextID = id + 1
+		}
+	}
+	s.limit = s.nextID
+	return s, nil
+}
+
+func (s *FileLobStore) path(id uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%016x%s", id, lobSuffix))
+}
+
+// Create copies r into a new large object, r is never held in memory
+func (s *FileLobStore) Create(r io.Reader) (uint64, int64, error) {
+	s.lock.Lock()
+	id := s.nextID
+	if id >= s.limit {
+		b := []byte(strconv.FormatUint(id+lobIDBatch, 16))
+		if err := writeFileAtomic(filepath.Join(s.dir, lobIDsFile), b); err != nil {
+			s.lock.Unlock()
+			return 0, 0, err
+		}
+		s.limit = id + lobIDBatch
+	}
+	s.nextID++
+	s.lock.Unlock()
+
+	path := s.path(id)
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return 0, 0, err
+	}
+	size, err := io.Copy(f, r)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return 0, 0, err
+	}
+	if err := renameSync(tmp, path); err != nil {
+		os.Remove(tmp)
+		return 0, 0, err
+	}
+	return id, size, nil
+}
+
+func (s *FileLobStore) Open(id uint64) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(id))
+	if os.IsNotExist(err) {
+		return nil, ErrLobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *FileLobStore) Delete(id uint64) error {
+	err := os.Remove(s.path(id))
+	if os.IsNotExist(err) {
+		return ErrLobNotFound
+	}
+	return err
+}
+
+// AttachLobStore makes the tree delete the large objects of a row in store
+// when the row is deleted or replaced by a row that does not use them.
+//
+// The objects are deleted at once and a Backup does not copy them, so a tree
+// that Restore brings back to a point before the delete has the row but
+// reading its object is ErrLobNotFound. The store of a tree that is restored
+// from backups is better not attached, its objects are then never deleted.
+func (tree *PageTree) AttachLobStore(store dt.LobStore) {
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+	tree.lobs = store
+}
+
+// lobIDs returns the ids of the large objects of the cells of r
+func lobIDs(r *Row) []uint64 {
+	var ids []uint64
+	for _, cell := range r.cells {
+		if b, ok := cell.(*dt.Bytes); ok && b.IsLob() {
+			ids = append(ids, b.GetValue().(dt.LobRef).ID)
+		}
+	}
+	return ids
+}
+
+// dropLobs deletes the large objects of old that r does not use, r is nil for
+// a delete. An object that is gone already, like on a replay of the wal, is
+// skipped.
+func (tree *PageTree) dropLobs(old *Row, r *Row) {
+	if nil == tree.lobs || old == r {
+		return
+	}
+	keep := make(map[uint64]bool)
+	if nil != r {
+		for _, id := range lobIDs(r) {
+			keep[id] = true
+		}
+	}
+	for _, id := range lobIDs(old) {
+		if !keep[id] {
+			tree.lobs.Delete(id)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package yard
+
+import (
+	"bytes"
+	"github.com/lycying/pitydb/dt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// patternReader yields size bytes without holding them in memory
+type patternReader struct {
+	size int64
+	read int64
+}
+
+func (r *patternReader) Read(b []byte) (int, error) {
+	if r.read >= r.size {
+		return 0, io.EOF
+	}
+	if int64(len(b)) > r.size-r.read {
+		b = b[:r.size-r.read]
+	}
+	for i := range b {
+		b[i] = byte((r.read + int64(i)) % 251)
+	}
+	r.read += int64(len(b))
+	return len(b), nil
+}
+
+func TestFileLobStore(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pitydb")
+	defer os.RemoveAll(dir)
+
+	store, err := OpenFileLobStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rowMeta := dt.NewRowMeta()
+	slot0 := dt.NewCellMetaRaw(0, dt.UInt32Type, "id", "the auto incrementID", nil)
+	slot1 := dt.NewCellMetaRaw(1, dt.BytesType, "attachment", "a file", nil)
+	rowMeta.AddCellMeta(slot0)
+	rowMeta.AddCellMeta(slot1)
+	tree := NewPageTree(rowMeta, nil)
+
+	const size = 8 << 20
+	r := NewRow(rowMeta)
+	r.WithDefaultValues()
+	r.SetKey(1)
+	if err := r.GetCellAt(slot1).(*dt.Bytes).WriteFrom(store, &patternReader{size: size}); err != nil {
+		t.Fatal(err)
+	}
+	tree.Insert(r)
+
+	found, _ := tree.Get(1)
+	out := new(bytes.Buffer)
+	n, err := found.GetCellAt(slot1).(*dt.Bytes).CopyTo(store, out)
+	if err != nil || n != size {
+		t.Fatal("the large object should be read back, ", n, err)
+	}
+	want, _ := ioutil.ReadAll(&patternReader{size: size})
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Fatal("the large object is changed")
+	}
+
+	//a torn object is removed and the ids go on after a reopen
+	ioutil.WriteFile(filepath.Join(dir, "torn.lob.tmp"), []byte("x"), 0666)
+	store, _ = OpenFileLobStore(dir)
+	if _, err := os.Stat(filepath.Join(dir, "torn.lob.tmp")); !os.IsNotExist(err) {
+		t.Fatal("the torn object should be removed")
+	}
+	id, _, _ := store.Create(bytes.NewReader([]byte("second")))
+	lob := found.GetCellAt(slot1).GetValue().(dt.LobRef)
+	if id <= lob.ID {
+		t.Fatal("a new id should be larger than the old ones")
+	}
+	//the id of a deleted object is not used again
+	store.Delete(id)
+	store, _ = OpenFileLobStore(dir)
+	if again, _, _ := store.Create(bytes.NewReader([]byte("third"))); again <= id {
+		t.Fatal("the id of a deleted object should not be used again", again, id)
+	}
+
+	//the tree deletes the objects its rows do not use any more
+	tree.AttachLobStore(store)
+	r2 := NewRow(rowMeta)
+	r2.WithDefaultValues()
+	r2.SetKey(1)
+	r2.GetCellAt(slot1).(*dt.Bytes).WriteFrom(store, bytes.NewReader([]byte("new")))
+	tree.Insert(r2)
+	if _, err := store.Open(lob.ID); err != ErrLobNotFound {
+		t.Fatal("the object of the replaced row should be deleted")
+	}
+	tree.Insert(r2)
+	lob2 := r2.GetCellAt(slot1).GetValue().(dt.LobRef)
+	if rc, err := store.Open(lob2.ID); err != nil {
+		t.Fatal("the object of the same row should be kept", err)
+	} else {
+		rc.Close()
+	}
+	tree.Delete(1)
+	if _, err := store.Open(lob2.ID); err != ErrLobNotFound {
+		t.Fatal("the object of the deleted row should be deleted")
+	}
+	if err := store.Delete(lob2.ID); err != ErrLobNotFound {
+		t.Fatal("a deleted object should not be found")
+	}
+}
+
+func TestPageTree_BackupLob(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pitydb")
+	defer os.RemoveAll(dir)
+
+	store, _ := OpenFileLobStore(dir)
+	rowMeta := dt.NewRowMeta()
+	slot0 := dt.NewCellMetaRaw(0, dt.UInt32Type, "id", "the auto incrementID", nil)
+	slot1 := dt.NewCellMetaRaw(1, dt.BytesType, "attachment", "a file", nil)
+	rowMeta.AddCellMeta(slot0)
+	rowMeta.AddCellMeta(slot1)
+	tree := NewPageTree(rowMeta, nil)
+	tree.AttachLobStore(store)
+
+	r := NewRow(rowMeta)
+	r.WithDefaultValues()
+	r.SetKey(1)
+	r.GetCellAt(slot1).(*dt.Bytes).WriteFrom(store, &patternReader{size: 1 << 20})
+	tree.Insert(r)
+	backup := new(bytes.Buffer)
+	if _, err := tree.Backup(backup, 0); err != nil {
+		t.Fatal(err)
+	}
+	tree.Delete(1)
+
+	//the backup has the row but not its object, which the delete has removed
+	restored, err := Restore(rowMeta, []io.Reader{backup}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, ok := restored.Get(1)
+	if !ok {
+		t.Fatal("the row should be restored")
+	}
+	lob := found.GetCellAt(slot1).GetValue().(dt.LobRef)
+	if _, err := store.Open(lob.ID); err != ErrLobNotFound {
+		t.Fatal("the object of a deleted row is not in the backup", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"github.com/lycying/pitydb/dt"
 	"sort"
+	"time"
 )
 
 const DefaultPageSize = 1024 * 2
@@ -24,6 +25,7 @@ type pageHeader struct {
 	right      *dt.UInt32
 	checksum   *dt.UInt32
 	lastModify *dt.UInt64 //time.Now().UnixNano()
+	lsn        *dt.UInt64 //the log sequence number of the last change
 	size       *dt.UInt32 //this counter is used to read data from disk
 }
 
@@ -36,6 +38,7 @@ func (header *pageHeader) Encode() ([]byte, error) {
 	bRight, _ := header.right.Encode()
 	bChecksum, _ := header.checksum.Encode()
 	bLastModify, _ := header.lastModify.Encode()
+	bLsn, _ := header.lsn.Encode()
 	bSize, _ := header.size.Encode()
 
 	buf := new(bytes.Buffer)
@@ -46,6 +49,7 @@ func (header *pageHeader) Encode() ([]byte, error) {
 	buf.Write(bRight)
 	buf.Write(bChecksum)
 	buf.Write(bLastModify)
+	buf.Write(bLsn)
 	buf.Write(bSize)
 
 	return buf.Bytes(), nil
@@ -68,6 +72,8 @@ func (header *pageHeader) Decode(buf []byte, offset int) (int, error) {
 	idx += lenChecksum
 	lenLastModify, _ := header.lastModify.Decode(buf, idx+offset)
 	idx += lenLastModify
+	lenLsn, _ := header.lsn.Decode(buf, idx+offset)
+	idx += lenLsn
 	lenSize, _ := header.size.Decode(buf, idx+offset)
 	idx += lenSize
 
@@ -87,12 +93,20 @@ type Page struct {
 	_len int    //finger if the size is larger than 16kb
 }
 
+// Decode reads the rows of the page, the key of each row is saved before the row
 func (p *Page) Decode(buf []byte, offset int) (int, error) {
 	idx, _ := p.pageHeader.Decode(buf, offset)
-	for _, row := range p.rows {
+	meta := p.rowMeta()
+	p.rows = make([]*Row, p.size.GetValue().(uint32))
+	for i := range p.rows {
+		row := NewRow(meta)
+		kLen, _ := row.key.Decode(buf, idx+offset)
+		idx += kLen
 		rLen, _ := row.Decode(buf, idx+offset)
 		idx += rLen
+		p.rows[i] = row
 	}
+	p._len = p.GetLen()
 	return idx, nil
 }
 
@@ -101,33 +115,34 @@ func (p *Page) Encode() ([]byte, error) {
 	bHeader, _ := p.pageHeader.Encode()
 	buf.Write(bHeader)
 	for _, row := range p.rows {
+		bKey, _ := row.key.Encode()
+		buf.Write(bKey)
 		bRow, _ := row.Encode()
 		buf.Write(bRow)
 	}
 	return buf.Bytes(), nil
 }
 
+func (p *Page) rowMeta() *dt.RowMeta {
+	if p.isIndexPage() {
+		return dt.DefaultIndexRowMeta()
+	}
+	return p.tree.meta
+}
+
+// findIndexRow returns the position after the child that covers the key.
+// The key of the first index row is ignored because it only grows stale
+// when smaller keys are inserted into the leftmost child
 func (p *Page) findIndexRow(key uint32) (*Page, int, bool) {
 	pSize := int(p.size.GetValue().(uint32))
 
-	i := sort.Search(pSize, func(i int) bool {
-		return key <= p.rows[i].GetKey()
-	})
 	//the rows is empty
-	if i == 0 && pSize == 0 {
+	if pSize == 0 {
 		return p, 0, false
 	}
-	if i >= pSize {
-		i = i - 1
-	}
-	if i > 0 {
-		x0 := p.rows[i].GetKey()
-		x1 := p.rows[i-1].GetKey()
-
-		if key < x0 && key > x1 {
-			i = i - 1
-		}
-	}
+	i := sort.Search(pSize-1, func(i int) bool {
+		return key < p.rows[i+1].GetKey()
+	})
 	return p, i + 1, true
 
 }
@@ -140,7 +155,7 @@ func (p *Page) findOne(key uint32) (*Page, int, bool) {
 		pgID := p.rows[count].cells[0].GetValue().(uint32)
 		next := p.tree.mgr.GetPage(pgID)
 
-		return next.findOne(pgID)
+		return next.findOne(key)
 	}
 
 	pSize := int(p.size.GetValue().(uint32))
@@ -166,6 +181,7 @@ func (p *Page) findOne(key uint32) (*Page, int, bool) {
 }
 
 func (p *Page) insert(row *Row, index int, find bool) int {
+	p.touch()
 	bs := p._len + row.GetLen()
 	if find {
 		bs = bs - p.rows[index].GetLen()
@@ -193,6 +209,7 @@ func (p *Page) insert(row *Row, index int, find bool) int {
 		}
 
 		newPage := p.tree.NewPage(p.level.GetValue().(uint32), p.pgType.GetValue().(byte))
+		newPage.touch()
 		//copy [:i-1] to newNode
 		newPage.copyRightPart(p, i-1)
 		//only left [i-1:] part
@@ -222,13 +239,27 @@ func (p *Page) insert(row *Row, index int, find bool) int {
 	return bs
 }
 
+// append adds the row to the tail without split, the rows must come in key order
+func (p *Page) append(row *Row) {
+	p.rows = append(p.rows, row)
+	p.size.SetValue(uint32(len(p.rows)))
+	p._len += row.GetLen()
+}
+
 func (p *Page) delete(key uint32, index int) {
+	p.touch()
 	p.rows = append(p.rows[:index], p.rows[index+1:]...)
 	pSize := p.size.GetValue().(uint32)
 	p.size.SetValue(pSize - 1)
 	p._len = p.GetLen()
 }
 
+// touch marks the page as changed by the current write of the tree
+func (p *Page) touch() {
+	p.lsn.SetValue(p.tree.lsn)
+	p.lastModify.SetValue(uint64(time.Now().UnixNano()))
+}
+
 func (p *Page) GetLen() int {
 	ret := 0
 	for _, row := range p.rows {
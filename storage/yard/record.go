@@ -0,0 +1,587 @@
+package yard
+
+import (
+	"errors"
+	"fmt"
+	"github.com/lycying/pitydb/dt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotStruct     = errors.New("the value is not a struct or a pointer to a struct")
+	ErrRecursiveType = errors.New("the struct contains itself")
+)
+
+// the names of the types in a pity tag
+var tagTypes = map[string]dt.DType{
+	"byte": dt.ByteType, "int32": dt.Int32Type, "uint32": dt.UInt32Type, "int64": dt.Int64Type,
+	"uint64": dt.UInt64Type, "float32": dt.Float32Type, "float64": dt.Float64Type, "bool": dt.BoolType,
+	"string": dt.StringType, "decimal": dt.DecimalType, "time": dt.TimeType, "array": dt.ArrayType,
+	"json": dt.JsonType, "bytes": dt.BytesType, "uuid": dt.UUIDType, "enum": dt.EnumType,
+	"struct": dt.StructType, "map": dt.MapType,
+}
+
+var tagCollations = map[string]dt.Collation{
+	"nocase": dt.CollateNoCase, "binary": dt.CollateBinary, "unicode": dt.CollateUnicode,
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	decimalType = reflect.TypeOf(dt.Decimal{})
+	uuidType    = reflect.TypeOf([16]byte{})
+	bytesType   = reflect.TypeOf([]byte{})
+)
+
+// recordField is a field of a struct and the cell it is saved to
+type recordField struct {
+	index []int
+	name  string
+	item  *dt.CellMeta
+}
+
+type record struct {
+	meta   *dt.RowMeta
+	fields []*recordField
+	key    *recordField //the field that is the key of the row
+}
+
+// the records by their struct type
+var records sync.Map
+
+// StructMeta returns the RowMeta of the struct of v, v may be a pointer. An
+// exported field is a cell named by the field unless it is tagged like
+//
+//	Price string `pity:"price,decimal,0.00,scale=2"`
+//
+// with the name, the type and the default value of the cell, an empty part is
+// taken from the field. A tag of "-" skips the field. The options after them
+// are key, notnull, unique, scale=n, collate=binary|nocase|unicode and
+// labels=a|b|c of an enum. The key of the row is the field of the option key,
+// or the first field if it is an integer. The same RowMeta is returned for the
+// same type.
+func StructMeta(v interface{}) (*dt.RowMeta, error) {
+	t := reflect.TypeOf(v)
+	for nil != t && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if nil == t || t.Kind() != reflect.Struct {
+		return nil, ErrNotStruct
+	}
+	rec, err := recordOf(t)
+	if err != nil {
+		return nil, err
+	}
+	return rec.meta, nil
+}
+
+func recordOf(t reflect.Type) (*record, error) {
+	return walkRecord(t, nil)
+}
+
+// walkRecord returns the record of t, walking are the structs that hold t in a field.
+// A struct that is a field of itself has no meta, its cells would never end.
+func walkRecord(t reflect.Type, walking []reflect.Type) (*record, error) {
+	if rec, ok := records.Load(t); ok {
+		return rec.(*record), nil
+	}
+	for _, w := range walking {
+		if w == t {
+			return nil, ErrRecursiveType
+		}
+	}
+	walking = append(walking, t)
+	rec := &record{meta: dt.NewRowMeta()}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("pity")
+		if f.PkgPath != "" || tag == "-" {
+			continue
+		}
+		field, isKey, err := newRecordField(f, tag, len(rec.fields), walking)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", f.Name, err)
+		}
+		rec.meta.AddCellMeta(field.item)
+		rec.fields = append(rec.fields, field)
+		if isKey {
+			rec.key = field
+		}
+	}
+	if nil == rec.key && len(rec.fields) > 0 {
+		switch rec.fields[0].item.GetMType() {
+		case dt.ByteType, dt.Int32Type, dt.UInt32Type, dt.Int64Type, dt.UInt64Type:
+			rec.key = rec.fields[0]
+		}
+	}
+	actual, _ := records.LoadOrStore(t, rec)
+	return actual.(*record), nil
+}
+
+func newRecordField(f reflect.StructField, tag string, pos int, walking []reflect.Type) (*recordField, bool, error) {
+	parts := strings.Split(tag, ",")
+	for len(parts) < 3 {
+		parts = append(parts, "")
+	}
+	name, typName, def := parts[0], parts[1], parts[2]
+	if name == "" {
+		name = f.Name
+	}
+	ft := f.Type
+	if ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+	}
+	typ, ok := tagTypes[typName]
+	if typName == "" {
+		typ, ok = goType(ft)
+	}
+	if !ok {
+		return nil, false, fmt.Errorf("no type for %s %q", ft, typName)
+	}
+
+	item := dt.NewCellMetaRaw(pos, typ, name, "", nil)
+	isKey := false
+	for _, opt := range parts[3:] {
+		kv := strings.SplitN(opt, "=", 2)
+		switch kv[0] {
+		case "key":
+			isKey = true
+		case "notnull":
+			item.WithNotNull()
+		case "unique":
+			item.WithUnique()
+		case "scale":
+			scale, err := strconv.Atoi(optValue(kv))
+			if err != nil {
+				return nil, false, fmt.Errorf("bad scale %q", optValue(kv))
+			}
+			item.WithDecimal(0, scale)
+		case "collate":
+			c, ok := tagCollations[optValue(kv)]
+			if !ok {
+				return nil, false, fmt.Errorf("no collation %q", optValue(kv))
+			}
+			item.WithCollation(c)
+		case "labels":
+			item.WithEnumLabels(strings.Split(optValue(kv), "|")...)
+		default:
+			return nil, false, fmt.Errorf("unknown option %q", opt)
+		}
+	}
+	if err := declareGoType(item, typ, ft, walking); err != nil {
+		return nil, false, err
+	}
+	if def != "" {
+		cell := item.NewCell()
+		if err := dt.TrySetValue(cell, def); err != nil {
+			return nil, false, fmt.Errorf("bad default %q: %v", def, err)
+		}
+		item = withDefault(item, cell.GetValue())
+	}
+	return &recordField{index: f.Index, name: name, item: item}, isKey, nil
+}
+
+func optValue(kv []string) string {
+	if len(kv) < 2 {
+		return ""
+	}
+	return kv[1]
+}
+
+// withDefault returns item with the default value v, it is only set by NewCellMetaRaw
+func withDefault(item *dt.CellMeta, v interface{}) *dt.CellMeta {
+	precision, scale := item.GetDecimal()
+	keyType, valueType := item.GetMapTypes()
+	ret := dt.NewCellMetaRaw(item.GetPos(), item.GetMType(), item.GetName(), item.GetComment(), v).
+		WithDecimal(precision, scale).WithTimeKind(item.GetTimeKind()).WithSubType(item.GetSubType()...).
+		WithCollation(item.GetCollation()).WithEnumLabels(item.GetEnumLabels()...).
+		WithStructMeta(item.GetStructMeta()).WithMapTypes(keyType, valueType)
+	if item.IsNotNull() {
+		ret.WithNotNull()
+	}
+	if item.IsUnique() {
+		ret.WithUnique()
+	}
+	return ret
+}
+
+// goType returns the type of the cell of a go type
+func goType(t reflect.Type) (dt.DType, bool) {
+	switch t {
+	case timeType:
+		return dt.TimeType, true
+	case decimalType:
+		return dt.DecimalType, true
+	case uuidType:
+		return dt.UUIDType, true
+	case bytesType:
+		return dt.BytesType, true
+	}
+	switch t.Kind() {
+	case reflect.Uint8:
+		return dt.ByteType, true
+	case reflect.Int32:
+		return dt.Int32Type, true
+	case reflect.Uint32:
+		return dt.UInt32Type, true
+	case reflect.Int, reflect.Int64:
+		return dt.Int64Type, true
+	case reflect.Uint, reflect.Uint64:
+		return dt.UInt64Type, true
+	case reflect.Float32:
+		return dt.Float32Type, true
+	case reflect.Float64:
+		return dt.Float64Type, true
+	case reflect.Bool:
+		return dt.BoolType, true
+	case reflect.String:
+		return dt.StringType, true
+	case reflect.Struct:
+		return dt.StructType, true
+	case reflect.Map:
+		return dt.MapType, true
+	case reflect.Slice:
+		return dt.ArrayType, true
+	}
+	return 0, false
+}
+
+// declareGoType declares the item types of an array, a map or a struct by the go type
+func declareGoType(item *dt.CellMeta, typ dt.DType, t reflect.Type, walking []reflect.Type) error {
+	switch typ {
+	case dt.StructType:
+		if t.Kind() != reflect.Struct {
+			return ErrNotStruct
+		}
+		rec, err := walkRecord(t, walking)
+		if err != nil {
+			return err
+		}
+		item.WithStructMeta(rec.meta)
+	case dt.MapType:
+		if t.Kind() != reflect.Map {
+			return dt.ErrMapType
+		}
+		k, ok := goType(t.Key())
+		v, ok2 := goType(t.Elem())
+		if !ok || !ok2 {
+			return dt.ErrMapType
+		}
+		item.WithMapTypes(k, v)
+		//the values take the other declarations of the cell
+		return declareGoType(item, v, t.Elem(), walking)
+	case dt.ArrayType:
+		var subTypes []dt.DType
+		for t.Kind() == reflect.Slice && t != bytesType {
+			sub, ok := goType(t.Elem())
+			if !ok || sub == dt.StructType || sub == dt.MapType {
+				//the items of an array have no declarations
+				return fmt.Errorf("no type for the items of %s", t)
+			}
+			subTypes = append(subTypes, sub)
+			t = t.Elem()
+		}
+		if len(subTypes) == 0 {
+			return dt.ErrCastType
+		}
+		item.WithSubType(subTypes...)
+	}
+	return nil
+}
+
+func structValue(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return rv, ErrNotStruct
+	}
+	return rv, nil
+}
+
+// MarshalRow returns a row of meta with the fields of the struct v, the cells
+// are matched by name. The zero value of a field is stored as it is, a cell
+// that is not a field, or the field of which is a nil pointer, takes the
+// default of the cell, or is NULL if it has none.
+func MarshalRow(meta *dt.RowMeta, v interface{}) (*Row, error) {
+	rv, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := recordOf(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+	r := NewRow(meta)
+	if err := r.WithDefaultValues(); err != nil {
+		return nil, err
+	}
+	for _, field := range rec.fields {
+		item := meta.GetCellMeta(field.name)
+		if nil == item {
+			continue
+		}
+		fv := rv.FieldByIndex(field.index)
+		if fv.Kind() == reflect.Ptr && fv.IsNil() {
+			if nil == item.GetDefaultValue() && item.GetDefaultExpr() == "" {
+				r.SetNull(item)
+			}
+			continue
+		}
+		x, err := goValue(fv)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", field.name, err)
+		}
+		if err := r.SetCellValue(item, x); err != nil {
+			return nil, fmt.Errorf("field %s: %v", field.name, err)
+		}
+	}
+	if nil != rec.key {
+		if item := meta.GetCellMeta(rec.key.name); nil != item && nil != r.GetCellAt(item) {
+			key, err := dt.Cast(r.GetCellAt(item), dt.UInt32Type)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %v", rec.key.name, err)
+			}
+			r.SetKey(key.(uint32))
+		}
+	}
+	return r, nil
+}
+
+// goValue returns a value of fv that dt.TrySetValue takes
+func goValue(fv reflect.Value) (dt.ValueRefer, error) {
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return nil, nil
+		}
+		fv = fv.Elem()
+	}
+	switch fv.Type() {
+	case timeType, uuidType, bytesType:
+		return fv.Interface(), nil
+	case decimalType:
+		d := fv.Interface().(dt.Decimal)
+		return &d, nil
+	}
+	switch fv.Kind() {
+	case reflect.Struct:
+		rec, err := recordOf(fv.Type())
+		if err != nil {
+			return nil, err
+		}
+		m := make(map[string]dt.ValueRefer, len(rec.fields))
+		for _, field := range rec.fields {
+			x, err := goValue(fv.FieldByIndex(field.index))
+			if err != nil {
+				return nil, err
+			}
+			m[field.name] = x
+		}
+		return m, nil
+	case reflect.Map:
+		m := make(map[dt.ValueRefer]dt.ValueRefer, fv.Len())
+		for _, k := range fv.MapKeys() {
+			x, err := goValue(fv.MapIndex(k))
+			if err != nil {
+				return nil, err
+			}
+			m[k.Interface()] = x
+		}
+		return m, nil
+	case reflect.Slice:
+		sub, ok := goType(fv.Type().Elem())
+		if !ok {
+			return nil, dt.ErrCastType
+		}
+		items := make([]dt.DtRefer, fv.Len())
+		for i := range items {
+			x, err := goValue(fv.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			item := dt.NewCellMetaRaw(0, sub, "", "", nil)
+			if err := declareGoType(item, sub, fv.Type().Elem(), nil); err != nil {
+				return nil, err
+			}
+			items[i] = item.NewCell()
+			if err := dt.TrySetValue(items[i], x); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return fv.Interface(), nil
+}
+
+// UnmarshalRow sets the fields of the struct v points to by the cells of r of
+// the same names, a NULL cell sets the zero value
+func UnmarshalRow(r *Row, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return ErrNotStruct
+	}
+	rv, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	rec, err := recordOf(rv.Type())
+	if err != nil {
+		return err
+	}
+	for _, field := range rec.fields {
+		cell, err := r.GetCellByName(field.name)
+		if err != nil {
+			continue
+		}
+		if err := setGoValue(rv.FieldByIndex(field.index), cell); err != nil {
+			return fmt.Errorf("field %s: %v", field.name, err)
+		}
+	}
+	return nil
+}
+
+// castKinds are the types the cells are cast to for the go kinds
+var castKinds = map[reflect.Kind]dt.DType{
+	reflect.Bool: dt.BoolType, reflect.String: dt.StringType,
+	reflect.Int: dt.Int64Type, reflect.Int8: dt.Int64Type, reflect.Int16: dt.Int64Type, reflect.Int32: dt.Int64Type, reflect.Int64: dt.Int64Type,
+	reflect.Uint: dt.UInt64Type, reflect.Uint8: dt.UInt64Type, reflect.Uint16: dt.UInt64Type, reflect.Uint32: dt.UInt64Type, reflect.Uint64: dt.UInt64Type,
+	reflect.Float32: dt.Float64Type, reflect.Float64: dt.Float64Type,
+}
+
+// setGoValue sets fv to the value of cell
+func setGoValue(fv reflect.Value, cell dt.DtRefer) error {
+	if nil == cell {
+		fv.Set(reflect.Zero(fv.Type()))
+		return nil
+	}
+	if fv.Kind() == reflect.Ptr {
+		p := reflect.New(fv.Type().Elem())
+		if err := setGoValue(p.Elem(), cell); err != nil {
+			return err
+		}
+		fv.Set(p)
+		return nil
+	}
+	switch fv.Type() {
+	case timeType:
+		t, err := dt.Cast(cell, dt.TimeType)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	case decimalType:
+		d, err := dt.Cast(cell, dt.DecimalType)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(*d.(*dt.Decimal)))
+		return nil
+	case uuidType:
+		u, ok := cell.(*dt.UUID)
+		if !ok {
+			return dt.ErrCastType
+		}
+		fv.Set(reflect.ValueOf(u.Bytes()))
+		return nil
+	case bytesType:
+		b, ok := cell.GetValue().([]byte)
+		if !ok {
+			return dt.ErrCastType
+		}
+		fv.SetBytes(append([]byte(nil), b...))
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.Struct:
+		s, ok := cell.(*dt.Struct)
+		if !ok {
+			return dt.ErrCastType
+		}
+		rec, err := recordOf(fv.Type())
+		if err != nil {
+			return err
+		}
+		for _, field := range rec.fields {
+			x, err := s.Field(field.name)
+			if err != nil {
+				continue
+			}
+			if err := setGoValue(fv.FieldByIndex(field.index), x); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		m, ok := cell.(*dt.Map)
+		if !ok {
+			return dt.ErrCastType
+		}
+		ret := reflect.MakeMapWithSize(fv.Type(), m.Len())
+		for i, k := range m.Keys() {
+			kv, vv := reflect.New(fv.Type().Key()).Elem(), reflect.New(fv.Type().Elem()).Elem()
+			if err := setGoValue(kv, k); err != nil {
+				return err
+			}
+			if err := setGoValue(vv, m.Values()[i]); err != nil {
+				return err
+			}
+			ret.SetMapIndex(kv, vv)
+		}
+		fv.Set(ret)
+		return nil
+	case reflect.Slice:
+		a, ok := cell.(*dt.Array)
+		if !ok {
+			return dt.ErrCastType
+		}
+		items := a.GetValue().([]dt.DtRefer)
+		ret := reflect.MakeSlice(fv.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setGoValue(ret.Index(i), item); err != nil {
+				return err
+			}
+		}
+		fv.Set(ret)
+		return nil
+	}
+	typ, ok := castKinds[fv.Kind()]
+	if !ok {
+		return dt.ErrCastType
+	}
+	x, err := dt.Cast(cell, typ)
+	if err != nil {
+		return err
+	}
+	xv := reflect.ValueOf(x)
+	switch {
+	case fv.Kind() >= reflect.Int && fv.Kind() <= reflect.Int64 && fv.OverflowInt(xv.Int()),
+		fv.Kind() >= reflect.Uint && fv.Kind() <= reflect.Uint64 && fv.OverflowUint(xv.Uint()),
+		fv.Kind() == reflect.Float32 && fv.OverflowFloat(xv.Float()):
+		return dt.ErrCastType
+	}
+	fv.Set(xv.Convert(fv.Type()))
+	return nil
+}
+
+// InsertStruct inserts the row of the struct v, see MarshalRow
+func (tree *PageTree) InsertStruct(v interface{}) error {
+	r, err := MarshalRow(tree.meta, v)
+	if err != nil {
+		return err
+	}
+	return tree.Insert(r)
+}
+
+// GetStruct sets the struct v points to by the row of key, see UnmarshalRow
+func (tree *PageTree) GetStruct(key uint32, v interface{}) (bool, error) {
+	r, ok := tree.Get(key)
+	if !ok {
+		return false, nil
+	}
+	return true, UnmarshalRow(r, v)
+}
@@ -0,0 +1,177 @@
+package yard
+
+import (
+	"github.com/lycying/pitydb/dt"
+	"testing"
+	"time"
+)
+
+type testAddress struct {
+	City string `pity:"city"`
+	Zip  *string
+}
+
+type testOrder struct {
+	ID      uint32           `pity:"id,,,key"`
+	Name    string           `pity:"name,,,notnull,collate=binary"`
+	Status  string           `pity:"status,enum,new,labels=new|paid"`
+	Price   string           `pity:"price,decimal,0.00,scale=2"`
+	Qty     int              `pity:"qty"`
+	At      time.Time        `pity:"at"`
+	Ref     [16]byte         `pity:"ref"`
+	Tags    []string         `pity:"tags"`
+	Extra   map[string]int32 `pity:"extra"`
+	ShipTo  testAddress      `pity:"ship_to"`
+	Note    *string          `pity:"note"`
+	Secret  string           `pity:"-"`
+	private int
+}
+
+type testNode struct {
+	ID       uint32
+	Parent   *testNode
+	Children map[string]testNode
+}
+
+func TestStructMeta(t *testing.T) {
+	meta, err := StructMeta(&testOrder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := StructMeta(testOrder{}); again != meta {
+		t.Fatal("the meta of a type should be the same")
+	}
+	if meta.GetCellSize() != 11 || nil != meta.GetCellMeta("Secret") || nil != meta.GetCellMeta("private") {
+		t.Fatal("the exported fields should be the cells")
+	}
+	cases := []struct {
+		name string
+		typ  dt.DType
+	}{
+		{"id", dt.UInt32Type}, {"status", dt.EnumType}, {"price", dt.DecimalType}, {"qty", dt.Int64Type},
+		{"at", dt.TimeType}, {"ref", dt.UUIDType}, {"tags", dt.ArrayType}, {"extra", dt.MapType},
+		{"ship_to", dt.StructType}, {"note", dt.StringType},
+	}
+	for _, c := range cases {
+		if item := meta.GetCellMeta(c.name); nil == item || item.GetMType() != c.typ {
+			t.Fatal(c.name, " should be ", c.typ)
+		}
+	}
+	name := meta.GetCellMeta("name")
+	if !name.IsNotNull() || name.GetCollation() != dt.CollateBinary {
+		t.Fatal("the options should be declared")
+	}
+	if _, scale := meta.GetCellMeta("price").GetDecimal(); scale != 2 || meta.GetCellMeta("price").GetDefaultValue() != "0.00" {
+		t.Fatal("price should have a scale and a default")
+	}
+	if nil == meta.GetCellMeta("ship_to").GetStructMeta().GetCellMeta("Zip") {
+		t.Fatal("the struct should be declared by its fields")
+	}
+
+	bad := []interface{}{
+		1,
+		struct {
+			A int8
+		}{},
+		struct {
+			A string `pity:"a,nope"`
+		}{},
+		struct {
+			A string `pity:"a,,,wat"`
+		}{},
+		struct {
+			A int32 `pity:"a,,x"`
+		}{},
+		struct {
+			A []testAddress
+		}{},
+		testNode{},
+	}
+	for _, v := range bad {
+		if _, err := StructMeta(v); err == nil {
+			t.Fatalf("%T should have no meta", v)
+		}
+	}
+}
+
+func TestPageTree_InsertStruct(t *testing.T) {
+	meta, _ := StructMeta(testOrder{})
+	tree := NewPageTree(meta, nil)
+	zip := "75001"
+	note := "fragile"
+	in := &testOrder{
+		ID:     7,
+		Name:   "Pity",
+		Status: "paid",
+		Price:  "0.00",
+		Qty:    3,
+		At:     time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC),
+		Ref:    [16]byte{1, 2, 3},
+		Tags:   []string{"a", "b"},
+		Extra:  map[string]int32{"x": 1},
+		ShipTo: testAddress{City: "Paris", Zip: &zip},
+		Note:   &note,
+		Secret: "s",
+	}
+	if err := tree.InsertStruct(in); err != nil {
+		t.Fatal(err)
+	}
+	out := &testOrder{}
+	if ok, err := tree.GetStruct(7, out); !ok || err != nil {
+		t.Fatal("the row should be found", err)
+	}
+	if out.Name != "Pity" || out.Status != "paid" || out.Price != "0.00" || out.Qty != 3 || !out.At.Equal(in.At) ||
+		out.Ref != in.Ref || len(out.Tags) != 2 || out.Tags[1] != "b" || out.Extra["x"] != 1 ||
+		out.ShipTo.City != "Paris" || *out.ShipTo.Zip != "75001" || *out.Note != "fragile" || out.Secret != "" {
+		t.Fatalf("the struct should be read back, %+v", out)
+	}
+	if ok, _ := tree.GetStruct(8, out); ok {
+		t.Fatal("there is no row 8")
+	}
+
+	r, err := MarshalRow(meta, &testOrder{ID: 8, Name: "x", Status: "new", Price: "1.25", Note: &note})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.GetKey() != 8 || r.GetCellAt(meta.GetCellMeta("price")).(*dt.Decimal).String() != "1.25" {
+		t.Fatal("the key and the cells should be set")
+	}
+	if err := UnmarshalRow(r, testOrder{}); err != ErrNotStruct {
+		t.Fatal("a struct should not be set by value")
+	}
+	if _, err := MarshalRow(meta, &testOrder{Status: "lost"}); err == nil {
+		t.Fatal("a wrong label should fail")
+	}
+
+	//the zero value is stored, a nil pointer takes the default
+	type flags struct {
+		ID     uint32 `pity:"id"`
+		Active bool   `pity:"active,bool,true"`
+		Level  *int32 `pity:"level,int32,5"`
+		Note   *string
+	}
+	flagsMeta, _ := StructMeta(flags{})
+	fr, err := MarshalRow(flagsMeta, &flags{ID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back flags
+	if err := UnmarshalRow(fr, &back); err != nil || back.Active || nil == back.Level || *back.Level != 5 || nil != back.Note {
+		t.Fatal("false should be kept and the nil level should be 5", back, err)
+	}
+
+	//a NULL cell is the zero value
+	r.SetNull(meta.GetCellMeta("note"))
+	out.Note = &note
+	if err := UnmarshalRow(r, out); err != nil || nil != out.Note {
+		t.Fatal("note should be nil", err)
+	}
+	//a field that can not hold the value
+	var small struct {
+		Qty byte `pity:"qty"`
+	}
+	r.SetCellValue(meta.GetCellMeta("qty"), 300)
+	if err := UnmarshalRow(r, &small); err == nil {
+		t.Fatal("300 should not be a byte")
+	}
+}
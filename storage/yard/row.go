@@ -2,9 +2,12 @@ package yard
 
 import (
 	"bytes"
+	"errors"
 	"github.com/lycying/pitydb/dt"
 )
 
+var ErrNullCell = errors.New("a NULL cell can not be saved")
+
 type RowRefer interface {
 	dt.Encoder
 	dt.DeCoder
@@ -27,10 +30,16 @@ func NewRow(meta *dt.RowMeta) *Row {
 	}
 }
 
-func (r *Row) WithDefaultValues() {
+// WithDefaultValues sets every cell to its default value. A cell of a default
+// expression is NULL, the expression is evaluated by PageTree.Insert after the
+// other cells are set, see dt.CellMeta.WithDefaultExpr
+func (r *Row) WithDefaultValues() error {
 	idx := int(0)
 	data := make([]dt.DtRefer, r.meta.GetCellSize())
 	for i, item := range r.meta.GetItems() {
+		if item.GetDefaultExpr() != "" {
+			continue
+		}
 		cell := item.NewCell()
 		if nil != item.GetDefaultValue() {
 			cell.SetValue(item.GetDefaultValue())
@@ -39,12 +48,17 @@ func (r *Row) WithDefaultValues() {
 		idx += cell.GetLen()
 	}
 	r.cells = data
+	return nil
 }
 
+// Encode returns ErrNullCell if a cell is NULL, the rows have no room for NULL yet
 func (r *Row) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 
 	for _, item := range r.cells {
+		if nil == item {
+			return nil, ErrNullCell
+		}
 		b, _ := item.Encode()
 		buf.Write(b)
 	}
@@ -73,6 +87,27 @@ func (r *Row) GetLen() int {
 	return cl
 }
 
+// NewRowWithCells makes a row of the cells by position, a nil cell is NULL.
+// The default values are not used.
+func NewRowWithCells(meta *dt.RowMeta, key uint32, cells []dt.DtRefer) *Row {
+	r := NewRow(meta)
+	r.SetKey(key)
+	r.cells = cells
+	return r
+}
+
+// GetCellByPos returns nil if the cell is NULL or the row has no such cell
+func (r *Row) GetCellByPos(pos int) dt.DtRefer {
+	if pos < 0 || pos >= len(r.cells) {
+		return nil
+	}
+	return r.cells[pos]
+}
+
+func (r *Row) GetMeta() *dt.RowMeta {
+	return r.meta
+}
+
 func (r *Row) GetCellAt(meta *dt.CellMeta) dt.DtRefer {
 	return r.cells[meta.GetPos()]
 }
@@ -87,6 +122,24 @@ func (r *Row) SetCellValueForTest(meta *dt.CellMeta, value dt.ValueRefer) {
 	}
 }
 
+// SetCellValue casts value to the type of the cell before it is set, see dt.TrySetValue
+func (r *Row) SetCellValue(meta *dt.CellMeta, value dt.ValueRefer) error {
+	cell := r.GetCellAt(meta)
+	if nil == cell {
+		cell = meta.NewCell()
+	}
+	if err := dt.TrySetValue(cell, value); err != nil {
+		return err
+	}
+	r.cells[meta.GetPos()] = cell
+	return nil
+}
+
+// SetNull makes the cell NULL, the tree fills a NULL cell that has a default expression
+func (r *Row) SetNull(meta *dt.CellMeta) {
+	r.cells[meta.GetPos()] = nil
+}
+
 func (r *Row) SetKey(key uint32) {
 	r.key.SetValue(key)
 }
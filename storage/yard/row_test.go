@@ -35,3 +35,23 @@ func TestNewRow(t *testing.T) {
 	}
 
 }
+
+func TestRow_SetCellValue(t *testing.T) {
+	rowMeta := dt.NewRowMeta()
+	slot0 := dt.NewCellMetaRaw(0, dt.UInt32Type, "id", "the auto incrementID", nil)
+	slot1 := dt.NewCellMetaRaw(1, dt.Int32Type, "age", "an int32", int32(0))
+	rowMeta.AddCellMeta(slot0)
+	rowMeta.AddCellMeta(slot1)
+
+	row := NewRow(rowMeta)
+	row.WithDefaultValues()
+	if err := row.SetCellValue(slot1, 42); err != nil || row.GetCellAt(slot1).GetValue().(int32) != 42 {
+		t.Fatal("an int should be cast to int32, ", err)
+	}
+	if err := row.SetCellValue(slot1, int64(1)<<40); err != dt.ErrCastOverflow {
+		t.Fatal("1<<40 should overflow int32, ", err)
+	}
+	if row.GetCellAt(slot1).GetValue().(int32) != 42 {
+		t.Fatal("a failed cast should not change the cell")
+	}
+}
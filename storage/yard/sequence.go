@@ -0,0 +1,226 @@
+package yard
+
+import (
+	"bytes"
+	"errors"
+	"github.com/lycying/pitydb/dt"
+	"math"
+	"sync"
+)
+
+var ErrSequenceExists = errors.New("sequence already exists")
+var ErrSequenceExhausted = errors.New("sequence is exhausted")
+var ErrSequenceStep = errors.New("the step of a sequence can not be 0")
+
+// SequenceOptions are the settings of a sequence
+type SequenceOptions struct {
+	Start int64
+	Step  int64  //a negative step counts down
+	Cache uint32 //the values reserved by one write of the catalog
+}
+
+func DefaultSequenceOptions() *SequenceOptions {
+	return &SequenceOptions{
+		Start: 1,
+		Step:  1,
+		Cache: 20,
+	}
+}
+
+// Sequence hands out unique values. The catalog saves the end of the values
+// reserved before any of them is used, so a restart goes on from the end of
+// the reservation and may skip values but never hands one out twice.
+type Sequence struct {
+	lock sync.Mutex //one NextValue at a time
+	cat  *Catalog
+
+	name  string
+	start int64
+	step  int64
+	cache uint32
+
+	next     int64 //the next value to hand out
+	reserved int64 //the values from next up to here are saved as used, written under cat.lock
+}
+
+// NextValue returns the next value of the sequence, it implements dt.Sequence
+func (seq *Sequence) NextValue() (int64, error) {
+	seq.lock.Lock()
+	defer seq.lock.Unlock()
+
+	if seq.next == seq.reserved {
+		reserved, ok := addSteps(seq.next, seq.step, int64(seq.cache))
+		if !ok {
+			//reserve what is left before the end of int64
+			reserved, ok = addSteps(seq.next, seq.step, 1)
+			if !ok {
+				return 0, ErrSequenceExhausted
+			}
+		}
+		if err := seq.cat.reserve(seq, reserved); err != nil {
+			return 0, err
+		}
+	}
+	v := seq.next
+	seq.next += seq.step
+	return v, nil
+}
+
+func (seq *Sequence) GetName() string {
+	return seq.name
+}
+
+// addSteps returns v + step*n if it does not overflow
+func addSteps(v int64, step int64, n int64) (int64, bool) {
+	if n > 0 && (step > math.MaxInt64/n || step < math.MinInt64/n) {
+		return 0, false
+	}
+	d := step * n
+	if (d > 0 && v > math.MaxInt64-d) || (d < 0 && v < math.MinInt64-d) {
+		return 0, false
+	}
+	return v + d, true
+}
+
+// CreateSequence adds a sequence to the catalog, nil opts takes the defaults
+func (cat *Catalog) CreateSequence(name string, opts *SequenceOptions) (*Sequence, error) {
+	if nil == opts {
+		opts = DefaultSequenceOptions()
+	}
+	if opts.Step == 0 {
+		return nil, ErrSequenceStep
+	}
+	cache := opts.Cache
+	if cache == 0 {
+		cache = 1
+	}
+	seq := &Sequence{
+		cat:      cat,
+		name:     name,
+		start:    opts.Start,
+		step:     opts.Step,
+		cache:    cache,
+		next:     opts.Start,
+		reserved: opts.Start,
+	}
+
+	cat.lock.Lock()
+	if _, ok := cat.sequences[name]; ok {
+		cat.lock.Unlock()
+		return nil, ErrSequenceExists
+	}
+	cat.sequences[name] = seq
+	cat.lock.Unlock()
+
+	if err := cat.sync(); err != nil {
+		cat.lock.Lock()
+		delete(cat.sequences, name)
+		cat.lock.Unlock()
+		return nil, err
+	}
+	return seq, nil
+}
+
+// GetSequence returns nil if there is no sequence of the name
+func (cat *Catalog) GetSequence(name string) *Sequence {
+	cat.lock.RLock()
+	defer cat.lock.RUnlock()
+	return cat.sequences[name]
+}
+
+// LookupSequence implements dt.SequenceSource for nextval of the default expressions
+func (cat *Catalog) LookupSequence(name string) dt.Sequence {
+	if seq := cat.GetSequence(name); nil != seq {
+		return seq
+	}
+	//not a nil *Sequence in the interface
+	return nil
+}
+
+func (cat *Catalog) DropSequence(name string) error {
+	cat.lock.Lock()
+	delete(cat.sequences, name)
+	cat.lock.Unlock()
+	return cat.sync()
+}
+
+// reserve saves reserved as the end of the values used by seq,
+// the values are only handed out after the catalog is written
+func (cat *Catalog) reserve(seq *Sequence, reserved int64) error {
+	cat.lock.Lock()
+	old := seq.reserved
+	seq.reserved = reserved
+	cat.lock.Unlock()
+
+	if err := cat.sync(); err != nil {
+		cat.lock.Lock()
+		seq.reserved = old
+		cat.lock.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (seq *Sequence) encode(buf *bytes.Buffer) {
+	writeDt(buf, dt.ValidNewString(seq.name))
+	writeDt(buf, dt.ValidInt64(seq.start))
+	writeDt(buf, dt.ValidInt64(seq.step))
+	writeDt(buf, dt.ValidNewUInt32(seq.cache))
+	writeDt(buf, dt.ValidInt64(seq.reserved))
+}
+
+func (seq *Sequence) decode(buf []byte, offset int) int {
+	name, start, step, cache, reserved := dt.NewString(), dt.NewInt64(), dt.NewInt64(), dt.NewUInt32(), dt.NewInt64()
+	idx := offset
+	idx = readDt(buf, idx, name)
+	idx = readDt(buf, idx, start)
+	idx = readDt(buf, idx, step)
+	idx = readDt(buf, idx, cache)
+	idx = readDt(buf, idx, reserved)
+
+	seq.name = name.GetValue().(string)
+	seq.start = start.GetValue().(int64)
+	seq.step = step.GetValue().(int64)
+	seq.cache = cache.GetValue().(uint32)
+	seq.reserved = reserved.GetValue().(int64)
+	//the values before the reservation may have been used before a crash
+	seq.next = seq.reserved
+	return idx - offset
+}
+
+// fillAutoIncrement sets the auto-increment cells that are not set yet, a cell is
+// not set if it is nil or 0. A row without a key takes the value of the cell 0.
+func (r *Row) fillAutoIncrement() error {
+	for _, item := range r.meta.GetItems() {
+		seq := item.GetAutoIncrement()
+		if nil == seq {
+			continue
+		}
+		pos := item.GetPos()
+		if pos >= len(r.cells) || (nil != r.cells[pos] && !isZero(r.cells[pos])) {
+			continue
+		}
+		v, err := seq.NextValue()
+		if err != nil {
+			return err
+		}
+		cell := item.NewCell()
+		if err := dt.TrySetValue(cell, v); err != nil {
+			return err
+		}
+		r.cells[pos] = cell
+		if pos == 0 && r.GetKey() == 0 {
+			key, err := dt.Cast(v, dt.UInt32Type)
+			if err != nil {
+				return err
+			}
+			r.SetKey(key.(uint32))
+		}
+	}
+	return nil
+}
+
+func isZero(cell dt.DtRefer) bool {
+	c, err := dt.CompareValues(cell, dt.ValidInt64(0))
+	return err == nil && c == 0
+}
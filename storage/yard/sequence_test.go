@@ -0,0 +1,111 @@
+package yard
+
+import (
+	"github.com/lycying/pitydb/dt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestSequence_NextValue(t *testing.T) {
+	cat := NewCatalog()
+	seq, err := cat.CreateSequence("desc", &SequenceOptions{Start: 10, Step: -3, Cache: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []int64{10, 7, 4, 1, -2} {
+		if v, _ := seq.NextValue(); v != want {
+			t.Fatal("the value should be ", want, ",but ", v)
+		}
+	}
+	if _, err := cat.CreateSequence("desc", nil); err != ErrSequenceExists {
+		t.Fatal("the name is used")
+	}
+	if _, err := cat.CreateSequence("zero", &SequenceOptions{Step: 0}); err != ErrSequenceStep {
+		t.Fatal("the step can not be 0")
+	}
+
+	last, _ := cat.CreateSequence("last", &SequenceOptions{Start: math.MaxInt64 - 1, Step: 1, Cache: 10})
+	if v, err := last.NextValue(); err != nil || v != math.MaxInt64-1 {
+		t.Fatal("the value before the end should be handed out, ", v, err)
+	}
+	if _, err := last.NextValue(); err != ErrSequenceExhausted {
+		t.Fatal("the sequence should be exhausted, ", err)
+	}
+}
+
+func TestSequence_Restart(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pitydb")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "catalog")
+
+	cat, err := OpenCatalog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, _ := cat.CreateSequence("ids", &SequenceOptions{Start: 1, Step: 1, Cache: 10})
+	used := make(map[int64]bool)
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				v, _ := seq.NextValue()
+				lock.Lock()
+				used[v] = true
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(used) != 100 {
+		t.Fatal("the values should be unique, but ", len(used))
+	}
+
+	//a crash loses the cached values but never hands them out again
+	cat2, err := OpenCatalog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _ := cat2.GetSequence("ids").NextValue()
+	if used[v] || v < 100 {
+		t.Fatal("the value after the restart should be new, but ", v)
+	}
+}
+
+func TestSequence_AutoIncrement(t *testing.T) {
+	cat := NewCatalog()
+	seq, _ := cat.CreateSequence("ids", nil)
+
+	rowMeta := dt.NewRowMeta()
+	slot0 := dt.NewCellMetaRaw(0, dt.UInt32Type, "id", "the auto incrementID", nil).WithAutoIncrement(seq)
+	slot1 := dt.NewCellMetaRaw(1, dt.StringType, "name", "a name", "")
+	rowMeta.AddCellMeta(slot0)
+	rowMeta.AddCellMeta(slot1)
+	tree := NewPageTree(rowMeta, nil)
+
+	for i := 0; i < 3; i++ {
+		r := NewRow(rowMeta)
+		r.WithDefaultValues()
+		if err := tree.Insert(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := NewRow(rowMeta)
+	r.WithDefaultValues()
+	r.SetCellValue(slot0, 100)
+	r.SetKey(100)
+	tree.Insert(r)
+
+	for _, key := range []uint32{1, 2, 3, 100} {
+		found, ok := tree.Get(key)
+		if !ok || found.GetCellAt(slot0).GetValue().(uint32) != key {
+			t.Fatal("the row ", key, " should be inserted with its id")
+		}
+	}
+}
@@ -3,6 +3,8 @@ package yard
 import (
 	"github.com/lycying/pitydb/dt"
 	"os"
+	"sync"
+	"time"
 )
 
 type PageTree struct {
@@ -10,6 +12,17 @@ type PageTree struct {
 	meta *dt.RowMeta
 	link *os.File
 	mgr  *PageManagement
+
+	lock      sync.RWMutex
+	flushLock sync.Mutex //only one writer of the linked file at a time
+	changes   []change   //the writes while a Compactor runs, nil if none runs
+
+	wal       *Wal
+	lsn       uint64 //the lsn of the last write
+	backupLSN uint64 //the lsn of the last backup, the wal is kept after it for a restore
+
+	cons *constraints //built by the first write, see getConstraints
+	lobs dt.LobStore  //the large objects of the rows, see AttachLobStore
 }
 
 func NewPageTree(meta *dt.RowMeta, link *os.File) *PageTree {
@@ -33,8 +46,15 @@ func (tree *PageTree) NewDataPage(level uint32) *Page {
 	return tree.NewPage(level, dataPageType)
 }
 func (tree *PageTree) NewPage(lvl uint32, typ byte) *Page {
+	pg := tree.allocPage(tree.mgr.NextPageID(), lvl, typ)
+	tree.mgr.AddPage(pg)
+	return pg
+}
+
+// allocPage creates a page that is not added to any PageManagement yet
+func (tree *PageTree) allocPage(id uint32, lvl uint32, typ byte) *Page {
 	pgID := dt.NewUInt32()
-	pgID.SetValue(tree.mgr.NextPageID())
+	pgID.SetValue(id)
 	pgType := dt.NewByte()
 	pgType.SetValue(typ)
 	level := dt.NewUInt32()
@@ -47,6 +67,8 @@ func (tree *PageTree) NewPage(lvl uint32, typ byte) *Page {
 	checksum.SetValue(uint32(0)) //TODO
 	lastModify := dt.NewUInt64()
 	lastModify.SetValue(uint64(0))
+	lsn := dt.NewUInt64()
+	lsn.SetValue(uint64(0))
 	size := dt.NewUInt32()
 	size.SetValue(uint32(0))
 	pg := &Page{
@@ -58,6 +80,7 @@ func (tree *PageTree) NewPage(lvl uint32, typ byte) *Page {
 			right:      right,
 			checksum:   checksum,
 			lastModify: lastModify,
+			lsn:        lsn,
 			size:       size,
 		},
 		_len:   0,
@@ -65,29 +88,156 @@ func (tree *PageTree) NewPage(lvl uint32, typ byte) *Page {
 		parent: nil,
 		rows:   []*Row{},
 	}
-	tree.mgr.AddPage(pg)
 	return pg
 }
 
-func (tree *PageTree) Insert(r *Row) {
+// AttachWal replays the writes of w that are newer than the pages, then
+// makes the tree save every write to w before it is applied
+func (tree *PageTree) AttachWal(w *Wal) error {
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+	records, err := w.readAfter(tree.lsn)
+	if err != nil {
+		return err
+	}
+	for _, rec := range records {
+		tree.replay(rec)
+	}
+	tree.wal = w
+	if w.LastLSN() > tree.lsn {
+		tree.lsn = w.LastLSN()
+	}
+	return nil
+}
+
+// LSN returns the log sequence number of the last write
+func (tree *PageTree) LSN() uint64 {
+	tree.lock.RLock()
+	defer tree.lock.RUnlock()
+	return tree.lsn
+}
+
+func (tree *PageTree) Insert(r *Row) error {
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+
+	if err := r.fillAutoIncrement(); err != nil {
+		return err
+	}
+	if err := r.evalDefaults(); err != nil {
+		return err
+	}
+	cons, err := tree.getConstraints()
+	if err != nil {
+		return err
+	}
+	if err := cons.check(tree, r); err != nil {
+		return err
+	}
+	row, err := r.Encode()
+	if err != nil {
+		return err
+	}
+	if err := tree.log(&walRecord{op: walInsert, key: r.GetKey(), row: row}); err != nil {
+		return err
+	}
+	tree.insert(r)
+	return nil
+}
+
+func (tree *PageTree) insert(r *Row) {
 	key := r.GetKey()
+	if nil != tree.changes {
+		tree.changes = append(tree.changes, change{key: key, row: r})
+	}
 
 	node, idx, find := tree.root.findOne(key)
+	if nil != tree.cons {
+		var old *Row
+		if find {
+			old = node.rows[idx]
+		}
+		tree.cons.replace(old, r)
+	}
+	if find {
+		tree.dropLobs(node.rows[idx], r)
+	}
 
 	//the row is so big that one default can not hold it
 	if r.GetLen() > DefaultPageSize {
 		//TODO big row storage
 	}
 	node.insert(r, idx, find)
+}
+
+// deleteAt deletes the row at idx of node, it is found by findOne
+func (tree *PageTree) deleteAt(node *Page, key uint32, idx int) {
+	if nil != tree.changes {
+		tree.changes = append(tree.changes, change{key: key})
+	}
+	if nil != tree.cons {
+		tree.cons.replace(node.rows[idx], nil)
+	}
+	tree.dropLobs(node.rows[idx], nil)
+	node.delete(key, idx)
+}
+
+func (tree *PageTree) Delete(key uint32) (bool, error) {
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+
+	node, idx, find := tree.root.findOne(key)
+	if !find {
+		return false, nil
+	}
+	if err := tree.log(&walRecord{op: walDelete, key: key}); err != nil {
+		return false, err
+	}
+	tree.deleteAt(node, key, idx)
+	return true, nil
+}
+
+// log saves the change to the wal and moves the lsn of the tree on
+func (tree *PageTree) log(rec *walRecord) error {
+	rec.lsn = tree.lsn + 1
+	rec.ts = uint64(time.Now().UnixNano())
+	if nil != tree.wal {
+		if err := tree.wal.append(rec); err != nil {
+			return err
+		}
+	}
+	tree.lsn = rec.lsn
+	return nil
+}
 
+// replay applies a change read from the wal without saving it again
+func (tree *PageTree) replay(rec *walRecord) {
+	tree.lsn = rec.lsn
+	switch rec.op {
+	case walInsert:
+		row := NewRow(tree.meta)
+		row.Decode(rec.row, 0)
+		row.SetKey(rec.key)
+		tree.insert(row)
+	case walDelete:
+		node, idx, find := tree.root.findOne(rec.key)
+		if find {
+			tree.deleteAt(node, rec.key, idx)
+		}
+	}
 }
-func (tree *PageTree) Delete(key uint32) bool {
+
+// Get returns the row of the key, readers never wait for each other.
+// An expired row is not returned even if the sweeper has not deleted it yet
+func (tree *PageTree) Get(key uint32) (*Row, bool) {
+	tree.lock.RLock()
+	defer tree.lock.RUnlock()
+
 	node, idx, find := tree.root.findOne(key)
-	if find {
-		node.delete(key, idx)
-		return true
+	if !find || node.rows[idx].expired(time.Now().UnixNano()) {
+		return nil, false
 	}
-	return false
+	return node.rows[idx], true
 }
 
 func (tree *PageTree) GetRoot() *Page {
@@ -100,6 +250,31 @@ func (tree *PageTree) Dump() {
 	println("END")
 	println("")
 }
+
+// walk visits the pages of the tree in depth-first order,
+// it stops as soon as fn returns false
+func (tree *PageTree) walk(fn func(pg *Page) bool) {
+	_walk(tree.mgr, tree.root, fn)
+}
+
+func _walk(mgr *PageManagement, pg *Page, fn func(pg *Page) bool) bool {
+	if nil == pg {
+		return true
+	}
+	if !fn(pg) {
+		return false
+	}
+	if pg.isIndexPage() {
+		for _, x := range pg.rows {
+			px := mgr.GetPage(x.cells[0].GetValue().(uint32))
+			if !_walk(mgr, px, fn) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 func _getParentID(pg *Page) uint32 {
 	if pg.parent == nil {
 		return 0
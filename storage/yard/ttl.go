@@ -0,0 +1,213 @@
+package yard
+
+import (
+	"github.com/lycying/pitydb/dt"
+	"sync"
+	"time"
+)
+
+// expireAt returns the time the row expires in time.Now().UnixNano()
+func (r *Row) expireAt() (int64, bool) {
+	cell, ttl := r.meta.GetTTL()
+	if nil == cell || cell.GetPos() >= len(r.cells) || nil == r.cells[cell.GetPos()] {
+		return 0, false
+	}
+	var at int64
+	switch v := r.GetCellAt(cell).GetValue().(type) {
+	case int64:
+		at = v
+	case uint64:
+		at = int64(v)
+	case time.Time:
+		at = v.UnixNano()
+	default:
+		return 0, false
+	}
+	return at + int64(ttl), true
+}
+
+func (r *Row) expired(now int64) bool {
+	at, ok := r.expireAt()
+	return ok && at <= now
+}
+
+// Scan visits the live rows in key order until fn returns false,
+// fn runs under the read lock of the tree so it must not write the tree
+func (tree *PageTree) Scan(fn func(r *Row) bool) {
+	tree.lock.RLock()
+	defer tree.lock.RUnlock()
+
+	now := time.Now().UnixNano()
+	tree.walk(func(pg *Page) bool {
+		if pg.isIndexPage() {
+			return true
+		}
+		for _, r := range pg.rows {
+			if r.expired(now) {
+				continue
+			}
+			if !fn(r) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// deleteExpired deletes the row only if it is still expired,
+// it may be replaced by a new row after the sweeper found it
+func (tree *PageTree) deleteExpired(key uint32, now int64) (bool, error) {
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+
+	node, idx, find := tree.root.findOne(key)
+	if !find || !node.rows[idx].expired(now) {
+		return false, nil
+	}
+	if err := tree.log(&walRecord{op: walDelete, key: key}); err != nil {
+		return false, err
+	}
+	tree.deleteAt(node, key, idx)
+	return true, nil
+}
+
+// SweepOptions controls the background removal of the expired rows
+type SweepOptions struct {
+	RowsPerSecond int           //0 means no limit
+	Interval      time.Duration //the pause between two passes over the tree
+}
+
+func DefaultSweepOptions() *SweepOptions {
+	return &SweepOptions{
+		RowsPerSecond: 1000,
+		Interval:      time.Minute,
+	}
+}
+
+// Sweeper walks the leaves of a tree and deletes the expired rows.
+// The expired rows are already invisible to Get and Scan, the sweeper
+// only gives their space back.
+type Sweeper struct {
+	tree *PageTree
+	opts *SweepOptions
+
+	swept *dt.UInt64
+
+	lock    sync.Mutex
+	stop    chan struct{} //nil if the sweeper is not started
+	stopped chan struct{}
+
+	errLock sync.Mutex
+	err     error //the error of the last pass of Start
+}
+
+func NewSweeper(tree *PageTree, opts *SweepOptions) *Sweeper {
+	if nil == opts {
+		opts = DefaultSweepOptions()
+	}
+	return &Sweeper{
+		tree:  tree,
+		opts:  opts,
+		swept: dt.NewUInt64(),
+	}
+}
+
+// Start sweeps the tree every Interval until Stop is called, it does nothing
+// if the sweeper is already started
+func (s *Sweeper) Start() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if nil != s.stop {
+		return
+	}
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	s.stop, s.stopped = stop, stopped
+	go func() {
+		defer close(stopped)
+		for {
+			_, err := s.sweep(stop)
+			s.errLock.Lock()
+			s.err = err
+			s.errLock.Unlock()
+			select {
+			case <-stop:
+				return
+			case <-time.After(s.opts.Interval):
+			}
+		}
+	}()
+}
+
+// Stop waits for the pass in progress to stop, it does nothing if the
+// sweeper is not started
+func (s *Sweeper) Stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if nil == s.stop {
+		return
+	}
+	close(s.stop)
+	<-s.stopped
+	s.stop, s.stopped = nil, nil
+}
+
+// Err returns the error of the last pass started by Start, nil if it is done
+// without one. A pass that fails, like on a write of the wal, is tried again
+// after Interval.
+func (s *Sweeper) Err() error {
+	s.errLock.Lock()
+	defer s.errLock.Unlock()
+	return s.err
+}
+
+// Swept returns the rows deleted by the sweeper
+func (s *Sweeper) Swept() uint64 {
+	return s.swept.GetValue().(uint64)
+}
+
+// SweepOnce makes one pass over the tree and returns the rows deleted
+func (s *Sweeper) SweepOnce() (int, error) {
+	return s.sweep(nil)
+}
+
+// sweep makes one pass over the tree, it returns early when stop is closed
+func (s *Sweeper) sweep(stop chan struct{}) (int, error) {
+	now := time.Now().UnixNano()
+	keys := make([]uint32, 0)
+
+	s.tree.lock.RLock()
+	s.tree.walk(func(pg *Page) bool {
+		if pg.isDataPage() {
+			for _, r := range pg.rows {
+				if r.expired(now) {
+					keys = append(keys, r.GetKey())
+				}
+			}
+		}
+		return true
+	})
+	s.tree.lock.RUnlock()
+
+	count := 0
+	for _, key := range keys {
+		if nil != stop {
+			select {
+			case <-stop:
+				return count, nil
+			default:
+			}
+		}
+		deleted, err := s.tree.deleteExpired(key, now)
+		if err != nil {
+			return count, err
+		}
+		if deleted {
+			count++
+			s.swept.IncrementAndGet()
+			if s.opts.RowsPerSecond > 0 {
+				time.Sleep(time.Second / time.Duration(s.opts.RowsPerSecond))
+			}
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,130 @@
+package yard
+
+import (
+	"github.com/lycying/pitydb/dt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTTLTree(ttl time.Duration) (*PageTree, *dt.CellMeta) {
+	rowMeta := dt.NewRowMeta()
+	slot0 := dt.NewCellMetaRaw(0, dt.UInt32Type, "id", "the auto incrementID", nil)
+	slot1 := dt.NewCellMetaRaw(1, dt.Int64Type, "touched", "the session is touched at", int64(0))
+	rowMeta.AddCellMeta(slot0)
+	rowMeta.AddCellMeta(slot1)
+	rowMeta.SetTTL(slot1, ttl)
+	return NewPageTree(rowMeta, nil), slot1
+}
+
+func TestSweeper_SweepOnce(t *testing.T) {
+	tree, touched := newTTLTree(time.Hour)
+	now := time.Now()
+	for i := 1; i <= 300; i++ {
+		r := NewRow(tree.meta)
+		r.WithDefaultValues()
+		r.SetKey(uint32(i))
+		if i%3 == 0 {
+			r.SetCellValueForTest(touched, now.Add(-2*time.Hour).UnixNano())
+		} else {
+			r.SetCellValueForTest(touched, now.UnixNano())
+		}
+		tree.Insert(r)
+	}
+
+	if _, found := tree.Get(3); found {
+		t.Fatal("an expired row should be invisible")
+	}
+	if _, found := tree.Get(4); !found {
+		t.Fatal("a live row should be visible")
+	}
+	live := 0
+	tree.Scan(func(r *Row) bool {
+		live++
+		return true
+	})
+	if live != 200 || tree.Analyze(nil).Rows != 200 {
+		t.Fatal("only the live rows should be scanned, but ", live)
+	}
+
+	s := NewSweeper(tree, &SweepOptions{RowsPerSecond: 0})
+	count, err := s.SweepOnce()
+	if err != nil || count != 100 || s.Swept() != 100 {
+		t.Fatal("the sweeper should delete 100 rows, but ", count, err)
+	}
+	if tree.PageStats().TotalFill != 200*uint64(4+8) {
+		t.Fatal("the expired rows should be removed from the pages")
+	}
+}
+
+func TestSweeper_Start(t *testing.T) {
+	tree, expireAt := newTTLTree(0)
+	for i := 1; i <= 10; i++ {
+		r := NewRow(tree.meta)
+		r.WithDefaultValues()
+		r.SetKey(uint32(i))
+		r.SetCellValueForTest(expireAt, time.Now().Add(-time.Second).UnixNano())
+		tree.Insert(r)
+	}
+	s := NewSweeper(tree, &SweepOptions{RowsPerSecond: 1000, Interval: time.Millisecond})
+	s.Stop()
+	s.Start()
+	s.Start()
+	for i := 0; i < 100 && s.Swept() < 10; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.Stop()
+	s.Stop()
+	if s.Swept() != 10 {
+		t.Fatal("all the rows with a past expire time should be swept, but ", s.Swept())
+	}
+}
+
+func TestSweeper_Err(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pitydb")
+	defer os.RemoveAll(dir)
+
+	tree, expireAt := newTTLTree(0)
+	w, _ := OpenWal(filepath.Join(dir, "t1.wal"))
+	tree.AttachWal(w)
+	r := NewRow(tree.meta)
+	r.WithDefaultValues()
+	r.SetKey(1)
+	r.SetCellValueForTest(expireAt, time.Now().Add(-time.Second).UnixNano())
+	tree.Insert(r)
+	//the delete can not be logged
+	w.Close()
+
+	s := NewSweeper(tree, &SweepOptions{RowsPerSecond: 0, Interval: time.Millisecond})
+	s.Start()
+	for i := 0; i < 100 && nil == s.Err(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.Stop()
+	if nil == s.Err() || s.Swept() != 0 {
+		t.Fatal("the failed pass should be seen, but ", s.Err(), s.Swept())
+	}
+}
+
+func TestRow_expiredTimeCell(t *testing.T) {
+	rowMeta := dt.NewRowMeta()
+	slot0 := dt.NewCellMetaRaw(0, dt.UInt32Type, "id", "the auto incrementID", nil)
+	slot1 := dt.NewCellMetaRaw(1, dt.TimeType, "expire_at", "the row expires at", nil)
+	rowMeta.AddCellMeta(slot0)
+	rowMeta.AddCellMeta(slot1)
+	rowMeta.SetTTL(slot1, 0)
+
+	r := NewRow(rowMeta)
+	r.WithDefaultValues()
+	now := time.Now()
+	r.SetCellValueForTest(slot1, now.Add(-time.Second))
+	if !r.expired(now.UnixNano()) {
+		t.Fatal("the row should expire at the time of the cell")
+	}
+	r.SetCellValueForTest(slot1, now.Add(time.Second))
+	if r.expired(now.UnixNano()) {
+		t.Fatal("the row should not expire before the time of the cell")
+	}
+}
@@ -0,0 +1,238 @@
+package yard
+
+import (
+	"bytes"
+	"errors"
+	"github.com/lycying/pitydb/dt"
+	"github.com/lycying/pitydb/utils"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+const (
+	walInsert byte = iota + 1
+	walDelete
+	walCompact //the pages are rewritten, there is nothing to replay
+)
+
+var ErrWalChecksum = errors.New("wal record checksum mismatch")
+var ErrWalGap = errors.New("the wal does not follow the backup, it is cut off after a flush")
+
+// walRecord is one change of a tree, the row is saved encoded
+// because the meta is only known by the reader
+type walRecord struct {
+	lsn uint64
+	ts  uint64 //time.Now().UnixNano()
+	op  byte
+	key uint32
+	row []byte
+}
+
+func (rec *walRecord) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	writeDt(buf, dt.ValidNewUInt64(rec.lsn))
+	writeDt(buf, dt.ValidNewUInt64(rec.ts))
+	writeDt(buf, dt.ValidNewByte(rec.op))
+	writeDt(buf, dt.ValidNewUInt32(rec.key))
+	writeDt(buf, dt.ValidNewUInt32(uint32(len(rec.row))))
+	buf.Write(rec.row)
+	writeDt(buf, dt.ValidNewUInt32(utils.Sum32(buf.Bytes())))
+	return buf.Bytes(), nil
+}
+
+// walRecordHeadLen is the length of the fields before the row
+const walRecordHeadLen = 8 + 8 + 1 + 4 + 4
+
+// Decode returns io.ErrUnexpectedEOF for a torn record and ErrWalChecksum
+// with the length of the record for a broken one
+func (rec *walRecord) Decode(buf []byte, offset int) (int, error) {
+	if len(buf)-offset < walRecordHeadLen {
+		return 0, io.ErrUnexpectedEOF
+	}
+	lsn, ts, op, key, rowLen := dt.NewUInt64(), dt.NewUInt64(), dt.NewByte(), dt.NewUInt32(), dt.NewUInt32()
+	idx := offset
+	idx = readDt(buf, idx, lsn)
+	idx = readDt(buf, idx, ts)
+	idx = readDt(buf, idx, op)
+	idx = readDt(buf, idx, key)
+	idx = readDt(buf, idx, rowLen)
+
+	end := idx + int(rowLen.GetValue().(uint32))
+	if end+4 > len(buf) || end < idx {
+		return 0, io.ErrUnexpectedEOF
+	}
+	sum := dt.NewUInt32()
+	readDt(buf, end, sum)
+	if utils.Sum32(buf[offset:end]) != sum.GetValue().(uint32) {
+		return end + 4 - offset, ErrWalChecksum
+	}
+
+	rec.lsn = lsn.GetValue().(uint64)
+	rec.ts = ts.GetValue().(uint64)
+	rec.op = op.GetValue().(byte)
+	rec.key = key.GetValue().(uint32)
+	rec.row = append([]byte(nil), buf[idx:end]...)
+	return end + 4 - offset, nil
+}
+
+// decodeWalRecords reads the records until the end of buf or a torn record,
+// it returns the records and the length of the good part. A record is torn if
+// it runs past the end of buf, or if it is broken and only zeros follow it.
+// A broken record that other records follow is ErrWalChecksum, the records
+// after it are committed and must not be cut off.
+func decodeWalRecords(buf []byte) ([]*walRecord, int, error) {
+	records := make([]*walRecord, 0)
+	idx := 0
+	for idx < len(buf) {
+		rec := &walRecord{}
+		l, err := rec.Decode(buf, idx)
+		if err == ErrWalChecksum && !isZeros(buf[idx+l:]) {
+			return nil, idx, err
+		}
+		if err != nil {
+			break
+		}
+		records = append(records, rec)
+		idx += l
+	}
+	return records, idx, nil
+}
+
+func isZeros(buf []byte) bool {
+	for _, b := range buf {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Wal is the write ahead log of a tree, every change is saved
+// and synced to the file before it is applied to the pages
+type Wal struct {
+	lock    sync.Mutex
+	file    *os.File
+	lastLSN uint64
+}
+
+// OpenWal opens the log of path, a torn record at the tail left by a crash is
+// cut off and a broken record in the middle is ErrWalChecksum
+func OpenWal(path string) (*Wal, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := ioutil.ReadAll(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	records, good, err := decodeWalRecords(buf)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if good < len(buf) {
+		if err := f.Truncate(int64(good)); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	if _, err := f.Seek(int64(good), io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	w := &Wal{file: f}
+	if len(records) > 0 {
+		w.lastLSN = records[len(records)-1].lsn
+	}
+	return w, nil
+}
+
+func (w *Wal) append(rec *walRecord) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	b, _ := rec.Encode()
+	if _, err := w.file.Write(b); err != nil {
+		return err
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	w.lastLSN = rec.lsn
+	return nil
+}
+
+// LastLSN returns the lsn of the last record, 0 for an empty log
+func (w *Wal) LastLSN() uint64 {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.lastLSN
+}
+
+// readAfter returns the records which lsn is larger than lsn
+func (w *Wal) readAfter(lsn uint64) ([]*walRecord, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return readWalAfter(w.file.Name(), lsn)
+}
+
+func readWalAfter(path string, lsn uint64) ([]*walRecord, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	records, _, err := decodeWalRecords(buf)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*walRecord, 0)
+	for _, rec := range records {
+		if rec.lsn > lsn {
+			ret = append(ret, rec)
+		}
+	}
+	return ret, nil
+}
+
+// truncate cuts off the records which lsn is not larger than lsn, the rest
+// is written to a new file that replaces the log by a rename
+func (w *Wal) truncate(lsn uint64) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	path := w.file.Name()
+	records, err := readWalAfter(path, lsn)
+	if err != nil {
+		return err
+	}
+	buf := new(bytes.Buffer)
+	for _, rec := range records {
+		b, _ := rec.Encode()
+		buf.Write(b)
+	}
+	if err := writeFileAtomic(path, buf.Bytes()); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0666)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		f.Close()
+		return err
+	}
+	w.file.Close()
+	w.file = f
+	return nil
+}
+
+func (w *Wal) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.file.Close()
+}
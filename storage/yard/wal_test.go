@@ -0,0 +1,146 @@
+package yard
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWal_Torn(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pitydb")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "t1.wal")
+
+	w, err := OpenWal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := newTestTree(0)
+	tree.AttachWal(w)
+	for i := 1; i <= 10; i++ {
+		r := NewRow(tree.meta)
+		r.WithDefaultValues()
+		r.SetKey(uint32(i))
+		if err := tree.Insert(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tree.Delete(3)
+	w.Close()
+
+	//a crash in the middle of the last record
+	info, _ := os.Stat(path)
+	os.Truncate(path, info.Size()-3)
+
+	w, err = OpenWal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.LastLSN() != 10 {
+		t.Fatal("the torn delete should be cut off, but the last lsn is ", w.LastLSN())
+	}
+	records, _ := w.readAfter(8)
+	if len(records) != 2 || records[0].op != walInsert || records[1].key != 10 {
+		t.Fatal("the records after lsn 8 should be the inserts of 9 and 10")
+	}
+
+	tree2 := newTestTree(0)
+	tree2.AttachWal(w)
+	if tree2.LSN() != 10 {
+		t.Fatal("the tree should go on after the log, but ", tree2.LSN())
+	}
+}
+
+func TestWal_Corrupt(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pitydb")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "t1.wal")
+
+	w, _ := OpenWal(path)
+	tree := newTestTree(0)
+	tree.AttachWal(w)
+	insertTestRows(tree, 1, 10)
+	w.Close()
+	buf, _ := ioutil.ReadFile(path)
+
+	//a bad record that committed records follow
+	l, _ := (&walRecord{}).Decode(buf, 0)
+	bad := append([]byte(nil), buf...)
+	bad[2*l+walRecordHeadLen]++
+	ioutil.WriteFile(path, bad, 0666)
+	if _, err := OpenWal(path); err != ErrWalChecksum {
+		t.Fatal("a bad record in the middle should not be cut off, but ", err)
+	}
+	if info, _ := os.Stat(path); info.Size() != int64(len(buf)) {
+		t.Fatal("the log should not be truncated")
+	}
+
+	//a bad last record is a torn write
+	bad = append([]byte(nil), buf...)
+	bad[len(bad)-1]++
+	ioutil.WriteFile(path, append(bad, make([]byte, 16)...), 0666)
+	w, err := OpenWal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if w.LastLSN() != 9 {
+		t.Fatal("the bad last record should be cut off, but the last lsn is ", w.LastLSN())
+	}
+}
+
+func TestPageTree_AttachWal(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pitydb")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "t1.pity")
+	walPath := filepath.Join(dir, "t1.wal")
+
+	w, _ := OpenWal(walPath)
+	tree := newTestTree(0)
+	tree.link, _ = os.Create(path)
+	if err := tree.AttachWal(w); err != nil {
+		t.Fatal(err)
+	}
+	insertTestRows(tree, 1, 100)
+	if err := tree.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if records, _ := w.readAfter(0); len(records) != 0 {
+		t.Fatal("the records in the page file should be cut off, but ", len(records))
+	}
+	insertTestRows(tree, 101, 103)
+	tree.Delete(5)
+	w.Close()
+
+	//a crash before the next flush
+	tree2, err := OpenPageTree(tree.meta, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, _ = OpenWal(walPath)
+	defer w.Close()
+	if err := tree2.AttachWal(w); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 103; i++ {
+		if _, found := tree2.Get(uint32(i)); found != (i != 5) {
+			t.Fatal("the logged writes should be replayed ", i)
+		}
+	}
+	if tree2.LSN() != 104 {
+		t.Fatal("the lsn should be the last write, but ", tree2.LSN())
+	}
+
+	//the records after a backup are kept for a restore
+	m, _ := tree2.Backup(ioutil.Discard, 0)
+	insertTestRows(tree2, 104, 110)
+	tree2.Flush()
+	if records, _ := w.readAfter(0); len(records) != 7 || records[0].lsn != m.LSN+1 {
+		t.Fatal("the records after the backup should be kept, but ", len(records))
+	}
+	insertTestRows(tree2, 111, 111)
+	if last, _ := w.readAfter(0); w.LastLSN() != 112 || last[len(last)-1].key != 111 {
+		t.Fatal("the log should go on after a cut")
+	}
+}
@@ -71,9 +71,35 @@ func (cluster *Cluster) Size() int {
 }
 
 func (cluster *Cluster) Broadcast(packet mut.Packet) {
+	cluster.collectionLock.RLock()
+	defer cluster.collectionLock.RUnlock()
+
 	for _, value := range cluster.endPoints {
 		if value.IsConnected() {
 			value.Conn().WriteAsync(packet)
 		}
 	}
 }
+
+// Send writes the packet to peer if it is connected
+func (cluster *Cluster) Send(peer string, packet mut.Packet) {
+	cluster.collectionLock.RLock()
+	defer cluster.collectionLock.RUnlock()
+
+	if value, ok := cluster.endPoints[strings.TrimSpace(peer)]; ok && value.IsConnected() {
+		value.Conn().WriteAsync(packet)
+	}
+}
+
+// PeerOf returns the peer dialed by the connection c, "" for a connection accepted by the server
+func (cluster *Cluster) PeerOf(c *mut.Conn) string {
+	cluster.collectionLock.RLock()
+	defer cluster.collectionLock.RUnlock()
+
+	for peer, value := range cluster.endPoints {
+		if value.IsConnected() && value.Conn() == c {
+			return peer
+		}
+	}
+	return ""
+}
@@ -0,0 +1,604 @@
+package raft
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrLogNotFound   = errors.New("raft: log not found")
+	ErrNotContiguous = errors.New("raft: the logs must follow the last index")
+	ErrDeleteMiddle  = errors.New("raft: only a prefix or a suffix of the logs can be deleted")
+	ErrCorruptLog    = errors.New("raft: corrupt log segment")
+)
+
+// DefaultSegmentSize is the size a segment grows to before a new one is started
+const DefaultSegmentSize = 64 << 20
+
+const (
+	segmentExt = ".log"
+	firstFile  = "first"  //the first index after a compaction
+	stableFile = "stable" //the keys of the StableStore
+
+	//a record is the length and the crc of its body, then the body of the
+	//index, the term and the data
+	recordHeader = 8
+	recordIndex  = 16
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// segment is a file of the logs from first in order, offsets[i] is where the
+// record of first+i starts and size is where the next record is written
+type segment struct {
+	path    string
+	file    *os.File
+	base    uint64 //the index in the name of the file
+	first   uint64
+	offsets []int64
+	size    int64
+}
+
+func (seg *segment) last() uint64 {
+	return seg.first + uint64(len(seg.offsets)) - 1
+}
+
+// FileStore implements the LogStore interface with append-only segment files
+// in a directory. A record is checked by its crc when it is read, and a torn
+// record that ends the last segment is cut off when the store is opened.
+// It implements the StableStore interface too, the keys are saved in a file
+// of the directory on every Set.
+type FileStore struct {
+	lock        sync.RWMutex
+	dir         string
+	segmentSize int64
+	segments    []*segment //in order, only the last one is written
+	kv          map[string][]byte
+}
+
+// NewFileStore opens the logs in dir, a segmentSize of 0 is DefaultSegmentSize
+func NewFileStore(dir string, segmentSize int64) (*FileStore, error) {
+	if segmentSize <= 0 {
+		segmentSize = DefaultSegmentSize
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	s := &FileStore{dir: dir, segmentSize: segmentSize, kv: make(map[string][]byte)}
+	if err := s.recover(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileStore) segmentPath(base uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", base, segmentExt))
+}
+
+// recover reads the segments in the order of their names
+func (s *FileStore) recover() error {
+	names, err := filepath.Glob(filepath.Join(s.dir, "*"+segmentExt))
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+	first, err := s.readFirst()
+	if err != nil {
+		return err
+	}
+	if err := s.readStable(); err != nil {
+		return err
+	}
+	for i, name := range names {
+		var base uint64
+		if _, err := fmt.Sscanf(strings.TrimSuffix(filepath.Base(name), segmentExt), "%d", &base); err != nil {
+			return fmt.Errorf("%v: %s", ErrCorruptLog, name)
+		}
+		seg, err := s.openSegment(name, base, i == len(names)-1)
+		if err != nil {
+			return err
+		}
+		last := s.lastIndex()
+		s.segments = append(s.segments, seg)
+		if last > 0 && len(seg.offsets) > 0 && last+1 != seg.base {
+			return fmt.Errorf("%v: %s does not follow the last index", ErrCorruptLog, name)
+		}
+	}
+	return s.compact(first)
+}
+
+// openSegment reads the offsets of the records, a bad record that ends the
+// last segment is a write that did not finish and is truncated. A bad record
+// before the other records is ErrCorruptLog, they are committed logs.
+func (s *FileStore) openSegment(path string, base uint64, tail bool) (*segment, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	seg := &segment{path: path, file: f, base: base, first: base}
+	buf, err := ioutil.ReadAll(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	for seg.size < int64(len(buf)) {
+		l, ok := checkRecord(buf[seg.size:], base+uint64(len(seg.offsets)))
+		if !ok {
+			if !tail || !isTorn(buf[seg.size:]) {
+				f.Close()
+				return nil, fmt.Errorf("%v: %s at %d", ErrCorruptLog, path, seg.size)
+			}
+			logger.Warn("raft# cut the torn record of %s at %d", path, seg.size)
+			if err := f.Truncate(seg.size); err != nil {
+				f.Close()
+				return nil, err
+			}
+			if err := f.Sync(); err != nil {
+				f.Close()
+				return nil, err
+			}
+			break
+		}
+		seg.offsets = append(seg.offsets, seg.size)
+		seg.size += int64(l)
+	}
+	return seg, nil
+}
+
+// checkRecord returns the length of the record at the start of buf if it is
+// whole, its crc is right and it is the log of index
+func checkRecord(buf []byte, index uint64) (int, bool) {
+	if len(buf) < recordHeader {
+		return 0, false
+	}
+	n := int(binary.BigEndian.Uint32(buf))
+	if n < recordIndex || len(buf)-recordHeader < n {
+		return 0, false
+	}
+	body := buf[recordHeader : recordHeader+n]
+	if crc32.Checksum(body, castagnoli) != binary.BigEndian.Uint32(buf[4:]) {
+		return 0, false
+	}
+	return recordHeader + n, binary.BigEndian.Uint64(body) == index
+}
+
+// isTorn reports whether the bad record at the start of buf is a write that
+// did not finish, it runs to the end of the file or only zeros follow it
+func isTorn(buf []byte) bool {
+	if len(buf) < recordHeader {
+		return true
+	}
+	if recordHeader+int(binary.BigEndian.Uint32(buf)) >= len(buf) {
+		return true
+	}
+	for _, b := range buf {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func appendRecord(buf []byte, l *Log) []byte {
+	body := make([]byte, recordIndex+len(l.Data))
+	binary.BigEndian.PutUint64(body, l.Index)
+	binary.BigEndian.PutUint64(body[8:], l.Term)
+	copy(body[recordIndex:], l.Data)
+	var header [recordHeader]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(body)))
+	binary.BigEndian.PutUint32(header[4:], crc32.Checksum(body, castagnoli))
+	return append(append(buf, header[:]...), body...)
+}
+
+// readFirst returns the first index saved by a compaction, 0 if there is none
+func (s *FileStore) readFirst() (uint64, error) {
+	buf, err := ioutil.ReadFile(filepath.Join(s.dir, firstFile))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(buf) != 12 || crc32.Checksum(buf[:8], castagnoli) != binary.BigEndian.Uint32(buf[8:]) {
+		return 0, fmt.Errorf("%v: %s", ErrCorruptLog, firstFile)
+	}
+	return binary.BigEndian.Uint64(buf), nil
+}
+
+// writeFirst saves the first index
+func (s *FileStore) writeFirst(first uint64) error {
+	buf := make([]byte, 12)
+	binary.BigEndian.PutUint64(buf, first)
+	binary.BigEndian.PutUint32(buf[8:], crc32.Checksum(buf[:8], castagnoli))
+	return s.writeFile(firstFile, buf)
+}
+
+// writeFile replaces the file name by a rename so it is never half written
+func (s *FileStore) writeFile(name string, buf []byte) error {
+	tmp := filepath.Join(s.dir, name+".tmp")
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, name)); err != nil {
+		return err
+	}
+	return s.syncDir()
+}
+
+// syncDir makes the files made, renamed and removed in the directory durable
+func (s *FileStore) syncDir() error {
+	d, err := os.Open(s.dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// Close closes the segment files
+func (s *FileStore) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var ret error
+	for _, seg := range s.segments {
+		if err := seg.file.Close(); err != nil && nil == ret {
+			ret = err
+		}
+	}
+	s.segments = nil
+	return ret
+}
+
+// FirstIndex implements the LogStore interface.
+func (s *FileStore) FirstIndex() (uint64, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	for _, seg := range s.segments {
+		if len(seg.offsets) > 0 {
+			return seg.first, nil
+		}
+	}
+	return 0, nil
+}
+
+// LastIndex implements the LogStore interface.
+func (s *FileStore) LastIndex() (uint64, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.lastIndex(), nil
+}
+
+func (s *FileStore) lastIndex() uint64 {
+	for i := len(s.segments) - 1; i >= 0; i-- {
+		if seg := s.segments[i]; len(seg.offsets) > 0 {
+			return seg.last()
+		}
+	}
+	return 0
+}
+
+// find returns the segment of index, nil if index is not stored
+func (s *FileStore) find(index uint64) *segment {
+	i := sort.Search(len(s.segments), func(i int) bool {
+		return s.segments[i].first > index
+	})
+	if i == 0 {
+		return nil
+	}
+	seg := s.segments[i-1]
+	if len(seg.offsets) == 0 || index > seg.last() {
+		return nil
+	}
+	return seg
+}
+
+// GetLog implements the LogStore interface.
+func (s *FileStore) GetLog(index uint64) (*Log, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	seg := s.find(index)
+	if nil == seg {
+		return nil, ErrLogNotFound
+	}
+	i := index - seg.first
+	end := seg.size
+	if i+1 < uint64(len(seg.offsets)) {
+		end = seg.offsets[i+1]
+	}
+	buf := make([]byte, end-seg.offsets[i])
+	if _, err := seg.file.ReadAt(buf, seg.offsets[i]); err != nil {
+		return nil, err
+	}
+	if _, ok := checkRecord(buf, index); !ok {
+		return nil, fmt.Errorf("%v: %s at %d", ErrCorruptLog, seg.path, seg.offsets[i])
+	}
+	return &Log{
+		Index: index,
+		Term:  binary.BigEndian.Uint64(buf[recordHeader+8:]),
+		Data:  string(buf[recordHeader+recordIndex:]),
+	}, nil
+}
+
+// StoreLog implements the LogStore interface.
+func (s *FileStore) StoreLog(log *Log) error {
+	return s.StoreLogs([]*Log{log})
+}
+
+// StoreLogs implements the LogStore interface. The logs must follow the last
+// index in order, they are written to the last segment and synced at once.
+func (s *FileStore) StoreLogs(logs []*Log) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for len(logs) > 0 {
+		last := s.lastIndex()
+		if last > 0 && logs[0].Index != last+1 {
+			return ErrNotContiguous
+		}
+		seg, err := s.tail(logs[0].Index)
+		if err != nil {
+			return err
+		}
+		var buf []byte
+		offsets := make([]int64, 0, len(logs))
+		n := 0
+		for ; n < len(logs) && (n == 0 || seg.size+int64(len(buf)) < s.segmentSize); n++ {
+			if n > 0 && logs[n].Index != logs[n-1].Index+1 {
+				return ErrNotContiguous
+			}
+			offsets = append(offsets, seg.size+int64(len(buf)))
+			buf = appendRecord(buf, logs[n])
+		}
+		if _, err := seg.file.WriteAt(buf, seg.size); err != nil {
+			//a part of buf may be written
+			seg.file.Truncate(seg.size)
+			return err
+		}
+		if err := seg.file.Sync(); err != nil {
+			return err
+		}
+		seg.offsets = append(seg.offsets, offsets...)
+		seg.size += int64(len(buf))
+		logs = logs[n:]
+	}
+	return nil
+}
+
+// tail returns the segment to write the log of index, a full or an empty
+// store starts a new segment
+func (s *FileStore) tail(index uint64) (*segment, error) {
+	if n := len(s.segments); n > 0 {
+		seg := s.segments[n-1]
+		if len(seg.offsets) > 0 && seg.size < s.segmentSize {
+			return seg, nil
+		}
+		if len(seg.offsets) == 0 {
+			//an empty segment is only reused for the index in its name
+			if seg.base == index {
+				seg.first = index
+				return seg, nil
+			}
+			if err := s.removeSegment(n - 1); err != nil {
+				return nil, err
+			}
+		}
+	}
+	path := s.segmentPath(index)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.syncDir(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	seg := &segment{path: path, file: f, base: index, first: index}
+	s.segments = append(s.segments, seg)
+	return seg, nil
+}
+
+func (s *FileStore) removeSegment(i int) error {
+	seg := s.segments[i]
+	seg.file.Close()
+	if err := os.Remove(seg.path); err != nil {
+		return err
+	}
+	s.segments = append(s.segments[:i], s.segments[i+1:]...)
+	return nil
+}
+
+// DeleteRange implements the LogStore interface. A prefix removes the
+// segments before max and saves the new first index, a suffix truncates the
+// segment of min and removes the ones after it.
+func (s *FileStore) DeleteRange(min, max uint64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	first, last := uint64(0), s.lastIndex()
+	for _, seg := range s.segments {
+		if len(seg.offsets) > 0 {
+			first = seg.first
+			break
+		}
+	}
+	switch {
+	case last == 0 || min > max || max < first || min > last:
+		return nil
+	case min <= first && max >= last:
+		return s.truncate(first)
+	case min <= first:
+		if err := s.writeFirst(max + 1); err != nil {
+			return err
+		}
+		return s.compact(max + 1)
+	case max >= last:
+		return s.truncate(min)
+	}
+	return ErrDeleteMiddle
+}
+
+// compact drops the logs before first, the segments that are all before it
+// are removed
+func (s *FileStore) compact(first uint64) error {
+	for len(s.segments) > 0 {
+		seg := s.segments[0]
+		if len(seg.offsets) > 0 && seg.last() >= first {
+			if first > seg.first {
+				seg.offsets = seg.offsets[first-seg.first:]
+				seg.first = first
+			}
+			break
+		}
+		if err := s.removeSegment(0); err != nil {
+			return err
+		}
+	}
+	return s.syncDir()
+}
+
+// truncate deletes the logs from index to the end
+func (s *FileStore) truncate(index uint64) error {
+	for len(s.segments) > 0 {
+		i := len(s.segments) - 1
+		seg := s.segments[i]
+		if len(seg.offsets) > 0 && seg.first < index {
+			at := seg.offsets[index-seg.first]
+			if err := seg.file.Truncate(at); err != nil {
+				return err
+			}
+			if err := seg.file.Sync(); err != nil {
+				return err
+			}
+			seg.offsets = seg.offsets[:index-seg.first]
+			seg.size = at
+			break
+		}
+		if err := s.removeSegment(i); err != nil {
+			return err
+		}
+	}
+	if len(s.segments) == 0 {
+		//nothing is left to compact
+		if err := os.Remove(filepath.Join(s.dir, firstFile)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return s.syncDir()
+}
+
+// readStable reads the keys of the StableStore, the file is a key and a value
+// with their lengths after another and the crc of them all
+func (s *FileStore) readStable() error {
+	buf, err := ioutil.ReadFile(filepath.Join(s.dir, stableFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	bad := fmt.Errorf("%v: %s", ErrCorruptLog, stableFile)
+	if len(buf) < 4 {
+		return bad
+	}
+	body := buf[:len(buf)-4]
+	if crc32.Checksum(body, castagnoli) != binary.BigEndian.Uint32(buf[len(body):]) {
+		return bad
+	}
+	for len(body) > 0 {
+		var kv [2][]byte
+		for i := range kv {
+			if len(body) < 4 || uint64(len(body)-4) < uint64(binary.BigEndian.Uint32(body)) {
+				return bad
+			}
+			n := 4 + int(binary.BigEndian.Uint32(body))
+			kv[i] = body[4:n]
+			body = body[n:]
+		}
+		s.kv[string(kv[0])] = kv[1]
+	}
+	return nil
+}
+
+func (s *FileStore) writeStable() error {
+	keys := make([]string, 0, len(s.kv))
+	for k := range s.kv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var buf []byte
+	for _, k := range keys {
+		buf = appendBytes(appendBytes(buf, []byte(k)), s.kv[k])
+	}
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.Checksum(buf, castagnoli))
+	return s.writeFile(stableFile, append(buf, sum[:]...))
+}
+
+func appendBytes(buf []byte, b []byte) []byte {
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(b)))
+	return append(append(buf, n[:]...), b...)
+}
+
+// Set implements the StableStore interface, the key is durable when it returns
+func (s *FileStore) Set(key []byte, val []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	old, ok := s.kv[string(key)]
+	s.kv[string(key)] = append([]byte(nil), val...)
+	if err := s.writeStable(); err != nil {
+		if ok {
+			s.kv[string(key)] = old
+		} else {
+			delete(s.kv, string(key))
+		}
+		return err
+	}
+	return nil
+}
+
+// Get implements the StableStore interface.
+func (s *FileStore) Get(key []byte) ([]byte, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return append([]byte{}, s.kv[string(key)]...), nil
+}
+
+// SetUint64 implements the StableStore interface, the value is the 8 bytes
+// of a key of Set
+func (s *FileStore) SetUint64(key []byte, val uint64) error {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, val)
+	return s.Set(key, buf)
+}
+
+// GetUint64 implements the StableStore interface.
+func (s *FileStore) GetUint64(key []byte) (uint64, error) {
+	buf, _ := s.Get(key)
+	switch len(buf) {
+	case 0:
+		return 0, nil
+	case 8:
+		return binary.BigEndian.Uint64(buf), nil
+	}
+	return 0, fmt.Errorf("raft: %s is not a uint64", key)
+}
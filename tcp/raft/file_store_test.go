@@ -0,0 +1,232 @@
+package raft
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func storeRange(t *testing.T, s *FileStore, from uint64, to uint64) {
+	var logs []*Log
+	for i := from; i <= to; i++ {
+		logs = append(logs, &Log{Index: i, Term: i / 10, Data: fmt.Sprint("data ", i)})
+	}
+	if err := s.StoreLogs(logs); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkRange(t *testing.T, s *FileStore, first uint64, last uint64) {
+	if idx, _ := s.FirstIndex(); idx != first {
+		t.Fatal("the first index should be", first, "not", idx)
+	}
+	if idx, _ := s.LastIndex(); idx != last {
+		t.Fatal("the last index should be", last, "not", idx)
+	}
+	for i := first; i <= last && last > 0; i++ {
+		l, err := s.GetLog(i)
+		if err != nil || l.Index != i || l.Term != i/10 || l.Data != fmt.Sprint("data ", i) {
+			t.Fatal("the log", i, "should be read", l, err)
+		}
+	}
+	if _, err := s.GetLog(first - 1); err != ErrLogNotFound {
+		t.Fatal("a log before the first should not be found")
+	}
+	if _, err := s.GetLog(last + 1); err != ErrLogNotFound {
+		t.Fatal("a log after the last should not be found")
+	}
+}
+
+func reopen(t *testing.T, s *FileStore) *FileStore {
+	s.Close()
+	s2, err := NewFileStore(s.dir, s.segmentSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s2
+}
+
+func TestFileStore(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "raft")
+	defer os.RemoveAll(dir)
+
+	//a segment holds a few records
+	s, err := NewFileStore(dir, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var _ LogStore = s
+	checkRange(t, s, 0, 0)
+	storeRange(t, s, 1, 50)
+	if err := s.StoreLog(&Log{Index: 52}); err != ErrNotContiguous {
+		t.Fatal("a gap should be refused")
+	}
+	if len(s.segments) < 5 {
+		t.Fatal("the logs should be in segments", len(s.segments))
+	}
+	checkRange(t, s, 1, 50)
+	s = reopen(t, s)
+	checkRange(t, s, 1, 50)
+
+	if err := s.DeleteRange(10, 20); err != ErrDeleteMiddle {
+		t.Fatal("a range in the middle should be refused")
+	}
+	segments := len(s.segments)
+	if err := s.DeleteRange(1, 30); err != nil {
+		t.Fatal(err)
+	}
+	checkRange(t, s, 31, 50)
+	if len(s.segments) >= segments {
+		t.Fatal("the compacted segments should be removed")
+	}
+	s = reopen(t, s)
+	checkRange(t, s, 31, 50)
+
+	if err := s.DeleteRange(45, 60); err != nil {
+		t.Fatal(err)
+	}
+	checkRange(t, s, 31, 44)
+	storeRange(t, s, 45, 70)
+	s = reopen(t, s)
+	checkRange(t, s, 31, 70)
+
+	if err := s.DeleteRange(0, 70); err != nil {
+		t.Fatal(err)
+	}
+	checkRange(t, s, 0, 0)
+	storeRange(t, s, 5, 8)
+	s = reopen(t, s)
+	checkRange(t, s, 5, 8)
+	s.Close()
+}
+
+func TestFileStore_Recover(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "raft")
+	defer os.RemoveAll(dir)
+
+	s, err := NewFileStore(dir, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storeRange(t, s, 1, 20)
+	tail := s.segments[len(s.segments)-1]
+	size := tail.size
+	s.Close()
+
+	//a write that did not finish
+	f, _ := os.OpenFile(tail.path, os.O_WRONLY|os.O_APPEND, 0644)
+	f.Write(appendRecord(nil, &Log{Index: 21, Term: 2, Data: "data 21"})[:10])
+	f.Close()
+	s, err = NewFileStore(dir, 200)
+	if err != nil {
+		t.Fatal("a torn record should be cut off", err)
+	}
+	checkRange(t, s, 1, 20)
+	if info, _ := os.Stat(tail.path); info.Size() != size {
+		t.Fatal("the segment should be truncated", info.Size(), size)
+	}
+	storeRange(t, s, 21, 26)
+	checkRange(t, s, 1, 26)
+	tail = s.segments[len(s.segments)-1]
+	if len(tail.offsets) < 2 {
+		t.Fatal("the last segment should have a few logs", tail.first)
+	}
+	s.Close()
+
+	//a bad record followed by others is not a torn write
+	buf, _ := ioutil.ReadFile(tail.path)
+	ioutil.WriteFile(tail.path+".bak", buf, 0644)
+	buf[tail.offsets[1]-1]++
+	ioutil.WriteFile(tail.path, buf, 0644)
+	if _, err := NewFileStore(dir, 200); !strings.HasPrefix(fmt.Sprint(err), ErrCorruptLog.Error()) {
+		t.Fatal("the logs after a bad record should not be cut off", err)
+	}
+	os.Rename(tail.path+".bak", tail.path)
+
+	//a bad crc before the last segment is not a torn write
+	names, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	buf, _ = ioutil.ReadFile(names[0])
+	buf[len(buf)-1]++
+	ioutil.WriteFile(names[0], buf, 0644)
+	if _, err := NewFileStore(dir, 200); nil == err {
+		t.Fatal("a corrupt segment should not be opened")
+	}
+}
+
+func TestFileStore_Raft(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "raft")
+	defer os.RemoveAll(dir)
+
+	s, err := NewFileStore(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newRaft(&RaftConfig{Srv: "a", Peers: []string{"b", "c"}, LogStore: s, StableStore: s})
+	a.timeout = time.Hour
+	b := newTestRaft("b", "a", "c")
+	a.lock.Lock()
+	a.startElection()
+	a.lock.Unlock()
+	a.processVoteResp(b.processVoteReq(&VoteReq{Term: 2, Candidate: "a", LastLogIndex: 1, LastLogTerm: 1}))
+	a.Apply("x")
+	a.Apply("y")
+	syncPeer(a, "b", b)
+	if a.GetCommitIndex() != 3 {
+		t.Fatal("the logs should be committed")
+	}
+
+	//b is the leader of a later term and a had an entry that is not committed
+	a.Apply("lost")
+	a.processAppendEntriesReq(&AppendEntriesReq{Term: 3, Leader: "b", PrevLogEntry: 3, PrevLogTerm: 2,
+		Entries: []*Log{{Index: 4, Term: 3, Data: "z"}}, LeaderCommitIndex: 4})
+	s = reopen(t, s)
+	if l, err := s.GetLog(4); err != nil || l.Data != "z" || l.Term != 3 {
+		t.Fatal("the follower should keep the entry of the leader", l, err)
+	}
+	if last, _ := s.LastIndex(); last != 4 {
+		t.Fatal("the log should end at 4", last)
+	}
+	again := newRaft(&RaftConfig{Srv: "a", Peers: []string{"b", "c"}, LogStore: s, StableStore: s})
+	if again.currentTerm != 3 || again.votedFor != "" {
+		t.Fatal("the term should be restored", again.currentTerm, again.votedFor)
+	}
+	s.Close()
+}
+
+func TestFileStore_Stable(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "raft")
+	defer os.RemoveAll(dir)
+
+	s, err := NewFileStore(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var _ StableStore = s
+	if v, _ := s.Get([]byte("k")); nil == v || len(v) != 0 {
+		t.Fatal("a missing key should be empty")
+	}
+	s.Set([]byte("k"), []byte("v"))
+	s.Set([]byte("empty"), nil)
+	s.SetUint64([]byte("n"), 7)
+	s = reopen(t, s)
+	v, _ := s.Get([]byte("k"))
+	n, _ := s.GetUint64([]byte("n"))
+	if string(v) != "v" || n != 7 {
+		t.Fatal("the keys should be durable", string(v), n)
+	}
+	if _, err := s.GetUint64([]byte("k")); err == nil {
+		t.Fatal("v is not a uint64")
+	}
+	s.Close()
+
+	buf, _ := ioutil.ReadFile(filepath.Join(dir, stableFile))
+	buf[2]++
+	ioutil.WriteFile(filepath.Join(dir, stableFile), buf, 0644)
+	if _, err := NewFileStore(dir, 0); nil == err {
+		t.Fatal("a corrupt stable file should not be opened")
+	}
+}
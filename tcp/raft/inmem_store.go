@@ -7,7 +7,7 @@ import (
 
 // InmemStore implements the LogStore and StableStore interface.
 // It should NOT EVER be used for production. It is used only for
-// unit tests. Use the MDBStore implementation instead.
+// unit tests. Use the FileStore implementation instead.
 type InmemStore struct {
 	lock      sync.RWMutex
 	lowIndex  uint64
@@ -81,7 +81,17 @@ func (i *InmemStore) DeleteRange(min, max uint64) error {
 	for j := min; j <= max; j++ {
 		delete(i.logs, j)
 	}
-	i.lowIndex = max + 1
+	if len(i.logs) == 0 {
+		i.lowIndex, i.highIndex = 0, 0
+		return nil
+	}
+	//a prefix is compacted, a suffix is truncated
+	if min <= i.lowIndex {
+		i.lowIndex = max + 1
+	}
+	if max >= i.highIndex {
+		i.highIndex = min - 1
+	}
 	return nil
 }
 
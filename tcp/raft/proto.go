@@ -32,6 +32,7 @@ type VoteReq struct {
 type VoteResp struct {
 	Term    uint64 // currentTerm, for candidate to update itself
 	Granted bool   //true means candidate received vote
+	Voter   string //the server that votes, a vote is counted once
 }
 
 const (
@@ -2,10 +2,12 @@ package raft
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/lycying/mut"
 	"github.com/lycying/mut/codec/typelen"
-	"github.com/lycying/pitydb/dt"
 	"math/rand"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -18,32 +20,80 @@ const (
 	Shutdown
 )
 
+var ErrNotLeader = errors.New("raft: not the leader")
+
+var (
+	keyCurrentTerm = []byte("CurrentTerm")
+	keyVotedFor    = []byte("VotedFor")
+)
+
+const (
+	// electionTimeout is the least time a follower waits for the leader
+	electionTimeout = time.Millisecond * 100
+
+	// heartBeatInterval is far below electionTimeout so a follower never
+	// starts an election while the leader is alive
+	heartBeatInterval = time.Millisecond * 30
+
+	// maxAppendEntries is the most entries of an AppendEntriesReq
+	maxAppendEntries = 64
+)
+
 type Raft struct {
+	lock           sync.Mutex
 	cluster        *Cluster
 	logStore       LogStore
+	stable         StableStore
 	state          RaftState
 	cfg            *RaftConfig
-	heartBeatTimer *time.Timer
+	heartBeatTimer *time.Timer //the election timer of a follower or a candidate
+	leaderTimer    *time.Timer //the heartbeats of a leader
+	timerEpoch     uint64      //a timer of an older epoch was reset and does nothing
+	timeout        time.Duration
 
 	// The current term, cache of StableStore
 	currentTerm uint64
 
+	// The candidate voted in currentTerm, cache of StableStore
+	votedFor string
+
 	// Highest committed log entry
 	commitIndex uint64
 
 	// Last applied log to the FSM
 	lastApplied uint64
 
-	myLeader    string
-	voteCounter *dt.UInt32
+	myLeader string
+
+	// The servers that voted for this candidate in currentTerm
+	votes map[string]bool
+
+	// The next entry to send to each peer, kept by the leader
+	nextIndex map[string]uint64
+
+	// The last entry known to be replicated on each peer, kept by the leader
+	matchIndex map[string]uint64
 }
 
 type RaftConfig struct {
 	Srv   string
 	Peers []string
+
+	// LogStore keeps the log and StableStore the term and the vote, a
+	// FileStore is both. They are in memory if they are nil, and lost when
+	// the server stops.
+	LogStore    LogStore
+	StableStore StableStore
 }
 
 func NewRaft(raftCfg *RaftConfig) *Raft {
+	rf := newRaft(raftCfg)
+	rf.cluster.InitCluster(raftCfg.Srv, raftCfg.Peers)
+	return rf
+}
+
+// newRaft restores the term and the vote from the StableStore, the cluster is not started
+func newRaft(raftCfg *RaftConfig) *Raft {
 	rf := &Raft{}
 
 	cfg := mut.DefaultConfig()
@@ -51,72 +101,476 @@ func NewRaft(raftCfg *RaftConfig) *Raft {
 	cfg.SetCallback(rf)
 	rf.cfg = raftCfg
 
-	rf.logStore = NewInmemStore()
-	log0 := &Log{
-		Index: 1,
-		Term:  1,
-		Data:  "",
+	rf.logStore = raftCfg.LogStore
+	if nil == rf.logStore {
+		rf.logStore = NewInmemStore()
+	}
+	rf.stable = raftCfg.StableStore
+	if nil == rf.stable {
+		rf.stable = NewInmemStore()
+	}
+	if idx, _ := rf.logStore.LastIndex(); idx == 0 {
+		log0 := &Log{
+			Index: 1,
+			Term:  1,
+			Data:  "",
+		}
+		rf.logStore.StoreLog(log0)
 	}
-	rf.logStore.StoreLog(log0)
 
 	rf.lastApplied = 1
 	rf.commitIndex = 1
-	rf.currentTerm = 1
+	rf.currentTerm, _ = rf.stable.GetUint64(keyCurrentTerm)
+	if rf.currentTerm == 0 {
+		rf.currentTerm = 1
+	}
+	votedFor, _ := rf.stable.Get(keyVotedFor)
+	rf.votedFor = string(votedFor)
+	rf.timeout = electionTimeout
 
 	rf.myLeader = ""
-	rf.voteCounter = dt.NewUInt32()
 
 	rf.cluster = NewCluster(cfg)
-	rf.cluster.InitCluster(raftCfg.Srv, raftCfg.Peers)
 	return rf
 }
+
 func (r *Raft) Startup() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
 	r.SetState(Follower)
-	r.WaitForHeartBeat()
+	r.waitForHeartBeat()
 }
 
 func (r *Raft) SetState(state RaftState) {
 	r.state = state
 }
 
+func (r *Raft) GetState() RaftState {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.state
+}
+
+// GetCommitIndex returns the highest entry known to be committed
+func (r *Raft) GetCommitIndex() uint64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.commitIndex
+}
+
+// GetTerm returns the current term and the leader known in it
+func (r *Raft) GetTerm() (uint64, string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.currentTerm, r.myLeader
+}
+
 //heartbeat timeout -> elect
 //half than elect -> leader
 //leader -> send heartbeat
 
-func heartBeatSpec() time.Duration {
-	return time.Millisecond*100 + time.Millisecond*time.Duration(rand.Int63n(100))
-}
-func (r *Raft) WaitForHeartBeat() {
-	if nil == r.heartBeatTimer {
-		r.heartBeatTimer = time.AfterFunc(heartBeatSpec(), func() {
-			idx, _ := r.logStore.LastIndex()
-			lastLog, _ := r.logStore.GetLog(idx)
-			req := &VoteReq{
-				Candidate:    r.cfg.Srv,
-				Term:         r.currentTerm,
-				LastLogIndex: lastLog.Index,
-				LastLogTerm:  lastLog.Term,
-			}
-			p, _ := Marshal(req)
+// heartBeatSpec is random so the followers do not all become candidates at once
+func (r *Raft) heartBeatSpec() time.Duration {
+	return r.timeout + time.Duration(rand.Int63n(int64(r.timeout)))
+}
 
-			r.cluster.Broadcast(p)
+// waitForHeartBeat restarts the election timer, the lock must be held
+func (r *Raft) waitForHeartBeat() {
+	r.timerEpoch++
+	epoch := r.timerEpoch
+	if nil != r.heartBeatTimer {
+		r.heartBeatTimer.Stop()
+	}
+	r.heartBeatTimer = time.AfterFunc(r.heartBeatSpec(), func() {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		if epoch == r.timerEpoch {
+			r.startElection()
+		}
+	})
+}
 
-			r.myLeader = r.cfg.Srv
-			r.voteCounter.SetValue(uint32(1))
-		})
-	} else {
-		r.heartBeatTimer.Reset(heartBeatSpec())
+// quorum is the votes of a majority of the servers, this server included
+func (r *Raft) quorum() uint32 {
+	return uint32(len(r.cfg.Peers)+1)/2 + 1
+}
+
+// setTerm saves the term and clears the vote, the term is not changed if it
+// can not be saved. A vote that can not be cleared is kept, it only makes the
+// server refuse the other candidates of the term.
+func (r *Raft) setTerm(term uint64) error {
+	if err := r.stable.SetUint64(keyCurrentTerm, term); err != nil {
+		return err
+	}
+	r.currentTerm = term
+	return r.setVotedFor("")
+}
+
+// setVotedFor is saved before the vote is sent, a server never votes twice in a term
+func (r *Raft) setVotedFor(candidate string) error {
+	if err := r.stable.Set(keyVotedFor, []byte(candidate)); err != nil {
+		return err
+	}
+	r.votedFor = candidate
+	return nil
+}
+
+// isPeer reports whether srv is a server of the cluster other than this one
+func (r *Raft) isPeer(srv string) bool {
+	for _, peer := range r.cfg.Peers {
+		if strings.TrimSpace(peer) == srv {
+			return true
+		}
+	}
+	return false
+}
+
+// lastLog returns the index and the term of the last entry of the log
+func (r *Raft) lastLog() (uint64, uint64) {
+	idx, _ := r.logStore.LastIndex()
+	if idx == 0 {
+		return 0, 0
+	}
+	l, err := r.logStore.GetLog(idx)
+	if err != nil {
+		return idx, 0
+	}
+	return l.Index, l.Term
+}
+
+// startElection votes for itself in a new term and asks the peers for their votes
+func (r *Raft) startElection() {
+	if r.state == Leader || r.state == Shutdown {
+		return
+	}
+	err := r.setTerm(r.currentTerm + 1)
+	if nil == err {
+		err = r.setVotedFor(r.cfg.Srv)
+	}
+	if err != nil {
+		logger.Err(err, "raft# %v can not save the term %v", r.cfg.Srv, r.currentTerm+1)
+		r.SetState(Follower)
+		r.waitForHeartBeat()
+		return
 	}
+	r.SetState(Candidate)
+	r.votes = map[string]bool{r.cfg.Srv: true}
+	r.myLeader = ""
+	logger.Debug("raft# %v starts the election of term %v", r.cfg.Srv, r.currentTerm)
+	if uint32(len(r.votes)) >= r.quorum() {
+		r.becomeLeader()
+		return
+	}
+
+	lastIndex, lastTerm := r.lastLog()
+	req := &VoteReq{
+		Candidate:    r.cfg.Srv,
+		Term:         r.currentTerm,
+		LastLogIndex: lastIndex,
+		LastLogTerm:  lastTerm,
+	}
+	p, _ := Marshal(req)
+	r.cluster.Broadcast(p)
+
+	//a split vote elects nobody, so a new election starts on timeout
+	r.waitForHeartBeat()
 }
 
-func (r *Raft) processVoteReq(c *mut.Conn, req *VoteReq) {
+// stepDown follows the leader of term, a higher term clears the vote. The
+// server is a follower even if the term can not be saved, then the error is
+// returned and the request of the term must be refused.
+func (r *Raft) stepDown(term uint64) error {
+	var err error
+	if term > r.currentTerm {
+		if err = r.setTerm(term); err != nil {
+			logger.Err(err, "raft# %v can not save the term %v", r.cfg.Srv, term)
+		}
+	}
+	if r.state == Leader && nil != r.leaderTimer {
+		r.leaderTimer.Stop()
+	}
+	r.SetState(Follower)
+	r.waitForHeartBeat()
+	return err
+}
+
+func (r *Raft) becomeLeader() {
+	logger.Info("raft# %v is the leader of term %v", r.cfg.Srv, r.currentTerm)
+	r.SetState(Leader)
+	r.myLeader = r.cfg.Srv
+	r.timerEpoch++
+	if nil != r.heartBeatTimer {
+		r.heartBeatTimer.Stop()
+	}
+	lastIndex, _ := r.lastLog()
+	r.nextIndex = make(map[string]uint64)
+	r.matchIndex = make(map[string]uint64)
+	for _, peer := range r.cfg.Peers {
+		peer = strings.TrimSpace(peer)
+		r.nextIndex[peer] = lastIndex + 1
+		r.matchIndex[peer] = 0
+	}
+	r.sendHeartBeats()
+}
+
+// Apply appends data to the log of the leader and sends it to the followers,
+// it is committed when a majority has stored it, see GetCommitIndex
+func (r *Raft) Apply(data string) (uint64, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.state != Leader {
+		return 0, ErrNotLeader
+	}
+	lastIndex, _ := r.lastLog()
+	l := &Log{Index: lastIndex + 1, Term: r.currentTerm, Data: data}
+	if err := r.logStore.StoreLog(l); err != nil {
+		return 0, err
+	}
+	r.advanceCommitIndex()
+	for peer := range r.nextIndex {
+		r.replicate(peer)
+	}
+	return l.Index, nil
+}
+
+// appendEntriesReq returns the entries of peer from its nextIndex, nil if the
+// entry before them is not in the log any more
+func (r *Raft) appendEntriesReq(peer string) *AppendEntriesReq {
+	req := &AppendEntriesReq{
+		Term:              r.currentTerm,
+		Leader:            r.cfg.Srv,
+		PrevLogEntry:      r.nextIndex[peer] - 1,
+		LeaderCommitIndex: r.commitIndex,
+	}
+	if req.PrevLogEntry > 0 {
+		prev, err := r.logStore.GetLog(req.PrevLogEntry)
+		if err != nil {
+			logger.Err(err, "raft# the entry %v of %v is gone", req.PrevLogEntry, peer)
+			return nil
+		}
+		req.PrevLogTerm = prev.Term
+	}
+	lastIndex, _ := r.lastLog()
+	for idx := req.PrevLogEntry + 1; idx <= lastIndex && len(req.Entries) < maxAppendEntries; idx++ {
+		l, err := r.logStore.GetLog(idx)
+		if err != nil {
+			logger.Err(err, "raft# the entry %v of %v is gone", idx, peer)
+			return nil
+		}
+		req.Entries = append(req.Entries, l)
+	}
+	return req
+}
+
+// replicate sends peer the entries it misses, none is a heartbeat
+func (r *Raft) replicate(peer string) {
+	req := r.appendEntriesReq(peer)
+	if nil == req {
+		return
+	}
+	p, _ := Marshal(req)
+	r.cluster.Send(peer, p)
+}
+
+// advanceCommitIndex commits the highest entry of the current term stored by
+// a majority, an entry of an older term is only committed with it (§5.4.2)
+func (r *Raft) advanceCommitIndex() {
+	lastIndex, _ := r.lastLog()
+	for idx := lastIndex; idx > r.commitIndex; idx-- {
+		l, err := r.logStore.GetLog(idx)
+		if err != nil || l.Term != r.currentTerm {
+			return
+		}
+		count := uint32(1)
+		for _, match := range r.matchIndex {
+			if match >= idx {
+				count++
+			}
+		}
+		if count >= r.quorum() {
+			r.commitIndex = idx
+			return
+		}
+	}
+}
+
+// sendHeartBeats tells the followers the leader is alive until it steps down,
+// a follower that is behind gets its entries with the heartbeat
+func (r *Raft) sendHeartBeats() {
+	for peer := range r.nextIndex {
+		r.replicate(peer)
+	}
+
+	term := r.currentTerm
+	r.leaderTimer = time.AfterFunc(heartBeatInterval, func() {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		if r.state == Leader && r.currentTerm == term {
+			r.sendHeartBeats()
+		}
+	})
+}
+
+// processVoteReq grants the vote if the candidate is not behind in term or
+// log and no other candidate got the vote of the term (§5.2, §5.4)
+func (r *Raft) processVoteReq(req *VoteReq) *VoteResp {
+	r.lock.Lock()
+	defer r.lock.Unlock()
 	logger.Debug("%+v", req)
-	resp := &VoteResp{}
-	p, _ := Marshal(resp)
-	c.WriteAsync(p)
+
+	var err error
+	if req.Term > r.currentTerm {
+		err = r.stepDown(req.Term)
+	}
+	resp := &VoteResp{Term: r.currentTerm, Voter: r.cfg.Srv}
+	if req.Term < r.currentTerm || err != nil {
+		return resp
+	}
+	if r.votedFor != "" && r.votedFor != req.Candidate {
+		return resp
+	}
+	lastIndex, lastTerm := r.lastLog()
+	if req.LastLogTerm < lastTerm || (req.LastLogTerm == lastTerm && req.LastLogIndex < lastIndex) {
+		return resp
+	}
+	if err := r.setVotedFor(req.Candidate); err != nil {
+		logger.Err(err, "raft# %v can not save the vote for %v", r.cfg.Srv, req.Candidate)
+		return resp
+	}
+	resp.Granted = true
+	//a follower that just voted gives the candidate the time to win
+	r.waitForHeartBeat()
+	return resp
 }
-func (r *Raft) processVoteResp(c *mut.Conn, resp *VoteResp) {
+
+// processVoteResp makes the candidate the leader on the votes of a majority,
+// a voter is counted once however many of its responses arrive
+func (r *Raft) processVoteResp(resp *VoteResp) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
 	logger.Debug("%+v", resp)
+
+	if resp.Term > r.currentTerm {
+		r.stepDown(resp.Term)
+		return
+	}
+	if r.state != Candidate || resp.Term != r.currentTerm || !resp.Granted || !r.isPeer(resp.Voter) {
+		return
+	}
+	r.votes[resp.Voter] = true
+	if uint32(len(r.votes)) >= r.quorum() {
+		r.becomeLeader()
+	}
+}
+
+// processAppendEntriesReq follows a leader of a term that is not behind and
+// stores its entries if the log has the entry before them (§5.3). A conflicting
+// entry and all that follow it are deleted, LastLog is the last entry that
+// matches the leader, or where the leader should try again on a failure.
+func (r *Raft) processAppendEntriesReq(req *AppendEntriesReq) *AppendEntriesResp {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	lastIndex, _ := r.lastLog()
+	resp := &AppendEntriesResp{Term: r.currentTerm, LastLog: lastIndex}
+	if req.Term < r.currentTerm {
+		return resp
+	}
+	//a candidate of the same term lost the election
+	if req.Term > r.currentTerm || r.state != Follower {
+		if err := r.stepDown(req.Term); err != nil {
+			resp.Term = r.currentTerm
+			return resp
+		}
+	} else {
+		r.waitForHeartBeat()
+	}
+	r.myLeader = req.Leader
+	resp.Term = r.currentTerm
+
+	if req.PrevLogEntry > lastIndex {
+		return resp
+	}
+	if req.PrevLogEntry > 0 {
+		prev, err := r.logStore.GetLog(req.PrevLogEntry)
+		if err != nil || prev.Term != req.PrevLogTerm {
+			resp.LastLog = req.PrevLogEntry - 1
+			return resp
+		}
+	}
+
+	var entries []*Log
+	for i, e := range req.Entries {
+		if e.Index > lastIndex {
+			entries = req.Entries[i:]
+			break
+		}
+		if l, err := r.logStore.GetLog(e.Index); err == nil && l.Term == e.Term {
+			continue
+		}
+		if err := r.logStore.DeleteRange(e.Index, lastIndex); err != nil {
+			logger.Err(err, "raft# truncate the log from %v", e.Index)
+			resp.LastLog = e.Index - 1
+			return resp
+		}
+		lastIndex = e.Index - 1
+		entries = req.Entries[i:]
+		break
+	}
+	if len(entries) > 0 {
+		if err := r.logStore.StoreLogs(entries); err != nil {
+			logger.Err(err, "raft# store %v entries", len(entries))
+			resp.LastLog = lastIndex
+			return resp
+		}
+	}
+
+	resp.LastLog = req.PrevLogEntry + uint64(len(req.Entries))
+	if req.LeaderCommitIndex > r.commitIndex {
+		r.commitIndex = req.LeaderCommitIndex
+		if resp.LastLog < r.commitIndex {
+			r.commitIndex = resp.LastLog
+		}
+	}
+	resp.Success = true
+	return resp
+}
+
+// processAppendEntriesResp moves the indexes of peer forward on a success
+// and backs nextIndex up on a conflict to find where the logs match
+func (r *Raft) processAppendEntriesResp(peer string, resp *AppendEntriesResp) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if resp.Term > r.currentTerm {
+		r.stepDown(resp.Term)
+		return
+	}
+	next, ok := r.nextIndex[peer]
+	if r.state != Leader || resp.Term != r.currentTerm || !ok {
+		return
+	}
+	if resp.Success {
+		//a late response says less than one already seen
+		if resp.LastLog > r.matchIndex[peer] {
+			r.matchIndex[peer] = resp.LastLog
+			r.nextIndex[peer] = resp.LastLog + 1
+			r.advanceCommitIndex()
+		}
+		return
+	}
+	if next > 1 {
+		next--
+	}
+	if resp.LastLog+1 < next {
+		next = resp.LastLog + 1
+	}
+	if next <= r.matchIndex[peer] {
+		next = r.matchIndex[peer] + 1
+	}
+	r.nextIndex[peer] = next
+	r.replicate(peer)
 }
 
 func (r *Raft) OnConnect(c *mut.Conn) {
@@ -127,15 +581,39 @@ func (r *Raft) OnError(c *mut.Conn, err error) {
 }
 func (r *Raft) OnMessage(c *mut.Conn, p mut.Packet) {
 	packet := p.(*typelen.Packet)
+	var reply interface{}
 	switch packet.Type {
 	case TypeVoteReq:
 		req := &VoteReq{}
-		json.Unmarshal(packet.Data, req)
-		r.processVoteReq(c, req)
+		if err := json.Unmarshal(packet.Data, req); err != nil {
+			logger.Err(err, "raft# bad vote request")
+			return
+		}
+		reply = r.processVoteReq(req)
 	case TypeVoteResp:
 		resp := &VoteResp{}
-		json.Unmarshal(packet.Data, resp)
-		r.processVoteResp(c, resp)
+		if err := json.Unmarshal(packet.Data, resp); err != nil {
+			logger.Err(err, "raft# bad vote response")
+			return
+		}
+		r.processVoteResp(resp)
+	case TypeAppendEntriesReq:
+		req := &AppendEntriesReq{}
+		if err := json.Unmarshal(packet.Data, req); err != nil {
+			logger.Err(err, "raft# bad append entries request")
+			return
+		}
+		reply = r.processAppendEntriesReq(req)
+	case TypeAppendEntriesResp:
+		resp := &AppendEntriesResp{}
+		if err := json.Unmarshal(packet.Data, resp); err != nil {
+			logger.Err(err, "raft# bad append entries response")
+			return
+		}
+		r.processAppendEntriesResp(r.cluster.PeerOf(c), resp)
+	}
+	if nil != reply {
+		p, _ := Marshal(reply)
+		c.WriteAsync(p)
 	}
-
 }
@@ -1,6 +1,7 @@
 package raft
 
 import (
+	"errors"
 	"testing"
 	"time"
 )
@@ -33,3 +34,293 @@ func TestRaft(t *testing.T) {
 
 	time.Sleep(time.Hour)
 }
+
+func newTestRaft(srv string, peers ...string) *Raft {
+	r := newRaft(&RaftConfig{Srv: srv, Peers: peers})
+	//the tests run the elections themselves
+	r.timeout = time.Hour
+	return r
+}
+
+func TestRaft_Election(t *testing.T) {
+	a := newTestRaft("a", "b", "c")
+	b := newTestRaft("b", "a", "c")
+	c := newTestRaft("c", "a", "b")
+
+	a.lock.Lock()
+	a.startElection()
+	a.lock.Unlock()
+	if a.GetState() != Candidate || a.currentTerm != 2 || a.votedFor != "a" {
+		t.Fatal("a should be a candidate of term 2")
+	}
+	if term, _ := a.stable.GetUint64(keyCurrentTerm); term != 2 {
+		t.Fatal("the term should be saved")
+	}
+	req := &VoteReq{Term: 2, Candidate: "a", LastLogIndex: 1, LastLogTerm: 1}
+	resp := b.processVoteReq(req)
+	if !resp.Granted || b.currentTerm != 2 || b.votedFor != "a" {
+		t.Fatal("b should vote for a", resp)
+	}
+	if voted, _ := b.stable.Get(keyVotedFor); string(voted) != "a" {
+		t.Fatal("the vote should be saved")
+	}
+	if resp := b.processVoteReq(&VoteReq{Term: 2, Candidate: "c", LastLogIndex: 1, LastLogTerm: 1}); resp.Granted {
+		t.Fatal("b should vote once in a term")
+	}
+	if again := b.processVoteReq(req); !again.Granted {
+		t.Fatal("a repeated request should be granted again")
+	}
+
+	a.processVoteResp(&VoteResp{Term: 1, Granted: true, Voter: "c"})
+	if a.GetState() != Candidate {
+		t.Fatal("a vote of an old term should not count")
+	}
+	a.processVoteResp(resp)
+	if term, leader := a.GetTerm(); a.GetState() != Leader || term != 2 || leader != "a" {
+		t.Fatal("a should be the leader on a majority")
+	}
+
+	if resp := c.processAppendEntriesReq(&AppendEntriesReq{Term: 2, Leader: "a"}); !resp.Success || c.currentTerm != 2 {
+		t.Fatal("c should follow the leader of a higher term")
+	}
+	if _, leader := c.GetTerm(); leader != "a" || c.GetState() != Follower {
+		t.Fatal("c should know the leader")
+	}
+	if resp := c.processAppendEntriesReq(&AppendEntriesReq{Term: 1, Leader: "b"}); resp.Success || resp.Term != 2 {
+		t.Fatal("a leader of an old term should be rejected")
+	}
+
+	a.processAppendEntriesResp("b", &AppendEntriesResp{Term: 3})
+	if a.GetState() != Follower || a.currentTerm != 3 || a.votedFor != "" {
+		t.Fatal("the leader should step down on a higher term")
+	}
+}
+
+// failStable is a StableStore that can not save
+type failStable struct {
+	*InmemStore
+}
+
+func (f failStable) Set(key []byte, val []byte) error {
+	return errors.New("disk full")
+}
+
+func (f failStable) SetUint64(key []byte, val uint64) error {
+	return errors.New("disk full")
+}
+
+func TestRaft_Votes(t *testing.T) {
+	a := newTestRaft("a", "b", "c", "d", "e")
+	a.lock.Lock()
+	a.startElection()
+	a.lock.Unlock()
+	granted := &VoteResp{Term: 2, Granted: true, Voter: "b"}
+	a.processVoteResp(granted)
+	a.processVoteResp(granted)
+	a.processVoteResp(&VoteResp{Term: 2, Granted: true})
+	a.processVoteResp(&VoteResp{Term: 2, Granted: true, Voter: "x"})
+	if a.GetState() != Candidate {
+		t.Fatal("a voter should be counted once, and only a peer is a voter")
+	}
+	a.processVoteResp(&VoteResp{Term: 2, Granted: true, Voter: "c"})
+	if a.GetState() != Leader {
+		t.Fatal("a should be the leader on 3 votes of 5")
+	}
+
+	//a vote or a term that is not saved is refused
+	b := newRaft(&RaftConfig{Srv: "b", Peers: []string{"a", "c"}, StableStore: failStable{NewInmemStore()}})
+	b.timeout = time.Hour
+	if resp := b.processVoteReq(&VoteReq{Term: 2, Candidate: "a", LastLogIndex: 1, LastLogTerm: 1}); resp.Granted || b.currentTerm != 1 {
+		t.Fatal("b should not vote if the term is not saved")
+	}
+	if resp := b.processAppendEntriesReq(&AppendEntriesReq{Term: 2, Leader: "a"}); resp.Success {
+		t.Fatal("b should not follow a term that is not saved")
+	}
+	b.lock.Lock()
+	b.startElection()
+	b.lock.Unlock()
+	if b.GetState() != Follower || b.currentTerm != 1 {
+		t.Fatal("b should not start an election it can not save")
+	}
+}
+
+func TestRaft_VoteUpToDate(t *testing.T) {
+	r := newTestRaft("a", "b", "c")
+	r.logStore.StoreLogs([]*Log{{Index: 2, Term: 2}, {Index: 3, Term: 3}})
+	cases := []struct {
+		index   uint64
+		term    uint64
+		granted bool
+	}{
+		{5, 2, false},
+		{2, 3, false},
+		{3, 3, true},
+	}
+	for i, c := range cases {
+		term := r.currentTerm + 1
+		resp := r.processVoteReq(&VoteReq{Term: term, Candidate: "b", LastLogIndex: c.index, LastLogTerm: c.term})
+		if resp.Granted != c.granted || resp.Term != term {
+			t.Fatal(i, "the vote should be", c.granted)
+		}
+	}
+
+	store := NewInmemStore()
+	store.SetUint64(keyCurrentTerm, 7)
+	store.Set(keyVotedFor, []byte("c"))
+	again := newRaft(&RaftConfig{Srv: "a", Peers: []string{"b", "c"}, LogStore: store, StableStore: store})
+	again.timeout = time.Hour
+	if again.currentTerm != 7 || again.votedFor != "c" {
+		t.Fatal("the term and the vote should be restored")
+	}
+	if resp := again.processVoteReq(&VoteReq{Term: 7, Candidate: "b", LastLogIndex: 1, LastLogTerm: 1}); resp.Granted {
+		t.Fatal("a restored vote should not be given again")
+	}
+}
+
+func TestRaft_SingleNode(t *testing.T) {
+	r := newTestRaft("a")
+	r.lock.Lock()
+	r.startElection()
+	r.lock.Unlock()
+	if r.GetState() != Leader {
+		t.Fatal("a single server should elect itself")
+	}
+}
+
+// syncPeer sends peer its entries from leader and gives the leader the response
+func syncPeer(leader *Raft, peer string, follower *Raft) *AppendEntriesResp {
+	leader.lock.Lock()
+	req := leader.appendEntriesReq(peer)
+	leader.lock.Unlock()
+	resp := follower.processAppendEntriesReq(req)
+	leader.processAppendEntriesResp(peer, resp)
+	return resp
+}
+
+func newTestLeader(t *testing.T) (*Raft, *Raft, *Raft) {
+	a := newTestRaft("a", "b", "c")
+	b := newTestRaft("b", "a", "c")
+	c := newTestRaft("c", "a", "b")
+	a.lock.Lock()
+	a.startElection()
+	a.lock.Unlock()
+	a.processVoteResp(b.processVoteReq(&VoteReq{Term: 2, Candidate: "a", LastLogIndex: 1, LastLogTerm: 1}))
+	if a.GetState() != Leader {
+		t.Fatal("a should be the leader")
+	}
+	return a, b, c
+}
+
+func TestRaft_Replicate(t *testing.T) {
+	a, b, c := newTestLeader(t)
+	if _, err := b.Apply("x"); err != ErrNotLeader {
+		t.Fatal("a follower should not append")
+	}
+	for i := 0; i < 100; i++ {
+		if idx, err := a.Apply("x"); err != nil || idx != uint64(i+2) {
+			t.Fatal("the entry should be appended", idx, err)
+		}
+	}
+	if a.GetCommitIndex() != 1 {
+		t.Fatal("nothing should be committed without a majority")
+	}
+
+	if resp := syncPeer(a, "b", b); !resp.Success || resp.LastLog != 65 {
+		t.Fatal("b should store a batch of entries", resp)
+	}
+	if a.GetCommitIndex() != 65 || a.matchIndex["b"] != 65 || a.nextIndex["b"] != 66 {
+		t.Fatal("a majority should commit the entries", a.commitIndex)
+	}
+	syncPeer(a, "b", b)
+	if last, _ := b.logStore.LastIndex(); last != 101 || a.GetCommitIndex() != 101 || b.GetCommitIndex() != 65 {
+		t.Fatal("b should have every entry")
+	}
+	syncPeer(a, "b", b)
+	if b.GetCommitIndex() != 101 {
+		t.Fatal("b should learn the commit index with a heartbeat")
+	}
+
+	//a stale response does not move the indexes back
+	a.processAppendEntriesResp("b", &AppendEntriesResp{Term: 2, Success: true, LastLog: 10})
+	if a.matchIndex["b"] != 101 {
+		t.Fatal("a late response should be ignored")
+	}
+
+	for i := 0; i < 3; i++ {
+		syncPeer(a, "c", c)
+	}
+	if last, _ := c.logStore.LastIndex(); last != 101 || c.GetCommitIndex() != 101 {
+		t.Fatal("c should catch up", last)
+	}
+}
+
+func TestRaft_Conflict(t *testing.T) {
+	a, b, _ := newTestLeader(t)
+	a.Apply("x")
+	a.Apply("y")
+
+	//b kept entries of a term 1 leader that were never committed
+	b.logStore.StoreLogs([]*Log{{Index: 2, Term: 1, Data: "old"}, {Index: 3, Term: 1}, {Index: 4, Term: 1}, {Index: 5, Term: 1}})
+	a.lock.Lock()
+	a.nextIndex["b"] = 4
+	a.lock.Unlock()
+	if resp := syncPeer(a, "b", b); resp.Success || a.nextIndex["b"] != 3 {
+		t.Fatal("a should back up on a conflict", resp, a.nextIndex["b"])
+	}
+	if resp := syncPeer(a, "b", b); resp.Success || a.nextIndex["b"] != 2 {
+		t.Fatal("a should back up until the logs match", resp, a.nextIndex["b"])
+	}
+	if resp := syncPeer(a, "b", b); !resp.Success || resp.LastLog != 3 {
+		t.Fatal("b should take the entries of a", resp)
+	}
+	if last, _ := b.logStore.LastIndex(); last != 3 {
+		t.Fatal("the conflicting suffix should be deleted", last)
+	}
+	if l, _ := b.logStore.GetLog(2); l.Data != "x" || l.Term != 2 {
+		t.Fatal("the entry should be the one of the leader", l)
+	}
+	if _, err := b.logStore.GetLog(4); nil == err {
+		t.Fatal("the entry 4 should be gone")
+	}
+	if a.GetCommitIndex() != 3 {
+		t.Fatal("the entries should be committed")
+	}
+
+	//a follower far behind tells the leader where its log ends
+	c := newTestRaft("c", "a", "b")
+	a.lock.Lock()
+	a.nextIndex["c"] = 4
+	a.lock.Unlock()
+	if resp := syncPeer(a, "c", c); resp.Success || resp.LastLog != 1 || a.nextIndex["c"] != 2 {
+		t.Fatal("a should jump back to the end of the log of c", resp)
+	}
+	if resp := syncPeer(a, "c", c); !resp.Success || resp.LastLog != 3 {
+		t.Fatal("c should catch up", resp)
+	}
+
+	//an old leader steps down on the response of a newer term
+	a.processAppendEntriesResp("c", &AppendEntriesResp{Term: 5})
+	if a.GetState() != Follower {
+		t.Fatal("a should step down")
+	}
+}
+
+func TestInmemStore_DeleteRange(t *testing.T) {
+	s := NewInmemStore()
+	s.StoreLogs([]*Log{{Index: 1}, {Index: 2}, {Index: 3}, {Index: 4}})
+	s.DeleteRange(3, 4)
+	if first, _ := s.FirstIndex(); first != 1 {
+		t.Fatal("a truncated suffix should keep the first index")
+	}
+	if last, _ := s.LastIndex(); last != 2 {
+		t.Fatal("a truncated suffix should move the last index")
+	}
+	s.DeleteRange(1, 1)
+	if first, _ := s.FirstIndex(); first != 2 {
+		t.Fatal("a compacted prefix should move the first index")
+	}
+	s.DeleteRange(2, 2)
+	if first, _ := s.FirstIndex(); first != 0 {
+		t.Fatal("an empty store should have no index")
+	}
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"errors"
+	"hash/fnv"
+	"math"
+)
+
+// hllPrecision is the number of bits used to select a register
+const hllPrecision = 12
+const hllRegisters = 1 << hllPrecision
+
+// HyperLogLog is a sketch that estimates the number of distinct values
+// http://algo.inria.fr/flajolet/Publications/FlFuGaMe07.pdf
+type HyperLogLog struct {
+	registers []byte
+}
+
+func NewHyperLogLog() *HyperLogLog {
+	return &HyperLogLog{
+		registers: make([]byte, hllRegisters),
+	}
+}
+
+// NewHyperLogLogFrom restores the sketch from the bytes returned by Bytes
+func NewHyperLogLogFrom(b []byte) (*HyperLogLog, error) {
+	if len(b) != hllRegisters {
+		return nil, errors.New("bad hyperloglog registers")
+	}
+	h := NewHyperLogLog()
+	copy(h.registers, b)
+	return h, nil
+}
+
+// mix64 spreads the bits of fnv, which is not good enough for the sketch alone
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+func (h *HyperLogLog) Add(b []byte) {
+	f := fnv.New64a()
+	f.Write(b)
+	x := mix64(f.Sum64())
+
+	idx := x >> (64 - hllPrecision)
+	w := x<<hllPrecision | 1<<(hllPrecision-1)
+	rank := byte(1)
+	for w&(1<<63) == 0 {
+		rank++
+		w <<= 1
+	}
+	if rank > h.registers[idx] {
+		h.registers[idx] = rank
+	}
+}
+
+// Merge folds other into h, the result estimates the union
+func (h *HyperLogLog) Merge(other *HyperLogLog) {
+	for i, r := range other.registers {
+		if r > h.registers[i] {
+			h.registers[i] = r
+		}
+	}
+}
+
+func (h *HyperLogLog) Estimate() uint64 {
+	m := float64(hllRegisters)
+	sum := 0.0
+	zeros := 0
+	for _, r := range h.registers {
+		sum += math.Pow(2, -float64(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	alpha := 0.7213 / (1 + 1.079/m)
+	est := alpha * m * m / sum
+
+	//small range correction
+	if est <= 2.5*m && zeros > 0 {
+		est = m * math.Log(m/float64(zeros))
+	}
+	return uint64(est + 0.5)
+}
+
+func (h *HyperLogLog) Bytes() []byte {
+	ret := make([]byte, hllRegisters)
+	copy(ret, h.registers)
+	return ret
+}
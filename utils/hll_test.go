@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHyperLogLog(t *testing.T) {
+	h := NewHyperLogLog()
+	for i := 0; i < 100000; i++ {
+		h.Add([]byte(fmt.Sprintf("key-%d", i%20000)))
+	}
+	est := h.Estimate()
+	if est < 18000 || est > 22000 {
+		t.Fatal("estimate should be near 20000, but ", est)
+	}
+
+	h2, err := NewHyperLogLogFrom(h.Bytes())
+	if err != nil || h2.Estimate() != est {
+		t.Fatal("the restored sketch should have the same estimate")
+	}
+
+	small := NewHyperLogLog()
+	for i := 0; i < 10; i++ {
+		small.Add([]byte{byte(i)})
+	}
+	if small.Estimate() != 10 {
+		t.Fatal("estimate should be 10, but ", small.Estimate())
+	}
+}
@@ -0,0 +1,162 @@
+package vec
+
+import (
+	"github.com/lycying/pitydb/dt"
+	"github.com/lycying/pitydb/storage/yard"
+)
+
+// DefaultBatchSize is the rows of a batch made by Scan
+const DefaultBatchSize = 1024
+
+// Selection is the indexes of the rows of a batch in order
+type Selection []int32
+
+// Batch is the rows of a RowMeta by column, the vector of a cell is at the
+// position of its CellMeta
+type Batch struct {
+	meta *dt.RowMeta
+	keys []uint32
+	vecs []*Vector
+}
+
+func NewBatch(meta *dt.RowMeta, capacity int) *Batch {
+	b := &Batch{
+		meta: meta,
+		keys: make([]uint32, 0, capacity),
+		vecs: make([]*Vector, meta.GetCellSize()),
+	}
+	for i, item := range meta.GetItems() {
+		if nil != item {
+			b.vecs[i] = NewVector(item, capacity)
+		}
+	}
+	return b
+}
+
+// FromRows makes a batch of the rows, the rows must be of meta
+func FromRows(meta *dt.RowMeta, rows []*yard.Row) (*Batch, error) {
+	b := NewBatch(meta, len(rows))
+	for _, r := range rows {
+		if err := b.Append(r); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// Scan visits the live rows of tree by batches of size rows until fn returns
+// false, fn runs under the read lock of the tree so it must not write the tree
+func Scan(tree *yard.PageTree, meta *dt.RowMeta, size int, fn func(b *Batch) bool) error {
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+	var err error
+	b := NewBatch(meta, size)
+	more := true
+	tree.Scan(func(r *yard.Row) bool {
+		if err = b.Append(r); err != nil {
+			return false
+		}
+		if b.Len() == size {
+			more = fn(b)
+			b = NewBatch(meta, size)
+		}
+		return more
+	})
+	if nil == err && more && b.Len() > 0 {
+		fn(b)
+	}
+	return err
+}
+
+func (b *Batch) GetMeta() *dt.RowMeta {
+	return b.meta
+}
+
+func (b *Batch) Len() int {
+	return len(b.keys)
+}
+
+func (b *Batch) Keys() []uint32 {
+	return b.keys
+}
+
+// Vector returns the vector of the cell at pos, nil if there is no such cell
+func (b *Batch) Vector(pos int) *Vector {
+	if pos < 0 || pos >= len(b.vecs) {
+		return nil
+	}
+	return b.vecs[pos]
+}
+
+// Column returns the vector of the cell name, nil if there is no such cell
+func (b *Batch) Column(name string) *Vector {
+	item := b.meta.GetCellMeta(name)
+	if nil == item {
+		return nil
+	}
+	return b.Vector(item.GetPos())
+}
+
+// Append adds the cells of r, a cell that is not in r is NULL
+func (b *Batch) Append(r *yard.Row) error {
+	for i, v := range b.vecs {
+		if nil == v {
+			continue
+		}
+		if err := v.Append(r.GetCellByPos(i)); err != nil {
+			//the vectors before i are one row longer
+			for _, o := range b.vecs[:i] {
+				if nil != o {
+					o.truncate(len(b.keys))
+				}
+			}
+			return err
+		}
+	}
+	b.keys = append(b.keys, r.GetKey())
+	return nil
+}
+
+// Row returns the row i of the batch, the cells are boxed again
+func (b *Batch) Row(i int) *yard.Row {
+	cells := make([]dt.DtRefer, len(b.vecs))
+	for pos, v := range b.vecs {
+		if nil != v {
+			cells[pos] = v.Get(i)
+		}
+	}
+	return yard.NewRowWithCells(b.meta, b.keys[i], cells)
+}
+
+// ToRows returns the rows of the batch
+func (b *Batch) ToRows() []*yard.Row {
+	rows := make([]*yard.Row, b.Len())
+	for i := range rows {
+		rows[i] = b.Row(i)
+	}
+	return rows
+}
+
+// Take returns a batch of the rows of sel
+func (b *Batch) Take(sel Selection) *Batch {
+	o := &Batch{meta: b.meta, keys: make([]uint32, len(sel)), vecs: make([]*Vector, len(b.vecs))}
+	for j, i := range sel {
+		o.keys[j] = b.keys[i]
+	}
+	for pos, v := range b.vecs {
+		if nil != v {
+			o.vecs[pos] = v.Take(sel)
+		}
+	}
+	return o
+}
+
+// all returns the selection of every row of the batch
+func (b *Batch) all() Selection {
+	sel := make(Selection, b.Len())
+	for i := range sel {
+		sel[i] = int32(i)
+	}
+	return sel
+}
@@ -0,0 +1,121 @@
+package vec
+
+import (
+	"fmt"
+	"github.com/lycying/pitydb/dt"
+	"github.com/lycying/pitydb/storage/yard"
+	"testing"
+)
+
+// newOrderMeta has a cell of every kind of vector
+func newOrderMeta() *dt.RowMeta {
+	meta := dt.NewRowMeta()
+	meta.AddCellMeta(dt.NewCellMetaRaw(0, dt.UInt32Type, "id", "", nil))
+	meta.AddCellMeta(dt.NewCellMetaRaw(1, dt.Int32Type, "qty", "", nil))
+	meta.AddCellMeta(dt.NewCellMetaRaw(2, dt.Float64Type, "price", "", nil))
+	meta.AddCellMeta(dt.NewCellMetaRaw(3, dt.StringType, "name", "", nil).WithCollation(dt.CollateBinary))
+	meta.AddCellMeta(dt.NewCellMetaRaw(4, dt.BoolType, "paid", "", nil))
+	meta.AddCellMeta(dt.NewCellMetaRaw(5, dt.DecimalType, "cost", "", nil).WithDecimal(10, 2))
+	return meta
+}
+
+// newOrderRows makes n rows, every 5th qty and every 7th name is NULL if nulls
+func newOrderRows(meta *dt.RowMeta, n int, nulls bool) []*yard.Row {
+	rows := make([]*yard.Row, n)
+	for i := range rows {
+		cost := dt.NewDecimalWithPrecision(10, 2)
+		cost.SetValue(fmt.Sprintf("%d.25", i))
+		cells := []dt.DtRefer{
+			dt.ValidNewUInt32(uint32(i + 1)),
+			dt.ValidNewInt32(int32(i % 10)),
+			dt.ValidNewFloat64(float64(i) / 2),
+			dt.ValidNewString(fmt.Sprintf("item%02d", i)),
+			dt.ValidNewBool(i%2 == 0),
+			cost,
+		}
+		if nulls && i%5 == 4 {
+			cells[1] = nil
+		}
+		if nulls && i%7 == 6 {
+			cells[3] = nil
+		}
+		rows[i] = yard.NewRowWithCells(meta, uint32(i+1), cells)
+	}
+	return rows
+}
+
+func sprintRow(r *yard.Row) string {
+	s := fmt.Sprint(r.GetKey())
+	for pos := range r.GetMeta().GetItems() {
+		if cell := r.GetCellByPos(pos); nil != cell {
+			s += fmt.Sprint(" ", cell.GetValue())
+		} else {
+			s += " NULL"
+		}
+	}
+	return s
+}
+
+func TestBatch_Rows(t *testing.T) {
+	meta := newOrderMeta()
+	rows := newOrderRows(meta, 20, true)
+	b, err := FromRows(meta, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != 20 || b.Column("qty").Kind() != Int64Kind || b.Column("name").Kind() != StringKind ||
+		b.Column("cost").Kind() != AnyKind || nil != b.Column("nope") {
+		t.Fatal("the vectors should be typed by the cells")
+	}
+	if b.Column("qty").Int64s[3] != 3 || !b.Column("qty").IsNull(4) || b.Column("name").String(5) != "item05" {
+		t.Fatal("the values should be in the vectors")
+	}
+	for i, r := range b.ToRows() {
+		if sprintRow(r) != sprintRow(rows[i]) {
+			t.Fatal("the row should be the same, ", sprintRow(r), " and ", sprintRow(rows[i]))
+		}
+	}
+
+	//a row that can not be appended leaves the batch as it was
+	bad := yard.NewRowWithCells(meta, 99, []dt.DtRefer{dt.ValidNewUInt32(99), dt.ValidNewInt32(1), dt.ValidNewString("x")})
+	if err := b.Append(bad); err == nil {
+		t.Fatal("a text should not be a float")
+	}
+	if b.Len() != 20 || b.Column("qty").Len() != 20 {
+		t.Fatal("the batch should not be changed")
+	}
+	if err := b.Column("qty").Append(dt.ValidInt64(1 << 40)); err != dt.ErrCastType {
+		t.Fatal("a value the Int32 can not hold should not be appended", err)
+	}
+
+	taken := b.Take(Selection{6, 4, 0})
+	if taken.Len() != 3 || sprintRow(taken.Row(0)) != sprintRow(rows[6]) || sprintRow(taken.Row(1)) != sprintRow(rows[4]) {
+		t.Fatal("the rows of the selection should be taken")
+	}
+}
+
+func TestScan(t *testing.T) {
+	meta := newOrderMeta()
+	tree := yard.NewPageTree(meta, nil)
+	for _, r := range newOrderRows(meta, 25, false) {
+		if err := tree.Insert(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var sizes []int
+	err := Scan(tree, meta, 10, func(b *Batch) bool {
+		sizes = append(sizes, b.Len())
+		return true
+	})
+	if err != nil || fmt.Sprint(sizes) != "[10 10 5]" {
+		t.Fatal("the rows should be scanned by batches", sizes, err)
+	}
+	sizes = nil
+	Scan(tree, meta, 10, func(b *Batch) bool {
+		sizes = append(sizes, b.Len())
+		return false
+	})
+	if fmt.Sprint(sizes) != "[10]" {
+		t.Fatal("the scan should stop", sizes)
+	}
+}
@@ -0,0 +1,279 @@
+package vec
+
+import (
+	"bytes"
+	"github.com/lycying/pitydb/dt"
+	"github.com/lycying/pitydb/expr"
+	"math"
+)
+
+// batchRow is the row i of a batch for the expressions that have no kernel
+type batchRow struct {
+	b *Batch
+	i int
+}
+
+func (r *batchRow) GetCellByName(name string) (dt.DtRefer, error) {
+	v := r.b.Column(name)
+	if nil == v {
+		return nil, expr.ErrNoColumn
+	}
+	return v.Get(r.i), nil
+}
+
+// Filter returns the rows of the batch where cond is true, a NULL condition
+// is not true. A comparison of a column with a constant, IS NULL, AND and OR
+// run on the vectors, the other conditions are evaluated row by row.
+func Filter(b *Batch, cond expr.Expr) (Selection, error) {
+	return filter(b, cond, b.all())
+}
+
+// filter returns the rows of sel where cond is true
+func filter(b *Batch, cond expr.Expr, sel Selection) (Selection, error) {
+	switch e := cond.(type) {
+	case *expr.Binary:
+		switch e.Op {
+		case expr.OpAnd:
+			l, err := filter(b, e.Left, sel)
+			if err != nil {
+				return nil, err
+			}
+			return filter(b, e.Right, l)
+		case expr.OpOr:
+			l, err := filter(b, e.Left, sel)
+			if err != nil {
+				return nil, err
+			}
+			r, err := filter(b, e.Right, minus(sel, l))
+			if err != nil {
+				return nil, err
+			}
+			return union(l, r), nil
+		}
+		if out, ok := compareKernel(b, e, sel); ok {
+			return out, nil
+		}
+	case *expr.IsNull:
+		if c, ok := e.X.(*expr.Column); ok {
+			if v := b.Column(c.Name); nil != v {
+				out := sel[:0:0]
+				for _, i := range sel {
+					if v.IsNull(int(i)) != e.Not {
+						out = append(out, i)
+					}
+				}
+				return out, nil
+			}
+		}
+	}
+	return filterRows(b, cond, sel)
+}
+
+func filterRows(b *Batch, cond expr.Expr, sel Selection) (Selection, error) {
+	var out Selection
+	row := &batchRow{b: b}
+	for _, i := range sel {
+		row.i = int(i)
+		ok, err := expr.EvalBool(cond, row)
+		if err != nil {
+			return nil, err
+		}
+		if nil != ok && *ok {
+			out = append(out, i)
+		}
+	}
+	return out, nil
+}
+
+// flip returns the op of the sides swapped, 1 < a is a > 1
+func flip(op expr.Op) expr.Op {
+	switch op {
+	case expr.OpLt:
+		return expr.OpGt
+	case expr.OpLe:
+		return expr.OpGe
+	case expr.OpGt:
+		return expr.OpLt
+	case expr.OpGe:
+		return expr.OpLe
+	}
+	return op
+}
+
+func match(op expr.Op, c int) bool {
+	switch op {
+	case expr.OpEq:
+		return c == 0
+	case expr.OpNe:
+		return c != 0
+	case expr.OpLt:
+		return c < 0
+	case expr.OpLe:
+		return c <= 0
+	case expr.OpGt:
+		return c > 0
+	case expr.OpGe:
+		return c >= 0
+	}
+	return false
+}
+
+// compareKernel runs a column op constant on the vector, ok is false if
+// there is no kernel for the types
+func compareKernel(b *Batch, e *expr.Binary, sel Selection) (Selection, bool) {
+	col, lit, op := asColumnLiteral(e)
+	if nil == col {
+		return nil, false
+	}
+	v := b.Column(col.Name)
+	if nil == v {
+		return nil, false
+	}
+	if nil == lit.Value {
+		//a comparison with NULL is never true
+		return Selection{}, true
+	}
+	switch v.kind {
+	case Int64Kind:
+		if c, ok := lit.Value.GetValue().(int64); ok {
+			return compareInt64s(v, op, c, sel), true
+		}
+	case Float64Kind:
+		switch c := lit.Value.GetValue().(type) {
+		case float64:
+			return compareFloat64s(v, op, c, sel), true
+		case int64:
+			//exact only in the 53 bits of a float64
+			if c >= -1<<53 && c <= 1<<53 {
+				return compareFloat64s(v, op, float64(c), sel), true
+			}
+		}
+	case StringKind:
+		//the bytes are the order of the binary collation only
+		c, ok := lit.Value.GetValue().(string)
+		if ok && v.meta.GetCollation() == dt.CollateBinary {
+			return compareStrings(v, op, c, sel), true
+		}
+	}
+	return nil, false
+}
+
+func asColumnLiteral(e *expr.Binary) (*expr.Column, *expr.Literal, expr.Op) {
+	if col, ok := e.Left.(*expr.Column); ok {
+		if lit, ok := e.Right.(*expr.Literal); ok {
+			return col, lit, e.Op
+		}
+	}
+	if col, ok := e.Right.(*expr.Column); ok {
+		if lit, ok := e.Left.(*expr.Literal); ok {
+			return col, lit, flip(e.Op)
+		}
+	}
+	return nil, nil, e.Op
+}
+
+func compareInt64s(v *Vector, op expr.Op, c int64, sel Selection) Selection {
+	out := make(Selection, 0, len(sel))
+	for _, i := range sel {
+		x := v.Int64s[i]
+		var ok bool
+		switch op {
+		case expr.OpEq:
+			ok = x == c
+		case expr.OpNe:
+			ok = x != c
+		case expr.OpLt:
+			ok = x < c
+		case expr.OpLe:
+			ok = x <= c
+		case expr.OpGt:
+			ok = x > c
+		case expr.OpGe:
+			ok = x >= c
+		}
+		if ok && !v.IsNull(int(i)) {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// compareFloat64s puts NaN after all the numbers like dt.Float64
+func compareFloat64s(v *Vector, op expr.Op, c float64, sel Selection) Selection {
+	out := make(Selection, 0, len(sel))
+	for _, i := range sel {
+		x := v.Float64s[i]
+		var cmp int
+		switch {
+		case math.IsNaN(x) || math.IsNaN(c):
+			cmp = compareNaN(math.IsNaN(x), math.IsNaN(c))
+		case x < c:
+			cmp = -1
+		case x > c:
+			cmp = 1
+		}
+		if match(op, cmp) && !v.IsNull(int(i)) {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+func compareNaN(a bool, b bool) int {
+	switch {
+	case a && b:
+		return 0
+	case a:
+		return 1
+	}
+	return -1
+}
+
+func compareStrings(v *Vector, op expr.Op, c string, sel Selection) Selection {
+	out := make(Selection, 0, len(sel))
+	cb := []byte(c)
+	for _, i := range sel {
+		cmp := bytes.Compare(v.Data[v.Offsets[i]:v.Offsets[i+1]], cb)
+		if match(op, cmp) && !v.IsNull(int(i)) {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// minus returns the rows of a that are not in b, both are in order
+func minus(a Selection, b Selection) Selection {
+	out := make(Selection, 0, len(a)-len(b))
+	j := 0
+	for _, i := range a {
+		for j < len(b) && b[j] < i {
+			j++
+		}
+		if j < len(b) && b[j] == i {
+			continue
+		}
+		out = append(out, i)
+	}
+	return out
+}
+
+// union returns the rows of a and b in order
+func union(a Selection, b Selection) Selection {
+	out := make(Selection, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case j >= len(b) || (i < len(a) && a[i] < b[j]):
+			out = append(out, a[i])
+			i++
+		case i >= len(a) || b[j] < a[i]:
+			out = append(out, b[j])
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
@@ -0,0 +1,48 @@
+package vec
+
+import (
+	"fmt"
+	"github.com/lycying/pitydb/expr"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	meta := newOrderMeta()
+	b, _ := FromRows(meta, newOrderRows(meta, 40, true))
+	cases := []struct {
+		cond string
+		want int
+	}{
+		{"qty >= 8", 4},
+		{"3 > qty", 12},
+		{"qty = NULL", 0},
+		{"price < 2.5 OR price > 18", 8},
+		{"price <= 3 AND qty <> 2", 5},
+		{"name > 'item3' OR name IS NULL", 14},
+		{"qty IS NULL AND NOT paid", 4},
+		{"cost > 30 AND qty IN (1, 2)", 2},
+		{"qty * 2 > 15 OR name = 'item00'", 5},
+	}
+	for _, c := range cases {
+		cond := expr.MustParse(c.cond)
+		sel, err := Filter(b, cond)
+		if err != nil {
+			t.Fatal(c.cond, err)
+		}
+		//the kernels find the rows found row by row
+		rows, _ := filterRows(b, cond, b.all())
+		if len(sel) != c.want || fmt.Sprint(sel) != fmt.Sprint(rows) {
+			t.Fatal(c.cond, " should select ", c.want, " rows ", rows, ",but ", sel)
+		}
+	}
+	if _, err := Filter(b, expr.MustParse("qty / 0 > 1")); err == nil {
+		t.Fatal("the error of a row should be returned")
+	}
+}
+
+func TestSelection(t *testing.T) {
+	a, b := Selection{1, 3, 5, 7}, Selection{2, 3, 8}
+	if fmt.Sprint(union(a, b)) != "[1 2 3 5 7 8]" || fmt.Sprint(minus(a, b)) != "[1 5 7]" {
+		t.Fatal("the selections should be merged in order")
+	}
+}
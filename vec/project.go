@@ -0,0 +1,186 @@
+package vec
+
+import (
+	"github.com/lycying/pitydb/dt"
+	"github.com/lycying/pitydb/expr"
+)
+
+// Projection is a cell of the batch made by Project
+type Projection struct {
+	Name string
+	Expr expr.Expr
+}
+
+// Project returns a batch of a cell for each projection, the type of a cell
+// is the expr.TypeOf its expression. A column keeps its declarations and a
+// copy of its vector, the arithmetic on the integers and the floats runs on
+// the vectors and the other expressions are evaluated row by row. Nothing is
+// shared with b, so either batch may be appended to.
+func Project(b *Batch, list []Projection) (*Batch, error) {
+	meta := dt.NewRowMeta()
+	o := &Batch{meta: meta, keys: append([]uint32(nil), b.keys...), vecs: make([]*Vector, len(list))}
+	for pos, p := range list {
+		typ, err := expr.TypeOf(p.Expr, b.meta)
+		if err != nil {
+			return nil, err
+		}
+		if col, ok := p.Expr.(*expr.Column); ok {
+			item := copyCellMeta(pos, p.Name, b.meta.GetCellMeta(col.Name))
+			meta.AddCellMeta(item)
+			o.vecs[pos] = b.Column(col.Name).clone(item)
+			continue
+		}
+		if typ == expr.NullType {
+			//NULL has no type of its own
+			typ = dt.Int64Type
+		}
+		item := dt.NewCellMetaRaw(pos, typ, p.Name, "", nil)
+		meta.AddCellMeta(item)
+		if o.vecs[pos], err = eval(b, p.Expr, item); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+// copyCellMeta returns the declarations of src at pos with the name
+func copyCellMeta(pos int, name string, src *dt.CellMeta) *dt.CellMeta {
+	precision, scale := src.GetDecimal()
+	keyType, valueType := src.GetMapTypes()
+	return dt.NewCellMetaRaw(pos, src.GetMType(), name, src.GetComment(), nil).
+		WithDecimal(precision, scale).WithTimeKind(src.GetTimeKind()).WithSubType(src.GetSubType()...).
+		WithCollation(src.GetCollation()).WithEncoding(src.GetEncoding()).WithEnumLabels(src.GetEnumLabels()...).
+		WithStructMeta(src.GetStructMeta()).WithMapTypes(keyType, valueType)
+}
+
+// eval returns the vector of e for every row of b
+func eval(b *Batch, e expr.Expr, item *dt.CellMeta) (*Vector, error) {
+	if a, ok := e.(*expr.Arith); ok {
+		if v, ok, err := arithKernel(b, a, item); ok || err != nil {
+			return v, err
+		}
+	}
+	v := NewVector(item, b.Len())
+	row := &batchRow{b: b}
+	for i := 0; i < b.Len(); i++ {
+		row.i = i
+		cell, err := e.Eval(row)
+		if err != nil {
+			return nil, err
+		}
+		if err := v.Append(cell); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// operand is a side of an arithmetic, a vector or a constant
+type operand struct {
+	v     *Vector
+	i64   int64
+	f64   float64
+	float bool
+	null  bool
+}
+
+func (op *operand) isNull(i int) bool {
+	return op.null || (nil != op.v && op.v.IsNull(i))
+}
+
+func (op *operand) int64At(i int) int64 {
+	if nil == op.v {
+		return op.i64
+	}
+	return op.v.Int64s[i]
+}
+
+func (op *operand) float64At(i int) float64 {
+	switch {
+	case nil == op.v && op.float:
+		return op.f64
+	case nil == op.v:
+		return float64(op.i64)
+	case op.float:
+		return op.v.Float64s[i]
+	}
+	return float64(op.v.Int64s[i])
+}
+
+// operandOf returns false if e is not an integer or a float the kernels know
+func operandOf(b *Batch, e expr.Expr) (*operand, bool, error) {
+	switch e := e.(type) {
+	case *expr.Literal:
+		if nil == e.Value {
+			return &operand{null: true}, true, nil
+		}
+		switch x := e.Value.GetValue().(type) {
+		case int64:
+			return &operand{i64: x}, true, nil
+		case float64:
+			return &operand{f64: x, float: true}, true, nil
+		}
+	case *expr.Column:
+		v := b.Column(e.Name)
+		if nil != v && (v.kind == Int64Kind || v.kind == Float64Kind) {
+			return &operand{v: v, float: v.kind == Float64Kind}, true, nil
+		}
+	case *expr.Neg:
+		return operandOf(b, &expr.Arith{Op: expr.OpSub, Left: &expr.Literal{Value: dt.ValidInt64(0)}, Right: e.X})
+	case *expr.Arith:
+		l, ok, err := operandOf(b, e.Left)
+		if !ok || err != nil {
+			return nil, ok, err
+		}
+		r, ok, err := operandOf(b, e.Right)
+		if !ok || err != nil {
+			return nil, ok, err
+		}
+		typ := dt.Int64Type
+		if l.float || r.float {
+			typ = dt.Float64Type
+		}
+		v, err := arithVectors(b.Len(), e.Op, l, r, dt.NewCellMetaRaw(0, typ, "", "", nil))
+		return &operand{v: v, float: l.float || r.float}, true, err
+	}
+	return nil, false, nil
+}
+
+// arithKernel returns false if a side is not an integer or a float
+func arithKernel(b *Batch, e *expr.Arith, item *dt.CellMeta) (*Vector, bool, error) {
+	op, ok, err := operandOf(b, e)
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+	//the vector of an arithmetic is made by arithVectors and is not a column
+	//of b, it is an Int64 or a Float64 vector like the type of e
+	op.v.meta = item
+	return op.v, true, nil
+}
+
+// arithVectors follows expr.Arith, an integer that overflows is expr.ErrOverflow
+func arithVectors(n int, op expr.Op, l *operand, r *operand, item *dt.CellMeta) (*Vector, error) {
+	v := NewVector(item, n)
+	float := l.float || r.float
+	for i := 0; i < n; i++ {
+		if l.isNull(i) || r.isNull(i) {
+			v.AppendNull()
+			continue
+		}
+		if float {
+			x, err := expr.ArithFloat64(op, l.float64At(i), r.float64At(i))
+			if err != nil {
+				return nil, err
+			}
+			v.Float64s = append(v.Float64s, x)
+		} else {
+			x, err := expr.ArithInt64(op, l.int64At(i), r.int64At(i))
+			if err != nil {
+				return nil, err
+			}
+			v.Int64s = append(v.Int64s, x)
+		}
+		v.n++
+	}
+	return v, nil
+}
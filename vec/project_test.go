@@ -0,0 +1,71 @@
+package vec
+
+import (
+	"github.com/lycying/pitydb/dt"
+	"github.com/lycying/pitydb/expr"
+	"github.com/lycying/pitydb/storage/yard"
+	"testing"
+)
+
+func TestProject(t *testing.T) {
+	meta := newOrderMeta()
+	b, _ := FromRows(meta, newOrderRows(meta, 10, true))
+	p, err := Project(b, []Projection{
+		{"product", expr.MustParse("name")},
+		{"total", expr.MustParse("qty * price + 1")},
+		{"next", expr.MustParse("-qty % 4")},
+		{"cost2", expr.MustParse("cost * 2")},
+		{"label", expr.MustParse("name || '/' || qty")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Len() != 10 || p.Keys()[3] != 4 {
+		t.Fatal("the rows should be kept")
+	}
+	product := p.Column("product")
+	if product.GetMeta().GetCollation() != dt.CollateBinary || product.String(2) != "item02" {
+		t.Fatal("a column should keep its declarations")
+	}
+	total := p.Column("total")
+	if total.Kind() != Float64Kind || total.Float64s[3] != 5.5 || !total.IsNull(4) {
+		t.Fatal("total should be a float", total.Float64s)
+	}
+	next := p.Column("next")
+	if next.Kind() != Int64Kind || next.Int64s[7] != -3 || !next.IsNull(9) {
+		t.Fatal("next should be an integer", next.Int64s)
+	}
+	if p.Column("cost2").Get(3).GetValue() != "6.50" || p.Column("label").String(3) != "item03/3" || !p.Column("label").IsNull(6) {
+		t.Fatal("the other expressions should be evaluated by row")
+	}
+	//the kernels and the rows agree
+	for i := 0; i < p.Len(); i++ {
+		want, _ := expr.MustParse("qty * price + 1").Eval(&batchRow{b: b, i: i})
+		if got := total.Get(i); (nil == want) != (nil == got) || (nil != got && got.Compare(want) != 0) {
+			t.Fatal("the row ", i, " should be ", want, ",but ", got)
+		}
+	}
+
+	//the batches share nothing
+	small, _ := FromRows(meta, newOrderRows(meta, 2, false))
+	keys, _ := Project(small, []Projection{{"id", expr.MustParse("id")}, {"qty", expr.MustParse("qty")}})
+	keys.Append(yard.NewRowWithCells(keys.GetMeta(), 3, []dt.DtRefer{dt.ValidNewUInt32(3), nil}))
+	small.Append(newOrderRows(meta, 4, false)[3])
+	if keys.Keys()[2] != 3 || small.Keys()[2] != 4 || small.Column("qty").IsNull(2) || !keys.Column("qty").IsNull(2) {
+		t.Fatal("an append should not change the other batch", keys.Keys(), small.Keys())
+	}
+
+	errs := []struct {
+		e   string
+		err error
+	}{
+		{"qty / 0", dt.ErrDivisionByZero},
+		{"qty + name", expr.ErrNotNumber},
+		{"nope", expr.ErrNoColumn},
+	}
+	for _, c := range errs {
+		if _, err := Project(b, []Projection{{"x", expr.MustParse(c.e)}}); err != c.err {
+			t.Fatal(c.e, " should fail with ", c.err, ",but ", err)
+		}
+	}
+}
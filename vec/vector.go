@@ -0,0 +1,312 @@
+// Package vec keeps the rows of a RowMeta by column in typed vectors, so a
+// scan or an aggregation works on []int64 or []float64 instead of one boxed
+// dt.DtRefer at a time.
+package vec
+
+import (
+	"github.com/lycying/pitydb/dt"
+	"github.com/lycying/pitydb/utils"
+)
+
+// Kind is how the values of a Vector are kept
+type Kind int
+
+const (
+	Int64Kind   Kind = iota //Byte, Int32, UInt32 and Int64
+	Float64Kind             //Float32 and Float64
+	BoolKind
+	StringKind //the offsets of the values in one []byte
+	AnyKind    //the boxed cells of the other types
+)
+
+// KindOf returns the Kind of the vectors of typ
+func KindOf(typ dt.DType) Kind {
+	switch typ {
+	case dt.ByteType, dt.Int32Type, dt.UInt32Type, dt.Int64Type:
+		return Int64Kind
+	case dt.Float32Type, dt.Float64Type:
+		return Float64Kind
+	case dt.BoolType:
+		return BoolKind
+	case dt.StringType:
+		return StringKind
+	}
+	return AnyKind
+}
+
+// Vector is a column of a Batch. Only the slice of its Kind is used, a NULL
+// value is marked in the null bitmap and keeps the zero value in the slice.
+type Vector struct {
+	meta *dt.CellMeta
+	kind Kind
+	n    int
+
+	Int64s   []int64
+	Float64s []float64
+	Bools    []bool
+	Offsets  []int //the string i is Data[Offsets[i]:Offsets[i+1]]
+	Data     []byte
+	Any      []dt.DtRefer
+
+	nulls   *utils.Bitmap
+	hasNull bool
+}
+
+func NewVector(meta *dt.CellMeta, capacity int) *Vector {
+	v := &Vector{
+		meta:  meta,
+		kind:  KindOf(meta.GetMType()),
+		nulls: utils.NewBitmap(uint64(capacity/8 + 1)),
+	}
+	switch v.kind {
+	case Int64Kind:
+		v.Int64s = make([]int64, 0, capacity)
+	case Float64Kind:
+		v.Float64s = make([]float64, 0, capacity)
+	case BoolKind:
+		v.Bools = make([]bool, 0, capacity)
+	case StringKind:
+		v.Offsets = make([]int, 1, capacity+1)
+	default:
+		v.Any = make([]dt.DtRefer, 0, capacity)
+	}
+	return v
+}
+
+func (v *Vector) GetMeta() *dt.CellMeta {
+	return v.meta
+}
+
+func (v *Vector) Kind() Kind {
+	return v.kind
+}
+
+func (v *Vector) Len() int {
+	return v.n
+}
+
+func (v *Vector) IsNull(i int) bool {
+	return v.hasNull && v.nulls.GetBit(uint64(i))
+}
+
+// HasNull reports whether a value of the vector may be NULL
+func (v *Vector) HasNull() bool {
+	return v.hasNull
+}
+
+// String returns the value i of a StringKind vector
+func (v *Vector) String(i int) string {
+	return string(v.Data[v.Offsets[i]:v.Offsets[i+1]])
+}
+
+// AppendNull adds a NULL
+func (v *Vector) AppendNull() {
+	v.nulls.SetBit(uint64(v.n), true)
+	v.hasNull = true
+	v.appendZero()
+}
+
+func (v *Vector) appendZero() {
+	switch v.kind {
+	case Int64Kind:
+		v.Int64s = append(v.Int64s, 0)
+	case Float64Kind:
+		v.Float64s = append(v.Float64s, 0)
+	case BoolKind:
+		v.Bools = append(v.Bools, false)
+	case StringKind:
+		v.Offsets = append(v.Offsets, len(v.Data))
+	default:
+		v.Any = append(v.Any, nil)
+	}
+	v.n++
+}
+
+// Append adds a cell, a nil cell is NULL. The cell is cast to the type of
+// the vector, dt.ErrCastType is returned if it can not be.
+func (v *Vector) Append(cell dt.DtRefer) error {
+	if nil == cell {
+		v.AppendNull()
+		return nil
+	}
+	switch v.kind {
+	case Int64Kind:
+		value, err := v.castValue(cell)
+		if err != nil {
+			return err
+		}
+		var x int64
+		switch value := value.(type) {
+		case byte:
+			x = int64(value)
+		case int32:
+			x = int64(value)
+		case uint32:
+			x = int64(value)
+		case int64:
+			x = value
+		}
+		v.Int64s = append(v.Int64s, x)
+	case Float64Kind:
+		value, err := v.castValue(cell)
+		if err != nil {
+			return err
+		}
+		var x float64
+		switch value := value.(type) {
+		case float32:
+			x = float64(value)
+		case float64:
+			x = value
+		}
+		v.Float64s = append(v.Float64s, x)
+	case BoolKind:
+		x, ok := cell.GetValue().(bool)
+		if !ok {
+			return dt.ErrCastType
+		}
+		v.Bools = append(v.Bools, x)
+	case StringKind:
+		x, ok := cell.GetValue().(string)
+		if !ok {
+			return dt.ErrCastType
+		}
+		v.Data = append(v.Data, x...)
+		v.Offsets = append(v.Offsets, len(v.Data))
+	default:
+		if typ, ok := dt.TypeOf(cell); !ok || typ != v.meta.GetMType() {
+			c := v.meta.NewCell()
+			if err := dt.TrySetValue(c, cell.GetValue()); err != nil {
+				return err
+			}
+			cell = c
+		}
+		//the cell of a row may be changed after it is appended
+		v.Any = append(v.Any, cell.DeepCopy())
+	}
+	v.n++
+	return nil
+}
+
+// castValue returns the value of cell in the type of the vector, so a value
+// that the type can not hold is never appended
+func (v *Vector) castValue(cell dt.DtRefer) (dt.ValueRefer, error) {
+	if typ, ok := dt.TypeOf(cell); ok && typ == v.meta.GetMType() {
+		return cell.GetValue(), nil
+	}
+	x, err := dt.Cast(cell, v.meta.GetMType())
+	if err != nil {
+		return nil, dt.ErrCastType
+	}
+	return x, nil
+}
+
+// truncate drops the values after the first n
+func (v *Vector) truncate(n int) {
+	for i := n; i < v.n; i++ {
+		v.nulls.SetBit(uint64(i), false)
+	}
+	switch v.kind {
+	case Int64Kind:
+		v.Int64s = v.Int64s[:n]
+	case Float64Kind:
+		v.Float64s = v.Float64s[:n]
+	case BoolKind:
+		v.Bools = v.Bools[:n]
+	case StringKind:
+		v.Data = v.Data[:v.Offsets[n]]
+		v.Offsets = v.Offsets[:n+1]
+	default:
+		v.Any = v.Any[:n]
+	}
+	v.n = n
+}
+
+// Get boxes the value i into a new cell of the CellMeta, NULL is nil
+func (v *Vector) Get(i int) dt.DtRefer {
+	if v.IsNull(i) {
+		return nil
+	}
+	if v.kind == AnyKind {
+		return v.Any[i]
+	}
+	cell := v.meta.NewCell()
+	switch v.kind {
+	case Int64Kind:
+		cell.SetValue(narrowInt64(v.meta.GetMType(), v.Int64s[i]))
+	case Float64Kind:
+		if v.meta.GetMType() == dt.Float32Type {
+			cell.SetValue(float32(v.Float64s[i]))
+		} else {
+			cell.SetValue(v.Float64s[i])
+		}
+	case BoolKind:
+		cell.SetValue(v.Bools[i])
+	case StringKind:
+		cell.SetValue(v.String(i))
+	}
+	return cell
+}
+
+// narrowInt64 converts x back to the go type of typ, Append has cast the
+// value to typ so it always fits
+func narrowInt64(typ dt.DType, x int64) dt.ValueRefer {
+	switch typ {
+	case dt.ByteType:
+		return byte(x)
+	case dt.Int32Type:
+		return int32(x)
+	case dt.UInt32Type:
+		return uint32(x)
+	}
+	return x
+}
+
+// clone returns a copy of the vector with meta, nothing is shared with v
+func (v *Vector) clone(meta *dt.CellMeta) *Vector {
+	o := &Vector{
+		meta:     meta,
+		kind:     v.kind,
+		n:        v.n,
+		Int64s:   append([]int64(nil), v.Int64s...),
+		Float64s: append([]float64(nil), v.Float64s...),
+		Bools:    append([]bool(nil), v.Bools...),
+		Offsets:  append([]int(nil), v.Offsets...),
+		Data:     append([]byte(nil), v.Data...),
+		Any:      append([]dt.DtRefer(nil), v.Any...),
+		nulls:    utils.NewBitmap(uint64(v.n/8 + 1)),
+		hasNull:  v.hasNull,
+	}
+	for i := 0; i < v.n && v.hasNull; i++ {
+		if v.nulls.GetBit(uint64(i)) {
+			o.nulls.SetBit(uint64(i), true)
+		}
+	}
+	return o
+}
+
+// Take returns a vector of the values of sel in that order
+func (v *Vector) Take(sel Selection) *Vector {
+	o := NewVector(v.meta, len(sel))
+	for _, i := range sel {
+		if v.IsNull(int(i)) {
+			o.AppendNull()
+			continue
+		}
+		switch v.kind {
+		case Int64Kind:
+			o.Int64s = append(o.Int64s, v.Int64s[i])
+		case Float64Kind:
+			o.Float64s = append(o.Float64s, v.Float64s[i])
+		case BoolKind:
+			o.Bools = append(o.Bools, v.Bools[i])
+		case StringKind:
+			o.Data = append(o.Data, v.Data[v.Offsets[i]:v.Offsets[i+1]]...)
+			o.Offsets = append(o.Offsets, len(o.Data))
+		default:
+			o.Any = append(o.Any, v.Any[i])
+		}
+		o.n++
+	}
+	return o
+}
@@ -10,6 +10,7 @@ func Start() {
 	http.Handle("/css/", http.FileServer(http.Dir("template")))
 	http.Handle("/js/", http.FileServer(http.Dir("template")))
 
+	http.HandleFunc("/tree", treeHandler)
 	http.HandleFunc("/", indexHandler)
 	http.ListenAndServe(":8888", nil)
 }
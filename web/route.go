@@ -1,11 +1,23 @@
 package web
 
 import (
+	"github.com/lycying/pitydb/storage/yard"
 	"html/template"
 	"log"
 	"net/http"
+	"sync"
 )
 
+var treeLock sync.RWMutex
+var trees = make(map[string]*yard.PageTree)
+
+// RegisterTree makes the tree visible to the web console by name
+func RegisterTree(name string, tree *yard.PageTree) {
+	treeLock.Lock()
+	defer treeLock.Unlock()
+	trees[name] = tree
+}
+
 func indexHandler(w http.ResponseWriter, r *http.Request) {
 	t, err := template.ParseFiles("template/html/index.html")
 	if err != nil {
@@ -14,3 +26,26 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
 	t.Execute(w, nil)
 
 }
+
+// treeHandler exports the tree structure, /tree?name=xxx&format=dot|json
+func treeHandler(w http.ResponseWriter, r *http.Request) {
+	treeLock.RLock()
+	tree, ok := trees[r.URL.Query().Get("name")]
+	treeLock.RUnlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	var err error
+	if r.URL.Query().Get("format") == "dot" {
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		err = tree.ExportDOT(w)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		err = tree.ExportJSON(w)
+	}
+	if err != nil {
+		log.Println(err)
+	}
+}